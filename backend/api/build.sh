@@ -6,6 +6,14 @@ LAMBDA_DIRS=(
   "google/log-in"
   "sighting-listing"
   "lost-listing"
+  "listing-purge"
+  "data-retention"
+  "organization"
+  "webhook-dispatch"
+  "social-publish"
+  "notification"
+  "notification-dispatch"
+  "listing-digest"
 )
 
 # Detect root directory of script
@@ -20,7 +28,6 @@ echo "🏗️  Building Go Lambda functions..."
 for dir in "${LAMBDA_DIRS[@]}"; do
   SRC_DIR="${ROOT_DIR}/${dir}"
   DEPLOY_DIR="${SRC_DIR}/deploy"
-  MAIN_GO="${SRC_DIR}/main.go"
   BINARY="${DEPLOY_DIR}/bootstrap"
   ZIP_FILE="${DEPLOY_DIR}/bootstrap.zip"
 
@@ -28,10 +35,10 @@ for dir in "${LAMBDA_DIRS[@]}"; do
 
   mkdir -p "${DEPLOY_DIR}"
 
-  # Only rebuild if main.go is newer than the binary or if binary doesn't exist
-  if [[ ! -f "$BINARY" ]] || [[ "$MAIN_GO" -nt "$BINARY" ]]; then
+  # Only rebuild if a Go file in the Lambda or in generic/ is newer than the binary or if binary doesn't exist
+  if [[ ! -f "$BINARY" ]] || [[ -n "$(find "$SRC_DIR" "${ROOT_DIR}/generic" -name '*.go' -newer "$BINARY")" ]]; then
     echo "   → Compiling Go source..."
-    GOOS=$GOOS GOARCH=$GOARCH go build -o "$BINARY" "$MAIN_GO"
+    (cd "$ROOT_DIR" && GOOS=$GOOS GOARCH=$GOARCH go build -o "$BINARY" "./${dir}")
 
     # Zip the binary
     if command -v zip &> /dev/null; then
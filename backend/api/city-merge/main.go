@@ -0,0 +1,297 @@
+// city-merge consolidates duplicate cities. Cities whose names come to the same place
+// key once folded ("calgary", "Calgary " and "Calgary, AB") or that the gazetteer knows
+// under another name are merged into one: their locations and digest cities are
+// repointed to the city that is kept, and their spellings become aliases of it so new
+// listings typed the same way land there too. Cities created before place keys existed
+// get theirs, with their ISO codes, on the first run.
+//
+// Usage:
+//
+//	DATABASE_URL=... go run ./city-merge [-dry-run]
+//	DATABASE_URL=... go run ./city-merge -into 12 -from 34,56 [-dry-run]
+//
+// -into and -from merge cities the names can't tell are the same, like a misspelling
+// the gazetteer doesn't know; the -from cities are folded into the -into city.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/jackc/pgx/v5"
+)
+
+type City struct {
+	ID       int
+	Place    generic.Place
+	PlaceKey *string
+}
+
+// Merge folds the From cities into Into, which takes the Canonical names under Key
+type Merge struct {
+	Into      City
+	From      []City
+	Canonical generic.Place
+	Key       string
+	Aliases   []string
+}
+
+func main() {
+	into := flag.Int("into", 0, "ID of the city to keep when merging by hand")
+	from := flag.String("from", "", "comma separated IDs of the cities to merge into -into")
+	dryRun := flag.Bool("dry-run", false, "print the merges without writing to the database")
+	flag.Parse()
+
+	fromIDs, err := parseIDs(*from)
+	if err != nil || (*into == 0) != (len(fromIDs) == 0) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	cities, err := loadCities(ctx, conn)
+	if err != nil {
+		log.Fatalf("failed to load cities: %v", err)
+	}
+
+	var merges []Merge
+	if *into != 0 {
+		merges, err = planManualMerge(cities, *into, fromIDs)
+	} else {
+		merges, err = planMerges(ctx, cities)
+	}
+	if err != nil {
+		log.Fatalf("failed to plan merges: %v", err)
+	}
+
+	merged := 0
+	for _, merge := range merges {
+		log.Printf("%s", describe(merge))
+		if *dryRun {
+			continue
+		}
+		if err := applyMerge(ctx, conn, merge); err != nil {
+			log.Fatalf("failed to merge into city %d: %v", merge.Into.ID, err)
+		}
+		merged += len(merge.From)
+	}
+	if *dryRun {
+		log.Printf("dry run: %d of %d cities would change", len(merges), len(cities))
+		return
+	}
+	log.Printf("updated %d cities and merged %d duplicates away", len(merges), merged)
+}
+
+func parseIDs(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func loadCities(ctx context.Context, conn *pgx.Conn) ([]City, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT id, city_name, province_or_state, country, place_key
+		FROM cities
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (City, error) {
+		var c City
+		err := row.Scan(&c.ID, &c.Place.City, &c.Place.ProvinceOrState, &c.Place.Country, &c.PlaceKey)
+		return c, err
+	})
+}
+
+// planMerges groups the cities by the key of their canonical names. Each group keeps
+// the city already stored under that key, or else the oldest, and merges the rest into
+// it. Groups that are a single city already stored under its key are left out.
+func planMerges(ctx context.Context, cities []City) ([]Merge, error) {
+	gazetteer, err := generic.DefaultGazetteer()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*Merge{}
+	var keys []string
+	for _, city := range cities {
+		canonical, err := generic.ResolveCity(ctx, gazetteer, city.Place)
+		if err != nil {
+			var validationErr *generic.ValidationError
+			if !errors.As(err, &validationErr) {
+				return nil, err
+			}
+			// An ambiguous name keeps its own spelling, tidied up
+			canonical = generic.NormalizePlace(city.Place)
+		}
+		canonical = generic.Place{City: canonical.City, ProvinceOrState: canonical.ProvinceOrState, Country: canonical.Country}
+
+		key := generic.CityKey(canonical)
+		group, ok := groups[key]
+		switch {
+		case !ok:
+			groups[key] = &Merge{Into: city, Canonical: canonical, Key: key}
+			keys = append(keys, key)
+		case city.PlaceKey != nil && *city.PlaceKey == key:
+			group.From = append(group.From, group.Into)
+			group.Into = city
+		default:
+			group.From = append(group.From, city)
+		}
+	}
+
+	var merges []Merge
+	for _, key := range keys {
+		merge := *groups[key]
+		merge.Aliases = aliasesOf(merge)
+		if len(merge.From) == 0 && len(merge.Aliases) == 0 && merge.Into.PlaceKey != nil && *merge.Into.PlaceKey == key && merge.Into.Place == merge.Canonical {
+			continue
+		}
+		merges = append(merges, merge)
+	}
+	return merges, nil
+}
+
+// planManualMerge merges the from cities into the into city, keeping its names
+func planManualMerge(cities []City, into int, from []int) ([]Merge, error) {
+	byID := map[int]City{}
+	for _, city := range cities {
+		byID[city.ID] = city
+	}
+
+	target, ok := byID[into]
+	if !ok {
+		return nil, fmt.Errorf("there is no city %d", into)
+	}
+	canonical := generic.NormalizePlace(target.Place)
+	merge := Merge{Into: target, Canonical: canonical, Key: generic.CityKey(canonical)}
+	for _, id := range from {
+		city, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("there is no city %d", id)
+		}
+		if id == into {
+			return nil, fmt.Errorf("city %d can't be merged into itself", id)
+		}
+		merge.From = append(merge.From, city)
+	}
+	merge.Aliases = aliasesOf(merge)
+	return []Merge{merge}, nil
+}
+
+// aliasesOf returns the keys the merged cities were stored or typed under, other than
+// the key they are merged under
+func aliasesOf(merge Merge) []string {
+	seen := map[string]bool{merge.Key: true}
+	var aliases []string
+	for _, city := range append([]City{merge.Into}, merge.From...) {
+		keys := []string{generic.CityKey(city.Place)}
+		if city.PlaceKey != nil {
+			keys = append(keys, *city.PlaceKey)
+		}
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				aliases = append(aliases, key)
+			}
+		}
+	}
+	return aliases
+}
+
+func applyMerge(ctx context.Context, conn *pgx.Conn, merge Merge) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(merge.From) > 0 {
+		fromIDs := make([]int, len(merge.From))
+		for i, city := range merge.From {
+			fromIDs[i] = city.ID
+		}
+		for _, query := range []string{
+			`UPDATE locations SET city_id = $1 WHERE city_id = ANY($2)`,
+			`UPDATE notification_preferences SET digest_city_id = $1 WHERE digest_city_id = ANY($2)`,
+			`UPDATE city_aliases SET city_id = $1 WHERE city_id = ANY($2)`,
+		} {
+			if _, err := tx.Exec(ctx, query, merge.Into.ID, fromIDs); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM cities WHERE id = ANY($1)`, fromIDs); err != nil {
+			return err
+		}
+	}
+
+	if len(merge.Aliases) > 0 {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO city_aliases (alias_key, city_id, created_at)
+			SELECT alias_key, $2, $3 FROM unnest($1::text[]) AS alias_key
+			ON CONFLICT (alias_key) DO UPDATE SET city_id = EXCLUDED.city_id
+		`, merge.Aliases, merge.Into.ID, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	// A city whose names have changed since it was keyed may still hold the key; it
+	// gets its own key when its group is merged
+	if _, err := tx.Exec(ctx, `UPDATE cities SET place_key = NULL WHERE place_key = $1 AND id <> $2`, merge.Key, merge.Into.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM city_aliases WHERE alias_key = $1`, merge.Key); err != nil {
+		return err
+	}
+
+	countryCode, subdivisionCode := generic.PlaceCodes(merge.Canonical)
+	_, err = tx.Exec(ctx, `
+		UPDATE cities
+		SET city_name = $2, province_or_state = $3, country = $4,
+			country_code = $5, subdivision_code = $6, place_key = $7
+		WHERE id = $1
+	`, merge.Into.ID, merge.Canonical.City, merge.Canonical.ProvinceOrState, merge.Canonical.Country,
+		countryCode, subdivisionCode, merge.Key)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func describe(merge Merge) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "city %d %q -> %q (%s)", merge.Into.ID, placeName(merge.Into.Place), placeName(merge.Canonical), merge.Key)
+	for _, city := range merge.From {
+		fmt.Fprintf(&b, "\n\tmerging city %d %q", city.ID, placeName(city.Place))
+	}
+	return b.String()
+}
+
+func placeName(place generic.Place) string {
+	return strings.Join([]string{place.City, place.ProvinceOrState, place.Country}, ", ")
+}
@@ -0,0 +1,80 @@
+// data-retention deletes the rows other jobs and endpoints leave behind once they are
+// past their retention period. Each table it trims is listed in policies with how long
+// its rows are kept and why deleting them is safe.
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// policy deletes the rows of one table that are older than keep. query takes the cutoff
+// as $1.
+type policy struct {
+	name  string
+	keep  time.Duration
+	query string
+}
+
+var policies = []policy{
+	// Limits only count hits in their current window, and no window is longer than this
+	{
+		name:  "rate limit windows",
+		keep:  generic.RateLimitRetention,
+		query: `DELETE FROM rate_limits WHERE window_start < $1`,
+	},
+	// Deleting an event removes its deliveries too; events still being retried are kept
+	{
+		name: "webhook events",
+		keep: generic.WebhookRetention,
+		query: `
+			DELETE FROM event_outbox e
+			WHERE e.dispatched_at < $1
+				AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.event_id = e.id AND d.status = 'pending')
+		`,
+	},
+	// Pending notifications are still to be sent, however old
+	{
+		name:  "notifications",
+		keep:  generic.NotificationRetention,
+		query: `DELETE FROM notifications WHERE status <> 'pending' AND created_at < $1`,
+	},
+	// A listing only goes in a digest if it was posted since the last one, so old
+	// records of what was sent can't cause a repeat
+	{
+		name:  "digest items",
+		keep:  generic.NotificationRetention,
+		query: `DELETE FROM digest_items WHERE sent_at < $1`,
+	},
+}
+
+func main() {
+	lambda.Start(handler)
+}
+
+// handler runs once a day and applies every policy. A policy that fails is logged and
+// the rest still run; the run fails if any did.
+func handler(ctx context.Context) error {
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var firstErr error
+	for _, p := range policies {
+		tag, err := conn.Exec(ctx, p.query, time.Now().Add(-p.keep))
+		if err != nil {
+			generic.Logger.Error("failed to delete expired rows", "policy", p.name, "error", err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		generic.Logger.Info("deleted expired rows", "policy", p.name, "count", tag.RowsAffected())
+	}
+	return firstErr
+}
@@ -0,0 +1,128 @@
+package generic
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Each user can register MaxAlertAreas areas. A circle's radius is at most
+// MaxAreaRadiusKm, and a polygon must fit in a box of the same size across. Areas can't
+// be smaller than a circle of MinAreaRadiusKm, the size of the coarsest privacy cell, so
+// they can't be packed around a listing to narrow down where it is.
+const (
+	MaxAlertAreas     = 5
+	MinAreaRadiusKm   = 1
+	MaxAreaRadiusKm   = 25
+	MinPolygonAreaKm2 = math.Pi * MinAreaRadiusKm * MinAreaRadiusKm
+	MaxPolygonPoints  = 50
+)
+
+// An area broadcasts at most AreaAlertLimit new lost listings per AreaAlertWindow, so a
+// burst of listings in one neighbourhood doesn't flood the people who live there. The
+// window must not be longer than RateLimitRetention.
+const (
+	AreaAlertLimit  = 5
+	AreaAlertWindow = 24 * time.Hour
+)
+
+type LatLng struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// ValidPolygon checks an alert area polygon: enough points, real coordinates, no
+// smaller than MinPolygonAreaKm2 and no bigger than a circle of MaxAreaRadiusKm. Empty
+// polygons are skipped.
+func ValidPolygon(field string, points []LatLng) Rule {
+	return func() *FieldError {
+		if len(points) == 0 {
+			return nil
+		}
+		if len(points) < 3 || len(points) > MaxPolygonPoints {
+			return &FieldError{Field: field, Code: CodeInvalid, Message: "must have between 3 and " + strconv.Itoa(MaxPolygonPoints) + " points"}
+		}
+
+		minLat, maxLat, minLng, maxLng := 90.0, -90.0, 180.0, -180.0
+		for _, p := range points {
+			if p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 {
+				return &FieldError{Field: field, Code: CodeOutOfRange, Message: "points must have a lat between -90 and 90 and a lng between -180 and 180"}
+			}
+			minLat, maxLat = math.Min(minLat, p.Lat), math.Max(maxLat, p.Lat)
+			minLng, maxLng = math.Min(minLng, p.Lng), math.Max(maxLng, p.Lng)
+		}
+
+		// Roughly 111 km per degree of latitude, and fewer per degree of longitude away from the equator
+		heightKm := (maxLat - minLat) * 111.32
+		widthKm := (maxLng - minLng) * 111.32 * math.Cos((minLat+maxLat)/2*math.Pi/180)
+		if heightKm > 2*MaxAreaRadiusKm || widthKm > 2*MaxAreaRadiusKm {
+			return &FieldError{Field: field, Code: CodeOutOfRange, Message: "must fit in " + strconv.Itoa(2*MaxAreaRadiusKm) + " km across"}
+		}
+
+		// Shoelace formula over the same flat projection
+		cos := math.Cos((minLat + maxLat) / 2 * math.Pi / 180)
+		area := 0.0
+		for i, p := range points {
+			q := points[(i+1)%len(points)]
+			area += p.Lng*cos*q.Lat - q.Lng*cos*p.Lat
+		}
+		if math.Abs(area)/2*111.32*111.32 < MinPolygonAreaKm2 {
+			return &FieldError{Field: field, Code: CodeOutOfRange, Message: "must cover at least " + strconv.FormatFloat(MinPolygonAreaKm2, 'f', 1, 64) + " square km"}
+		}
+		return nil
+	}
+}
+
+// PolygonLiteral formats points as a Postgres polygon, with x as longitude and y as latitude
+func PolygonLiteral(points []LatLng) string {
+	vertices := make([]string, 0, len(points))
+	for _, p := range points {
+		vertices = append(vertices, "("+strconv.FormatFloat(p.Lng, 'f', -1, 64)+","+strconv.FormatFloat(p.Lat, 'f', -1, 64)+")")
+	}
+	return "(" + strings.Join(vertices, ",") + ")"
+}
+
+// QueueAreaAlerts tells the users whose alert areas contain a new lost listing's last
+// seen location, at lat and lng. Areas are matched against the point other users see at
+// the listing's privacy level, not the true one. Owners aren't told about their own
+// listings, and an area over AreaAlertLimit for the current window is skipped. Call it in the listing's
+// transaction so the alerts and the rate limit hits only count if the listing commits.
+func QueueAreaAlerts(ctx context.Context, db Execer, listingID int, privacy string, lat, lng float64) error {
+	now := time.Now()
+	lat, lng = FuzzCoordinates(privacy, PrivacySeed(ListingTypeLost, listingID), lat, lng)
+	point := GeogParam(6, 7)
+	// The circle test is bounded by MaxAreaRadiusKm first so it can use the index on the centers
+	maxRadius := strconv.Itoa(MaxAreaRadiusKm)
+	_, err := db.Exec(ctx, `
+		WITH matched AS (
+			SELECT a.id, a.user_uuid, a.name, l.id AS listing_id, l.pet_name, l.animal_type, c.city_name
+			FROM lost_pet_listing l
+			JOIN locations loc ON loc.id = l.last_seen_location
+			LEFT JOIN cities c ON c.id = loc.city_id
+			JOIN alert_areas a ON a.user_uuid IS DISTINCT FROM l.listing_owner AND (
+				a.boundary @> point($7::float8, $6::float8)
+				OR (`+WithinKm("a.center", point, maxRadius)+` AND `+WithinKm("a.center", point, "a.radius_km")+`)
+			)
+			WHERE l.id = $1
+		), hits AS (
+			INSERT INTO rate_limits (key, window_start, hits)
+			SELECT 'area-alert:' || id, $3, 1 FROM matched
+			ON CONFLICT (key, window_start) DO UPDATE SET hits = rate_limits.hits + 1
+			RETURNING key, hits
+		)
+		INSERT INTO notifications (user_uuid, kind, channel, data, next_attempt_at, created_at)
+		SELECT m.user_uuid, $2, ch.channel, jsonb_build_object(
+			'lost_listing_id', m.listing_id,
+			'pet_name', m.pet_name,
+			'animal_type', m.animal_type,
+			'area_name', m.name,
+			'city', m.city_name
+		), $5, $5
+		FROM matched m
+		JOIN hits h ON h.key = 'area-alert:' || m.id AND h.hits <= $4
+		CROSS JOIN LATERAL `+RouteChannels(NotifyAreaAlert, "m.user_uuid")+` AS ch(channel)
+	`, listingID, NotifyAreaAlert, now.Truncate(AreaAlertWindow), AreaAlertLimit, now, lat, lng)
+	return err
+}
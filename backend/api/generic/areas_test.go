@@ -0,0 +1,46 @@
+package generic
+
+import "testing"
+
+// square returns the corners of a square about sideKm across centred on lat, lng
+func square(lat, lng, sideKm float64) []LatLng {
+	half := sideKm / 2
+	var points []LatLng
+	for _, corner := range [][2]float64{{-1, -1}, {-1, 1}, {1, 1}, {1, -1}} {
+		cornerLat, cornerLng := offsetKm(lat, lng, 0, corner[0]*half, corner[1]*half)
+		points = append(points, LatLng{Lat: cornerLat, Lng: cornerLng})
+	}
+	return points
+}
+
+func TestValidPolygon(t *testing.T) {
+	tests := []struct {
+		name     string
+		points   []LatLng
+		wantCode string
+	}{
+		{"empty", nil, ""},
+		{"two points", square(51, -114, 5)[:2], CodeInvalid},
+		{"out of range", []LatLng{{Lat: 91, Lng: 0}, {Lat: 0, Lng: 1}, {Lat: 1, Lng: 1}}, CodeOutOfRange},
+		{"5 km square", square(51, -114, 5), ""},
+		{"5 km square near the pole", square(80, -114, 5), ""},
+		{"square at the minimum area", square(51, -114, 1.8), ""},
+		{"1 km square", square(51, -114, 1), CodeOutOfRange},
+		{"thin sliver", []LatLng{{Lat: 51, Lng: -114}, {Lat: 51.2, Lng: -114}, {Lat: 51.2, Lng: -113.9999}}, CodeOutOfRange},
+		{"repeated point", []LatLng{{Lat: 51, Lng: -114}, {Lat: 51, Lng: -114}, {Lat: 51, Lng: -114}}, CodeOutOfRange},
+		{"wider than the maximum", square(51, -114, 2*MaxAreaRadiusKm+5), CodeOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidPolygon("polygon", tt.points)()
+			switch {
+			case tt.wantCode == "" && err != nil:
+				t.Errorf("got %s: %s", err.Code, err.Message)
+			case tt.wantCode != "" && err == nil:
+				t.Errorf("got no error, want %s", tt.wantCode)
+			case tt.wantCode != "" && err.Code != tt.wantCode:
+				t.Errorf("got %s (%s), want %s", err.Code, err.Message, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package generic
+
+// Listing digest frequencies stored in notification_preferences.digest_frequency. A
+// digest is off until the user picks one and an area.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+var DigestFrequencies = []string{DigestOff, DigestDaily, DigestWeekly}
+
+// Digests are sent from DigestHour in the user's timezone
+const DigestHour = 8
+
+// DigestMaxListings is how many listings one digest shows; the rest are only counted
+const DigestMaxListings = 20
+
+// MaxDigestRadiusKm is the largest area around a point a digest can cover
+const MaxDigestRadiusKm = 100
+
+// DigestPeriodDays is how many days apart a user's digests are. A first digest covers
+// the listings posted in the last period.
+func DigestPeriodDays(frequency string) int {
+	if frequency == DigestWeekly {
+		return 7
+	}
+	return 1
+}
+
+// DefaultDigestRadiusKm is used when a user picks a point for their digest without a radius
+const DefaultDigestRadiusKm = 10
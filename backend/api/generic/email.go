@@ -0,0 +1,280 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email transports selected with EMAIL_TRANSPORT
+const (
+	TransportSMTP    = "smtp"
+	TransportCapture = "capture"
+)
+
+// EmailMessage is one rendered email. Headers are added to the standard ones, e.g.
+// List-Unsubscribe.
+type EmailMessage struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string
+}
+
+// Mailer sends email
+type Mailer interface {
+	Send(ctx context.Context, msg EmailMessage) error
+}
+
+// NewMailer returns the transport named by EMAIL_TRANSPORT. It defaults to smtp when
+// SMTP_HOST is set and to capture otherwise, so nothing is sent by accident locally.
+func NewMailer() (Mailer, error) {
+	transport := os.Getenv("EMAIL_TRANSPORT")
+	if transport == "" {
+		transport = TransportCapture
+		if os.Getenv("SMTP_HOST") != "" {
+			transport = TransportSMTP
+		}
+	}
+
+	from := os.Getenv("EMAIL_FROM")
+	if from == "" {
+		return nil, errors.New("EMAIL_FROM is not configured")
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, errors.New("EMAIL_FROM is not a valid address: " + err.Error())
+	}
+
+	switch transport {
+	case TransportSMTP:
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, errors.New("SMTP_HOST is not configured")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Addr:     net.JoinHostPort(host, port),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case TransportCapture:
+		return &CaptureMailer{From: from, Dir: os.Getenv("EMAIL_CAPTURE_DIR")}, nil
+	default:
+		return nil, errors.New("unknown email transport " + transport)
+	}
+}
+
+// smtpTimeout bounds a whole SMTP conversation, so a stalled relay can't hold up the
+// rest of a dispatch run
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer sends through an SMTP relay, upgrading to TLS with STARTTLS when the
+// server offers it. Credentials are only sent over TLS.
+type SMTPMailer struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg EmailMessage) error {
+	body, err := buildEmail(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(m.From)
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	// Cancelling ctx interrupts a read or write that is waiting on the relay
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(m.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		// PlainAuth refuses to send the password unless the connection is TLS
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// CaptureMailer keeps every message in memory instead of sending it, and also writes
+// it as an .eml file when Dir is set. Use it in tests and local runs.
+type CaptureMailer struct {
+	From string
+	Dir  string
+
+	mu       sync.Mutex
+	messages []EmailMessage
+}
+
+func (m *CaptureMailer) Send(ctx context.Context, msg EmailMessage) error {
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return errors.New("invalid recipient: " + err.Error())
+	}
+
+	m.mu.Lock()
+	m.messages = append(m.messages, msg)
+	m.mu.Unlock()
+
+	if m.Dir == "" {
+		return nil
+	}
+	body, err := buildEmail(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + ".eml"
+	return os.WriteFile(filepath.Join(m.Dir, name), body, 0o644)
+}
+
+// Messages returns the messages sent so far
+func (m *CaptureMailer) Messages() []EmailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]EmailMessage(nil), m.messages...)
+}
+
+// buildEmail writes msg as a MIME message with a plain text part and, if msg has one,
+// an HTML alternative
+func buildEmail(from string, msg EmailMessage, date time.Time) ([]byte, error) {
+	for _, value := range []string{msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, errors.New("email header contains a line break")
+		}
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if address, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(address.Address, "@"); ok {
+			domain = d
+		}
+	}
+
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	for key, value := range msg.Headers {
+		if strings.ContainsAny(key+value, "\r\n") {
+			return nil, errors.New("email header contains a line break")
+		}
+		header(textproto.CanonicalMIMEHeaderKey(key), value)
+	}
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
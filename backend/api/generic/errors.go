@@ -0,0 +1,174 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Machine-readable error codes returned in the error envelope
+const (
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeForbidden        = "forbidden"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeInvalidRequest   = "invalid_request"
+	ErrCodeValidationFailed = "validation_failed"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeInternal         = "internal_error"
+)
+
+// APIError is the body every handler returns on failure, wrapped as {"error": APIError}
+type APIError struct {
+	Code      string           `json:"code"`
+	Message   string           `json:"message"`
+	Fields    ValidationErrors `json:"fields,omitempty"`
+	RequestID string           `json:"request_id,omitempty"`
+}
+
+type AuthError struct {
+	Message string
+	Err     error
+}
+
+func (e *AuthError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+type ForbiddenError struct {
+	Message string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Message
+}
+
+type NotFoundError struct {
+	Message string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Message
+}
+
+// RateLimitError is returned when a caller has made too many requests. RetryAfter is
+// in seconds and is sent back as the Retry-After header.
+type RateLimitError struct {
+	Message    string
+	RetryAfter int
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Message
+}
+
+// ValidationError is a single bad input. Field is empty when the whole request is malformed.
+type ValidationError struct {
+	Message string
+	Field   string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// InternalError carries a safe Message for the client and the underlying Err for the logs
+type InternalError struct {
+	Message string
+	Err     error
+}
+
+func (e *InternalError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *InternalError) Unwrap() error {
+	return e.Err
+}
+
+// Internal wraps err so that only message is returned to the client
+func Internal(message string, err error) error {
+	return &InternalError{Message: message, Err: err}
+}
+
+// ErrorResponse maps err to a status code and error envelope. Anything that is not one of
+// the typed errors above is logged and recorded on the request's span, and reported as a
+// generic internal error.
+func ErrorResponse(ctx context.Context, request events.APIGatewayProxyRequest, err error) (events.APIGatewayProxyResponse, error) {
+	status, apiErr := classify(err)
+	apiErr.RequestID = request.RequestContext.RequestID
+
+	if status >= http.StatusInternalServerError {
+		SpanFromContext(ctx).RecordError(err)
+		logger := Log(ctx)
+		if requestInfoFrom(ctx) == nil {
+			logger = logger.With("request_id", apiErr.RequestID, "route", request.HTTPMethod+" "+request.Resource)
+		}
+		logger.Error(apiErr.Message, "error", err.Error())
+	}
+
+	response, respErr := Response(status, Json{"error": apiErr})
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		response.Headers["Retry-After"] = strconv.Itoa(rateLimitErr.RetryAfter)
+	}
+	return response, respErr
+}
+
+// MethodNotAllowed is returned by handlers for unsupported HTTP methods
+func MethodNotAllowed(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return Response(http.StatusMethodNotAllowed, Json{"error": APIError{
+		Code:      ErrCodeMethodNotAllowed,
+		Message:   "method not allowed",
+		RequestID: request.RequestContext.RequestID,
+	}})
+}
+
+func classify(err error) (int, APIError) {
+	var (
+		authErr       *AuthError
+		forbiddenErr  *ForbiddenError
+		notFoundErr   *NotFoundError
+		rateLimitErr  *RateLimitError
+		validationErr *ValidationError
+		fieldErrs     ValidationErrors
+		internalErr   *InternalError
+	)
+
+	switch {
+	case errors.As(err, &fieldErrs):
+		return http.StatusBadRequest, APIError{Code: ErrCodeValidationFailed, Message: "request validation failed", Fields: fieldErrs}
+	case errors.As(err, &validationErr):
+		apiErr := APIError{Code: ErrCodeInvalidRequest, Message: validationErr.Message}
+		if validationErr.Field != "" {
+			apiErr.Code = ErrCodeValidationFailed
+			apiErr.Fields = ValidationErrors{{Field: validationErr.Field, Code: CodeInvalid, Message: validationErr.Message}}
+		}
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &authErr):
+		return http.StatusUnauthorized, APIError{Code: ErrCodeUnauthorized, Message: authErr.Message}
+	case errors.As(err, &forbiddenErr):
+		return http.StatusForbidden, APIError{Code: ErrCodeForbidden, Message: forbiddenErr.Message}
+	case errors.As(err, &notFoundErr):
+		return http.StatusNotFound, APIError{Code: ErrCodeNotFound, Message: notFoundErr.Message}
+	case errors.As(err, &rateLimitErr):
+		return http.StatusTooManyRequests, APIError{Code: ErrCodeRateLimited, Message: rateLimitErr.Message}
+	case errors.As(err, &internalErr):
+		return http.StatusInternalServerError, APIError{Code: ErrCodeInternal, Message: internalErr.Message}
+	default:
+		return http.StatusInternalServerError, APIError{Code: ErrCodeInternal, Message: "internal server error"}
+	}
+}
@@ -0,0 +1,230 @@
+package generic
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Export formats accepted by the format query parameter
+const (
+	ExportCSV     = "csv"
+	ExportGeoJSON = "geojson"
+	ExportKML     = "kml"
+)
+
+// An export stops at MaxExportRows rows or MaxExportBytes bytes, whichever comes first.
+// The byte cap leaves room under Lambda's 6 MB response limit for the body to be JSON
+// escaped in the response envelope.
+const (
+	MaxExportRows  = 10000
+	MaxExportBytes = 4 << 20
+)
+
+var exportContentTypes = map[string]string{
+	ExportCSV:     "text/csv; charset=utf-8",
+	ExportGeoJSON: "application/geo+json",
+	ExportKML:     "application/vnd.google-earth.kml+xml",
+}
+
+// exportClosings end each format's document
+var exportClosings = map[string]string{
+	ExportGeoJSON: `]}`,
+	ExportKML:     `</Document></kml>`,
+}
+
+// Exporter writes rows in one of the export formats as they are read from the database,
+// without holding them in memory as structs
+type Exporter struct {
+	format    string
+	columns   []string
+	out       strings.Builder
+	row       strings.Builder
+	csv       *csv.Writer
+	rows      int
+	Truncated bool
+}
+
+// NewExporter starts an export. columns name the values passed to Add; title names
+// the KML document.
+func NewExporter(format string, columns []string, title string) (*Exporter, error) {
+	if format == "" {
+		format = ExportCSV
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		return nil, &ValidationError{Message: "must be one of: csv, geojson, kml", Field: "format"}
+	}
+
+	e := &Exporter{format: format, columns: columns}
+	switch format {
+	case ExportCSV:
+		e.csv = csv.NewWriter(&e.out)
+		e.csv.Write(append(append([]string{}, columns...), "latitude", "longitude"))
+		e.csv.Flush()
+		// Rows are written to their own buffer first so a row that would go over
+		// MaxExportBytes can be left out
+		e.csv = csv.NewWriter(&e.row)
+	case ExportGeoJSON:
+		e.out.WriteString(`{"type":"FeatureCollection","features":[`)
+	case ExportKML:
+		e.out.WriteString(xml.Header)
+		e.out.WriteString(`<kml xmlns="http://www.opengis.net/kml/2.2"><Document><name>`)
+		xml.EscapeText(&e.out, []byte(title))
+		e.out.WriteString(`</name>`)
+	}
+	return e, nil
+}
+
+// Add writes one row. values line up with the exporter's columns; lat/lng are nil for
+// rows without a location. name labels the KML placemark. It returns false, leaving the
+// row out and marking the export truncated, when the row would take the export over
+// MaxExportBytes.
+func (e *Exporter) Add(name string, values []any, lat, lng *float64) bool {
+	e.row.Reset()
+	switch e.format {
+	case ExportCSV:
+		record := make([]string, 0, len(values)+2)
+		for _, value := range values {
+			record = append(record, csvText(value))
+		}
+		record = append(record, exportText(lat), exportText(lng))
+		e.csv.Write(record)
+		e.csv.Flush()
+
+	case ExportGeoJSON:
+		properties := make(map[string]any, len(values))
+		for i, value := range values {
+			properties[e.columns[i]] = exportValue(value)
+		}
+		feature := map[string]any{"type": "Feature", "properties": properties, "geometry": nil}
+		if lat != nil && lng != nil {
+			// GeoJSON positions are [longitude, latitude]
+			feature["geometry"] = map[string]any{"type": "Point", "coordinates": []float64{*lng, *lat}}
+		}
+		raw, _ := json.Marshal(feature)
+		if e.rows > 0 {
+			e.row.WriteByte(',')
+		}
+		e.row.Write(raw)
+
+	case ExportKML:
+		e.row.WriteString(`<Placemark><name>`)
+		xml.EscapeText(&e.row, []byte(name))
+		e.row.WriteString(`</name><ExtendedData>`)
+		for i, value := range values {
+			e.row.WriteString(`<Data name="` + e.columns[i] + `"><value>`)
+			xml.EscapeText(&e.row, []byte(exportText(value)))
+			e.row.WriteString(`</value></Data>`)
+		}
+		e.row.WriteString(`</ExtendedData>`)
+		if lat != nil && lng != nil {
+			e.row.WriteString(`<Point><coordinates>` + exportText(lng) + `,` + exportText(lat) + `</coordinates></Point>`)
+		}
+		e.row.WriteString(`</Placemark>`)
+	}
+
+	if e.out.Len()+e.row.Len()+len(exportClosings[e.format]) > MaxExportBytes {
+		e.Truncated = true
+		return false
+	}
+	e.out.WriteString(e.row.String())
+	e.rows++
+	return true
+}
+
+// Rows is the number of rows added so far
+func (e *Exporter) Rows() int {
+	return e.rows
+}
+
+// Response closes the document and returns it as a file download
+func (e *Exporter) Response(filename string) (events.APIGatewayProxyResponse, error) {
+	e.out.WriteString(exportClosings[e.format])
+
+	headers := map[string]string{
+		"Content-Type":                  exportContentTypes[e.format],
+		"Content-Disposition":           `attachment; filename="` + filename + `.` + e.format + `"`,
+		"Access-Control-Allow-Origin":   "*",
+		"Access-Control-Allow-Headers":  "Content-Type,Authorization",
+		"Access-Control-Allow-Methods":  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		"Access-Control-Expose-Headers": "Content-Disposition,X-Export-Rows,X-Export-Truncated",
+		"X-Export-Rows":                 strconv.Itoa(e.rows),
+		"X-Export-Truncated":            strconv.FormatBool(e.Truncated),
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       e.out.String(),
+	}, nil
+}
+
+// exportValue dereferences pointers so JSON properties hold plain values
+func exportValue(value any) any {
+	switch v := value.(type) {
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *int:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *float64:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.Format(time.RFC3339)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return value
+}
+
+// exportText formats a value for CSV cells and KML data. Lists are joined with ";",
+// matching the bulk import format.
+func exportText(value any) string {
+	switch v := exportValue(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ";")
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+}
+
+// csvText formats a value for a CSV cell. Text starting with a character spreadsheets
+// read as a formula is prefixed with ' so opening the export can't run it.
+func csvText(value any) string {
+	text := exportText(value)
+	switch exportValue(value).(type) {
+	case string, []string:
+		if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+			return "'" + text
+		}
+	}
+	return text
+}
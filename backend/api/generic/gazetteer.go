@@ -0,0 +1,157 @@
+package generic
+
+import (
+	"context"
+	"embed"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed gazetteer/cities.csv
+var gazetteerFiles embed.FS
+
+// GazetteerCity is a city the offline gazetteer knows, at its centre
+type GazetteerCity struct {
+	Name        string
+	RegionCode  string
+	CountryCode string
+	Lat         float64
+	Lng         float64
+	Aliases     []string
+}
+
+// Gazetteer is an offline Geocoder over a fixed list of cities. It works to city level:
+// Geocode returns the city centre, and ReverseGeocode the nearest city within
+// ReverseGeocodeRadiusKm, without a postal code.
+type Gazetteer struct {
+	cities []GazetteerCity
+	byName map[string][]int
+}
+
+var (
+	defaultGazetteer    *Gazetteer
+	defaultGazetteerErr error
+	loadGazetteer       sync.Once
+)
+
+// DefaultGazetteer returns the gazetteer built from the embedded city list, loading it
+// the first time it is used
+func DefaultGazetteer() (*Gazetteer, error) {
+	loadGazetteer.Do(func() {
+		f, err := gazetteerFiles.Open("gazetteer/cities.csv")
+		if err != nil {
+			defaultGazetteerErr = err
+			return
+		}
+		defer f.Close()
+		defaultGazetteer, defaultGazetteerErr = LoadGazetteer(f)
+	})
+	return defaultGazetteer, defaultGazetteerErr
+}
+
+// LoadGazetteer reads a city list with the columns city, region_code, country_code,
+// latitude, longitude and aliases (separated with ;)
+func LoadGazetteer(r io.Reader) (*Gazetteer, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("gazetteer is empty")
+	}
+
+	g := &Gazetteer{byName: map[string][]int{}}
+	for i, record := range records[1:] {
+		if len(record) != 6 {
+			return nil, errors.New("gazetteer line " + strconv.Itoa(i+2) + " should have 6 columns")
+		}
+		lat, latErr := strconv.ParseFloat(record[3], 64)
+		lng, lngErr := strconv.ParseFloat(record[4], 64)
+		if latErr != nil || lngErr != nil {
+			return nil, errors.New("gazetteer line " + strconv.Itoa(i+2) + " has invalid coordinates")
+		}
+
+		city := GazetteerCity{Name: record[0], RegionCode: record[1], CountryCode: record[2], Lat: lat, Lng: lng}
+		if record[5] != "" {
+			city.Aliases = strings.Split(record[5], ";")
+		}
+		g.Add(city)
+	}
+	return g, nil
+}
+
+// Add puts a city in the gazetteer, findable by its name and aliases
+func (g *Gazetteer) Add(city GazetteerCity) {
+	index := len(g.cities)
+	g.cities = append(g.cities, city)
+	seen := map[string]bool{}
+	for _, name := range append([]string{city.Name}, city.Aliases...) {
+		key := PlaceKey(name)
+		if !seen[key] {
+			seen[key] = true
+			g.byName[key] = append(g.byName[key], index)
+		}
+	}
+}
+
+func (g *Gazetteer) Geocode(ctx context.Context, place Place) (Place, error) {
+	country := CountryCode(place.Country)
+	var regionCode string
+	if r, ok := findRegion(place.ProvinceOrState, country); ok {
+		regionCode, country = r.code, r.country
+	}
+
+	var candidates []GazetteerCity
+	for _, index := range g.byName[PlaceKey(place.City)] {
+		city := g.cities[index]
+		if country != "" && city.CountryCode != country {
+			continue
+		}
+		if regionCode != "" && city.RegionCode != regionCode {
+			continue
+		}
+		candidates = append(candidates, city)
+	}
+
+	switch {
+	case len(candidates) == 0:
+		return Place{}, ErrPlaceNotFound
+	case len(candidates) > 1 && !place.Located():
+		return Place{}, ErrAmbiguousPlace
+	}
+
+	best := candidates[0]
+	for _, city := range candidates[1:] {
+		if distanceKm(place.Lat, place.Lng, city.Lat, city.Lng) < distanceKm(place.Lat, place.Lng, best.Lat, best.Lng) {
+			best = city
+		}
+	}
+	return best.place(), nil
+}
+
+func (g *Gazetteer) ReverseGeocode(ctx context.Context, lat, lng float64) (Place, error) {
+	var nearest *GazetteerCity
+	nearestKm := float64(ReverseGeocodeRadiusKm)
+	for i := range g.cities {
+		if km := distanceKm(lat, lng, g.cities[i].Lat, g.cities[i].Lng); km <= nearestKm {
+			nearest, nearestKm = &g.cities[i], km
+		}
+	}
+	if nearest == nil {
+		return Place{}, ErrPlaceNotFound
+	}
+	return nearest.place(), nil
+}
+
+// place is the city with its province or state and country spelled out
+func (c GazetteerCity) place() Place {
+	place := Place{City: c.Name, ProvinceOrState: c.RegionCode, Country: countries[c.CountryCode].name, Lat: c.Lat, Lng: c.Lng}
+	if r, ok := findRegion(c.RegionCode, c.CountryCode); ok {
+		place.ProvinceOrState = r.name
+	}
+	return place
+}
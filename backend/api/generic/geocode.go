@@ -0,0 +1,360 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Geocoders selected with GEOCODER
+const GeocoderGazetteer = "gazetteer"
+
+// A city the client names must be within MaxPlaceMismatchKm of the coordinates they
+// send, and ReverseGeocode only matches a city within ReverseGeocodeRadiusKm
+const (
+	MaxPlaceMismatchKm     = 50
+	ReverseGeocodeRadiusKm = 40
+)
+
+var (
+	// ErrPlaceNotFound is returned by a Geocoder that has no match
+	ErrPlaceNotFound = errors.New("place not found")
+	// ErrAmbiguousPlace is returned by Geocode when a city name matches more than one
+	// place and nothing narrows it down
+	ErrAmbiguousPlace = errors.New("place is ambiguous")
+)
+
+// Geocoder turns addresses into coordinates and back. Both return ErrPlaceNotFound
+// when nothing matches.
+type Geocoder interface {
+	// Geocode finds the place's city by name and returns it with canonical names and
+	// coordinates. Coordinates on the place, if any, pick between cities of the same name.
+	Geocode(ctx context.Context, place Place) (Place, error)
+	// ReverseGeocode returns the city, province or state, country and, where the
+	// geocoder knows it, postal code at a point
+	ReverseGeocode(ctx context.Context, lat, lng float64) (Place, error)
+}
+
+// NewGeocoder returns the geocoder named by GEOCODER, the offline gazetteer by default
+func NewGeocoder() (Geocoder, error) {
+	switch geocoder := os.Getenv("GEOCODER"); geocoder {
+	case "", GeocoderGazetteer:
+		return DefaultGazetteer()
+	default:
+		return nil, errors.New("unknown geocoder " + geocoder)
+	}
+}
+
+// Located reports whether the place has coordinates. Clients never send 0,0, which is
+// in the Atlantic.
+func (p Place) Located() bool {
+	return p.Lat != 0 || p.Lng != 0
+}
+
+type region struct {
+	country string
+	code    string
+	name    string
+	aliases []string
+}
+
+var regions = []region{
+	{"CA", "AB", "Alberta", []string{"Alta"}},
+	{"CA", "BC", "British Columbia", nil},
+	{"CA", "MB", "Manitoba", []string{"Man"}},
+	{"CA", "NB", "New Brunswick", nil},
+	{"CA", "NL", "Newfoundland and Labrador", []string{"Newfoundland", "Nfld"}},
+	{"CA", "NS", "Nova Scotia", nil},
+	{"CA", "NT", "Northwest Territories", []string{"NWT"}},
+	{"CA", "NU", "Nunavut", nil},
+	{"CA", "ON", "Ontario", []string{"Ont"}},
+	{"CA", "PE", "Prince Edward Island", []string{"PEI"}},
+	{"CA", "QC", "Quebec", []string{"Que", "PQ"}},
+	{"CA", "SK", "Saskatchewan", []string{"Sask"}},
+	{"CA", "YT", "Yukon", []string{"Yukon Territory"}},
+	{"US", "AL", "Alabama", nil},
+	{"US", "AK", "Alaska", nil},
+	{"US", "AZ", "Arizona", nil},
+	{"US", "AR", "Arkansas", nil},
+	{"US", "CA", "California", []string{"Calif"}},
+	{"US", "CO", "Colorado", nil},
+	{"US", "CT", "Connecticut", nil},
+	{"US", "DE", "Delaware", nil},
+	{"US", "DC", "District of Columbia", []string{"Washington DC"}},
+	{"US", "FL", "Florida", nil},
+	{"US", "GA", "Georgia", nil},
+	{"US", "HI", "Hawaii", nil},
+	{"US", "ID", "Idaho", nil},
+	{"US", "IL", "Illinois", nil},
+	{"US", "IN", "Indiana", nil},
+	{"US", "IA", "Iowa", nil},
+	{"US", "KS", "Kansas", nil},
+	{"US", "KY", "Kentucky", nil},
+	{"US", "LA", "Louisiana", nil},
+	{"US", "ME", "Maine", nil},
+	{"US", "MD", "Maryland", nil},
+	{"US", "MA", "Massachusetts", nil},
+	{"US", "MI", "Michigan", nil},
+	{"US", "MN", "Minnesota", nil},
+	{"US", "MS", "Mississippi", nil},
+	{"US", "MO", "Missouri", nil},
+	{"US", "MT", "Montana", nil},
+	{"US", "NE", "Nebraska", nil},
+	{"US", "NV", "Nevada", nil},
+	{"US", "NH", "New Hampshire", nil},
+	{"US", "NJ", "New Jersey", nil},
+	{"US", "NM", "New Mexico", nil},
+	{"US", "NY", "New York", nil},
+	{"US", "NC", "North Carolina", nil},
+	{"US", "ND", "North Dakota", nil},
+	{"US", "OH", "Ohio", nil},
+	{"US", "OK", "Oklahoma", nil},
+	{"US", "OR", "Oregon", nil},
+	{"US", "PA", "Pennsylvania", nil},
+	{"US", "RI", "Rhode Island", nil},
+	{"US", "SC", "South Carolina", nil},
+	{"US", "SD", "South Dakota", nil},
+	{"US", "TN", "Tennessee", nil},
+	{"US", "TX", "Texas", nil},
+	{"US", "UT", "Utah", nil},
+	{"US", "VT", "Vermont", nil},
+	{"US", "VA", "Virginia", nil},
+	{"US", "WA", "Washington", nil},
+	{"US", "WV", "West Virginia", nil},
+	{"US", "WI", "Wisconsin", nil},
+	{"US", "WY", "Wyoming", nil},
+}
+
+var countries = map[string]struct {
+	name    string
+	aliases []string
+}{
+	"CA": {"Canada", []string{"CAN"}},
+	"US": {"United States", []string{"USA", "United States of America", "America"}},
+}
+
+var postalCodes = map[string]*regexp.Regexp{
+	"CA": regexp.MustCompile(`^([A-Z][0-9][A-Z]) ?([0-9][A-Z][0-9])$`),
+	"US": regexp.MustCompile(`^([0-9]{5})(?:-?([0-9]{4}))?$`),
+}
+
+// PlaceKey folds a place name for matching: lower case, no accents, periods or
+// apostrophes, hyphens as spaces and single spaces, so "St. John's", "st johns",
+// "Montréal" and "MONTREAL", and "Trois-Rivières" and "trois rivieres" compare equal
+func PlaceKey(name string) string {
+	folded, _, err := transform.String(foldAccents, name)
+	if err == nil {
+		name = folded
+	}
+	name = strings.NewReplacer(".", "", "'", "", "’", "", "-", " ").Replace(strings.ToLower(name))
+	return strings.Join(strings.FieldsFunc(name, unicode.IsSpace), " ")
+}
+
+// foldAccents strips accents by decomposing letters and dropping the combining marks
+var foldAccents = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// CountryCode returns the ISO 3166-1 code for a country name, code or alias, or "" for
+// a country it doesn't know
+func CountryCode(country string) string {
+	key := PlaceKey(country)
+	for code, c := range countries {
+		if key == PlaceKey(code) || key == PlaceKey(c.name) {
+			return code
+		}
+		for _, alias := range c.aliases {
+			if key == PlaceKey(alias) {
+				return code
+			}
+		}
+	}
+	return ""
+}
+
+// SubdivisionCode returns the ISO 3166-2 code ("CA-AB") for a province or state name,
+// code or alias, in the given country if it is known, or "" for one it doesn't know
+func SubdivisionCode(region, country string) string {
+	if r, ok := findRegion(region, country); ok {
+		return r.country + "-" + r.code
+	}
+	return ""
+}
+
+// findRegion returns the province or state named by a name, code or alias, in the
+// given country if it is known
+func findRegion(name, country string) (region, bool) {
+	key := PlaceKey(name)
+	for _, r := range regions {
+		if country != "" && r.country != country {
+			continue
+		}
+		if key == PlaceKey(r.code) || key == PlaceKey(r.name) {
+			return r, true
+		}
+		for _, alias := range r.aliases {
+			if key == PlaceKey(alias) {
+				return r, true
+			}
+		}
+	}
+	return region{}, false
+}
+
+// NormalizePlace tidies the names a client sent: "Calgary, AB" in the city is split
+// out, provinces, states and countries are spelled out ("AB" becomes "Alberta"), and
+// Canadian and US postal codes are formatted. Names it doesn't know are only trimmed.
+func NormalizePlace(place Place) Place {
+	place.StreetAddress = strings.TrimSpace(place.StreetAddress)
+	place.City = strings.Join(strings.Fields(place.City), " ")
+	place.ProvinceOrState = strings.Join(strings.Fields(place.ProvinceOrState), " ")
+	place.Country = strings.Join(strings.Fields(place.Country), " ")
+
+	if parts := strings.Split(place.City, ","); len(parts) > 1 {
+		place.City = strings.TrimSpace(parts[0])
+		if place.ProvinceOrState == "" {
+			place.ProvinceOrState = strings.TrimSpace(parts[1])
+		}
+		if len(parts) > 2 && place.Country == "" {
+			place.Country = strings.TrimSpace(parts[2])
+		}
+	}
+
+	code := CountryCode(place.Country)
+	if code != "" {
+		place.Country = countries[code].name
+	}
+	if r, ok := findRegion(place.ProvinceOrState, code); ok {
+		place.ProvinceOrState = r.name
+		code = r.country
+		place.Country = countries[code].name
+	}
+
+	place.PostalCode = formatPostalCode(place.PostalCode, code)
+	return place
+}
+
+// formatPostalCode upper cases a postal code, and formats it as "T2P 1J9" in Canada and
+// "12345" or "12345-6789" in the US. An empty code becomes nil.
+func formatPostalCode(postalCode *string, country string) *string {
+	if postalCode == nil {
+		return nil
+	}
+	postal := strings.ToUpper(strings.TrimSpace(*postalCode))
+	if postal == "" {
+		return nil
+	}
+	if m := postalCodes[country]; m != nil && m.MatchString(postal) {
+		groups := m.FindStringSubmatch(postal)
+		switch {
+		case country == "CA":
+			postal = groups[1] + " " + groups[2]
+		case groups[2] != "":
+			postal = groups[1] + "-" + groups[2]
+		default:
+			postal = groups[1]
+		}
+	}
+	return &postal
+}
+
+// checkPostalCode formats the place's postal code for its country, and rejects one that
+// can't be from there
+func checkPostalCode(place Place) (Place, error) {
+	country := CountryCode(place.Country)
+	place.PostalCode = formatPostalCode(place.PostalCode, country)
+	if m := postalCodes[country]; m != nil && place.PostalCode != nil && !m.MatchString(*place.PostalCode) {
+		return place, &ValidationError{Message: "postal code is not valid for " + place.Country, Field: "postalCode"}
+	}
+	return place, nil
+}
+
+// ResolveCity normalizes the place's names and, when the geocoder knows the city,
+// replaces them with its canonical names. A city it doesn't know is kept as typed.
+// It returns a *ValidationError for a city the geocoder can't tell apart from another
+// of the same name, and for a postal code that doesn't fit the country.
+func ResolveCity(ctx context.Context, geocoder Geocoder, place Place) (Place, error) {
+	place = NormalizePlace(place)
+	if place.City == "" {
+		return checkPostalCode(place)
+	}
+
+	match, err := geocoder.Geocode(ctx, place)
+	switch {
+	case errors.Is(err, ErrPlaceNotFound):
+		return checkPostalCode(place)
+	case errors.Is(err, ErrAmbiguousPlace):
+		return place, &ValidationError{Message: "there is more than one " + place.City + ", add the province or state", Field: "provinceOrState"}
+	case err != nil:
+		return place, Internal("failed to geocode city", err)
+	}
+
+	if place.Located() {
+		if km := distanceKm(place.Lat, place.Lng, match.Lat, match.Lng); km > MaxPlaceMismatchKm {
+			return place, &ValidationError{
+				Message: match.City + " is " + strconv.Itoa(int(km)) + " km from locationCoords",
+				Field:   "city",
+			}
+		}
+	} else {
+		place.Lat, place.Lng = match.Lat, match.Lng
+	}
+	place.City, place.ProvinceOrState, place.Country = match.City, match.ProvinceOrState, match.Country
+	return checkPostalCode(place)
+}
+
+// ResolvePlace makes a client's address consistent before it is stored. The names are
+// normalized, a missing city, province, country or postal code is filled in from the
+// coordinates, and missing coordinates are taken from the city. Errors the client can
+// fix are *ValidationError, including an address the geocoder can't place at all.
+func ResolvePlace(ctx context.Context, place Place) (Place, error) {
+	geocoder, err := NewGeocoder()
+	if err != nil {
+		return place, Internal("geocoding is not configured", err)
+	}
+
+	place, err = ResolveCity(ctx, geocoder, place)
+	if err != nil {
+		return place, err
+	}
+
+	if place.Located() && (place.City == "" || place.ProvinceOrState == "" || place.Country == "" || place.PostalCode == nil) {
+		found, err := geocoder.ReverseGeocode(ctx, place.Lat, place.Lng)
+		if err != nil && !errors.Is(err, ErrPlaceNotFound) {
+			return place, Internal("failed to reverse geocode location", err)
+		}
+		if err == nil {
+			if place.City == "" {
+				place.City = found.City
+			}
+			if place.ProvinceOrState == "" {
+				place.ProvinceOrState = found.ProvinceOrState
+			}
+			if place.Country == "" {
+				place.Country = found.Country
+			}
+			if place.PostalCode == nil {
+				place.PostalCode = found.PostalCode
+			}
+		}
+	}
+
+	if !place.Located() {
+		return place, &ValidationError{Message: "couldn't find this address, send locationCoords", Field: "locationCoords"}
+	}
+	return checkPostalCode(place)
+}
+
+// distanceKm is the great-circle distance between two points on a sphere, like NearestFirst
+func distanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	cos := math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Cos((lng1-lng2)*rad) + math.Sin(lat1*rad)*math.Sin(lat2*rad)
+	return 6371 * math.Acos(math.Min(1, cos))
+}
@@ -0,0 +1,86 @@
+package generic
+
+import "testing"
+
+func TestNormalizePlace(t *testing.T) {
+	postal := func(code string) *string { return &code }
+	tests := []struct {
+		name  string
+		place Place
+		want  Place
+	}{
+		{
+			"province in the city",
+			Place{City: "Calgary, AB"},
+			Place{City: "Calgary", ProvinceOrState: "Alberta", Country: "Canada"},
+		},
+		{
+			"province and country in the city",
+			Place{City: " Red  Deer , Alberta, CA "},
+			Place{City: "Red Deer", ProvinceOrState: "Alberta", Country: "Canada"},
+		},
+		{
+			"own province wins over the city's",
+			Place{City: "Calgary, AB", ProvinceOrState: "BC"},
+			Place{City: "Calgary", ProvinceOrState: "British Columbia", Country: "Canada"},
+		},
+		{
+			"country code spelled out",
+			Place{City: "Denver", ProvinceOrState: "CO", Country: "us"},
+			Place{City: "Denver", ProvinceOrState: "Colorado", Country: "United States"},
+		},
+		{
+			"Canadian postal code",
+			Place{City: "Calgary", ProvinceOrState: "Alberta", Country: "Canada", PostalCode: postal("t2p1j9")},
+			Place{City: "Calgary", ProvinceOrState: "Alberta", Country: "Canada", PostalCode: postal("T2P 1J9")},
+		},
+		{
+			"US ZIP+4",
+			Place{City: "Denver", ProvinceOrState: "Colorado", Country: "US", PostalCode: postal("802021234")},
+			Place{City: "Denver", ProvinceOrState: "Colorado", Country: "United States", PostalCode: postal("80202-1234")},
+		},
+		{
+			"blank postal code",
+			Place{City: "Calgary", ProvinceOrState: "AB", PostalCode: postal("  ")},
+			Place{City: "Calgary", ProvinceOrState: "Alberta", Country: "Canada"},
+		},
+		{
+			"unknown country kept",
+			Place{City: "Springfield", ProvinceOrState: "Nowhere", Country: "Atlantis"},
+			Place{City: "Springfield", ProvinceOrState: "Nowhere", Country: "Atlantis"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormalizePlace(tt.place)
+			if got.City != tt.want.City || got.ProvinceOrState != tt.want.ProvinceOrState || got.Country != tt.want.Country {
+				t.Errorf("got %q, %q, %q, want %q, %q, %q",
+					got.City, got.ProvinceOrState, got.Country, tt.want.City, tt.want.ProvinceOrState, tt.want.Country)
+			}
+			if (got.PostalCode == nil) != (tt.want.PostalCode == nil) ||
+				got.PostalCode != nil && *got.PostalCode != *tt.want.PostalCode {
+				t.Errorf("got postal code %v, want %v", deref(got.PostalCode), deref(tt.want.PostalCode))
+			}
+		})
+	}
+}
+
+func TestPlaceKey(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"St. John's", "st johns"},
+		{"Montréal", "MONTREAL"},
+		{"Trois-Rivières", "trois  rivieres"},
+	}
+	for _, tt := range tests {
+		if PlaceKey(tt.a) != PlaceKey(tt.b) {
+			t.Errorf("%q folds to %q but %q folds to %q", tt.a, PlaceKey(tt.a), tt.b, PlaceKey(tt.b))
+		}
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
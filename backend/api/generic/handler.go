@@ -0,0 +1,53 @@
+package generic
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/otel/codes"
+)
+
+type Handler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Start runs handler as a Lambda. Every invocation gets a root span and a JSON
+// access log line with request ID, route, user UUID, status and latency.
+func Start(service string, handler Handler) {
+	flush := initTracing(service)
+
+	lambda.Start(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		route := request.Resource
+		if route == "" {
+			route = request.Path
+		}
+		route = request.HTTPMethod + " " + route
+
+		ctx = withRequestInfo(ctx, &requestInfo{
+			requestID: request.RequestContext.RequestID,
+			route:     route,
+			started:   time.Now(),
+		})
+		ctx, span := StartSpan(ctx, route,
+			attrRequestID.String(request.RequestContext.RequestID),
+			attrRoute.String(route),
+		)
+
+		response, err := handler(ctx, request)
+
+		span.SetAttributes(attrStatus.Int(response.StatusCode))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if err != nil || response.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(response.StatusCode))
+		}
+		span.End()
+
+		logRequest(ctx, response.StatusCode)
+		flush(ctx)
+
+		return response, err
+	})
+}
@@ -0,0 +1,186 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Listing types stored in listing_history.listing_type
+const (
+	ListingTypeLost     = "lost"
+	ListingTypeSighting = "sighting"
+)
+
+// History actions
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionFound   = "found"
+	ActionUnfound = "unfound"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// DeleteGracePeriod is how long a soft-deleted listing can be restored before it is purged
+const DeleteGracePeriod = 30 * 24 * time.Hour
+
+// Execer is satisfied by both *pgx.Conn and pgx.Tx
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Querier is satisfied by both *pgx.Conn and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// Changes maps a column name to its old and new value
+type Changes map[string]FieldChange
+
+type HistoryEntry struct {
+	ID        int       `json:"id"`
+	Action    string    `json:"action"`
+	Actor     *string   `json:"actor,omitempty"`
+	Changes   Changes   `json:"changes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Diff returns the columns of after whose value differs from before. Values are
+// compared by their JSON encoding so driver types ([]any vs []string, int32 vs int)
+// don't show up as changes.
+func Diff(before, after map[string]any) Changes {
+	changes := Changes{}
+	for column, newValue := range after {
+		oldValue := before[column]
+		if sameValue(oldValue, newValue) {
+			continue
+		}
+		changes[column] = FieldChange{Old: normalizeValue(oldValue), New: normalizeValue(newValue)}
+	}
+	return changes
+}
+
+func sameValue(a, b any) bool {
+	aJSON, aErr := json.Marshal(normalizeValue(a))
+	bJSON, bErr := json.Marshal(normalizeValue(b))
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
+
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC()
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC()
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return value
+}
+
+// RowValues reads the given columns of a single row as a column -> value map
+func RowValues(ctx context.Context, conn Querier, query string, columns []string, args ...any) (map[string]any, error) {
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, pgx.ErrNoRows
+	}
+	values, err := rows.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]any, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row, rows.Err()
+}
+
+// RecordHistory appends an entry to listing_history. actor is the user UUID making the
+// change, or empty for system jobs.
+func RecordHistory(ctx context.Context, db Execer, listingType string, listingID any, actor string, action string, changes Changes) error {
+	var actorUUID *string
+	if actor != "" {
+		actorUUID = &actor
+	}
+
+	var changesJSON []byte
+	if len(changes) > 0 {
+		var err error
+		if changesJSON, err = json.Marshal(changes); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO listing_history (listing_type, listing_id, actor, action, changes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := db.Exec(ctx, query, listingType, listingID, actorUUID, action, changesJSON, time.Now())
+	return err
+}
+
+// ListHistory returns a listing's history, oldest first
+func ListHistory(ctx context.Context, conn *pgx.Conn, listingType string, listingID string) ([]HistoryEntry, error) {
+	query := `
+		SELECT id, action, actor, changes, created_at
+		FROM listing_history
+		WHERE listing_type = $1 AND listing_id = $2
+		ORDER BY created_at, id
+	`
+	rows, err := conn.Query(ctx, query, listingType, listingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		var entry HistoryEntry
+		var changesJSON []byte
+		if err := rows.Scan(&entry.ID, &entry.Action, &entry.Actor, &changesJSON, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(changesJSON) > 0 {
+			if err := json.Unmarshal(changesJSON, &entry.Changes); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// IsModerator reports whether the user may view and manage listings they don't own
+func IsModerator(ctx context.Context, conn *pgx.Conn, userUUID string) (bool, error) {
+	var isModerator bool
+	err := conn.QueryRow(ctx, `SELECT is_moderator FROM users WHERE user_uuid = $1`, userUUID).Scan(&isModerator)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return isModerator, err
+}
@@ -0,0 +1,372 @@
+package generic
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxImportRows caps how many rows one import request can carry
+const MaxImportRows = 500
+
+// ImportRowError reports why one row was rejected. Rows are numbered from 1, not counting a CSV header.
+type ImportRowError struct {
+	Row     int              `json:"row"`
+	Message string           `json:"message,omitempty"`
+	Fields  ValidationErrors `json:"fields,omitempty"`
+}
+
+// ImportDuplicate reports a row that was skipped because it matches an existing listing
+// or an earlier row of the same import
+type ImportDuplicate struct {
+	Row        int  `json:"row"`
+	ExistingID *int `json:"existing_id,omitempty"`
+	SameAsRow  *int `json:"same_as_row,omitempty"`
+}
+
+// DecodeImportRows splits an import body into one JSON object per row so each can be
+// unmarshalled into the listing's request type. JSON bodies are an array of request
+// objects. CSV bodies have a header row of request field names; lat/lng columns become
+// locationCoords and listColumns (e.g. color, breed) are split on ";".
+func DecodeImportRows(body, contentType string, listColumns ...string) ([]json.RawMessage, error) {
+	if strings.Contains(strings.ToLower(contentType), "csv") {
+		return decodeCSVRows(body, listColumns)
+	}
+
+	var rows []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &rows); err != nil {
+		return nil, &ValidationError{Message: "body must be a JSON array of rows or a CSV file with Content-Type text/csv"}
+	}
+	return rows, nil
+}
+
+func decodeCSVRows(body string, listColumns []string) ([]json.RawMessage, error) {
+	reader := csv.NewReader(strings.NewReader(body))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, &ValidationError{Message: "CSV body must start with a header row"}
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var rows []json.RawMessage
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, &ValidationError{Message: "invalid CSV on line " + strconv.Itoa(parseErr.Line) + ": " + parseErr.Err.Error()}
+			}
+			return nil, &ValidationError{Message: "invalid CSV body"}
+		}
+
+		row := map[string]any{}
+		coords := map[string]any{}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if i >= len(header) || value == "" {
+				continue
+			}
+
+			column := header[i]
+			switch {
+			case column == "lat" || column == "lng":
+				// Left as a string if it isn't a number so validation reports the row
+				if number, err := strconv.ParseFloat(value, 64); err == nil {
+					coords[column] = number
+				} else {
+					coords[column] = value
+				}
+			case containsString(listColumns, column):
+				var items []string
+				for _, item := range strings.Split(value, ";") {
+					if item = strings.TrimSpace(item); item != "" {
+						items = append(items, item)
+					}
+				}
+				row[column] = items
+			default:
+				row[column] = value
+			}
+		}
+		if len(coords) > 0 {
+			row["locationCoords"] = coords
+		}
+
+		raw, _ := json.Marshal(row)
+		rows = append(rows, raw)
+	}
+	return rows, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Place is an address to resolve to a locations row
+type Place struct {
+	StreetAddress   string
+	PostalCode      *string
+	Lat             float64
+	Lng             float64
+	City            string
+	ProvinceOrState string
+	Country         string
+}
+
+// ResolveLocations returns a location ID for each place, creating the missing cities and
+// locations with one statement each instead of a lookup per row. Pass places from
+// ResolvePlace so new cities get their canonical names.
+func ResolveLocations(ctx context.Context, db Querier, places []Place) ([]int, error) {
+	if len(places) == 0 {
+		return nil, nil
+	}
+
+	// Cities are matched on their place key, like GetOrCreateCity
+	var cityKeys, cityNames, provinces, countries []string
+	var countryCodes, subdivisionCodes []*string
+	seenCities := map[string]bool{}
+	for _, place := range places {
+		place = NormalizePlace(place)
+		key := CityKey(place)
+		if !seenCities[key] {
+			seenCities[key] = true
+			countryCode, subdivisionCode := PlaceCodes(place)
+			cityKeys = append(cityKeys, key)
+			cityNames = append(cityNames, place.City)
+			provinces = append(provinces, place.ProvinceOrState)
+			countries = append(countries, place.Country)
+			countryCodes = append(countryCodes, countryCode)
+			subdivisionCodes = append(subdivisionCodes, subdivisionCode)
+		}
+	}
+
+	cityIDs := map[string]int{}
+	rows, err := db.Query(ctx, `
+		WITH wanted AS (
+			SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[])
+				AS w(place_key, city_name, province_or_state, country, country_code, subdivision_code)
+		), existing AS (
+			SELECT w.place_key, COALESCE(c.id, a.city_id) AS id
+			FROM wanted w
+			LEFT JOIN cities c ON c.place_key = w.place_key
+			LEFT JOIN city_aliases a ON a.alias_key = w.place_key
+		), inserted AS (
+			INSERT INTO cities (city_name, province_or_state, country, country_code, subdivision_code, place_key)
+			SELECT w.city_name, w.province_or_state, w.country, w.country_code, w.subdivision_code, w.place_key
+			FROM wanted w JOIN existing e ON e.place_key = w.place_key AND e.id IS NULL
+			ON CONFLICT (place_key) DO UPDATE SET place_key = EXCLUDED.place_key
+			RETURNING place_key, id
+		)
+		SELECT place_key, id FROM existing WHERE id IS NOT NULL
+		UNION ALL
+		SELECT place_key, id FROM inserted
+	`, cityKeys, cityNames, provinces, countries, countryCodes, subdivisionCodes)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var key string
+		var id int
+		if err := rows.Scan(&key, &id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		cityIDs[key] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Places that are the same location by sameLocation share one wanted row
+	var streets []string
+	var postalCodes []*string
+	var lats, lngs []float64
+	var locationCities []int
+	wantedFor := make([]int, len(places))
+	for i, place := range places {
+		cityID := cityIDs[CityKey(place)]
+		wantedFor[i] = -1
+		for w := range streets {
+			if locationCities[w] == cityID && strings.EqualFold(strings.TrimSpace(streets[w]), strings.TrimSpace(place.StreetAddress)) &&
+				distanceKm(lats[w], lngs[w], place.Lat, place.Lng)*1000 <= LocationToleranceM {
+				wantedFor[i] = w
+				break
+			}
+		}
+		if wantedFor[i] == -1 {
+			wantedFor[i] = len(streets)
+			streets = append(streets, place.StreetAddress)
+			postalCodes = append(postalCodes, place.PostalCode)
+			lats = append(lats, place.Lat)
+			lngs = append(lngs, place.Lng)
+			locationCities = append(locationCities, cityID)
+		}
+	}
+
+	point := GeogPoint("w.latitude", "w.longitude")
+	rows, err = db.Query(ctx, `
+		WITH wanted AS (
+			SELECT * FROM unnest($1::text[], $2::text[], $3::float8[], $4::float8[], $5::int[]) WITH ORDINALITY
+				AS w(street_address, postal_code, latitude, longitude, city_id, n)
+		), matched AS (
+			SELECT w.n, near.id
+			FROM wanted w
+			LEFT JOIN LATERAL (
+				SELECT l.id FROM locations l
+				WHERE `+sameLocation("w.street_address", "w.city_id", point)+`
+				ORDER BY `+NearestFirst("l.geog", point)+`, l.id
+				LIMIT 1
+			) near ON true
+		), inserted AS (
+			INSERT INTO locations (street_address, postal_code, latitude, longitude, city_id, created_at)
+			SELECT w.street_address, w.postal_code, w.latitude, w.longitude, w.city_id, $6::timestamptz
+			FROM wanted w JOIN matched m ON m.n = w.n AND m.id IS NULL
+			RETURNING id, street_address, latitude, longitude, city_id
+		)
+		SELECT w.n, m.id
+		FROM wanted w JOIN matched m ON m.n = w.n AND m.id IS NOT NULL
+		UNION ALL
+		SELECT w.n, i.id
+		FROM inserted i JOIN wanted w ON w.street_address = i.street_address
+			AND w.latitude = i.latitude AND w.longitude = i.longitude AND w.city_id = i.city_id
+	`, streets, postalCodes, lats, lngs, locationCities, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	locationIDs := make([]int, len(streets))
+	for rows.Next() {
+		var n, id int
+		if err := rows.Scan(&n, &id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		locationIDs[n-1] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(places))
+	for i, w := range wantedFor {
+		ids[i] = locationIDs[w]
+	}
+	return ids, nil
+}
+
+// ListingKey identifies a listing for duplicate detection. Two listings are the same
+// animal if they share a pet ID, or the same animal type, location and day.
+type ListingKey struct {
+	AnimalType string
+	LocationID int
+	Date       time.Time
+	PetID      *string
+}
+
+func (k ListingKey) String() string {
+	if k.PetID != nil && *k.PetID != "" {
+		return k.AnimalType + "|pet|" + *k.PetID
+	}
+	return k.AnimalType + "|" + strconv.Itoa(k.LocationID) + "|" + k.Date.Format(time.DateOnly)
+}
+
+// FindDuplicates returns, for each key, the ID of a live listing of the given type that
+// matches it, or 0 when there is none
+func FindDuplicates(ctx context.Context, db Querier, listingType string, keys []ListingKey) ([]int, error) {
+	table, locationColumn, dateColumn := "lost_pet_listing", "last_seen_location", "date_lost"
+	if listingType == ListingTypeSighting {
+		table, locationColumn, dateColumn = "sighting_listing", "spotted_location", "date_spotted"
+	}
+
+	animalTypes := make([]string, len(keys))
+	locations := make([]int, len(keys))
+	days := make([]string, len(keys))
+	petIDs := make([]*string, len(keys))
+	for i, key := range keys {
+		animalTypes[i] = key.AnimalType
+		locations[i] = key.LocationID
+		days[i] = key.Date.Format(time.DateOnly)
+		if key.PetID != nil && *key.PetID != "" {
+			petIDs[i] = key.PetID
+		}
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT DISTINCT ON (k.idx) k.idx, t.id
+		FROM unnest($1::text[], $2::int[], $3::date[], $4::text[]) WITH ORDINALITY
+			AS k(animal_type, location_id, day, pet_id, idx)
+		JOIN `+table+` t ON t.deleted_at IS NULL AND t.animal_type = k.animal_type AND (
+			(k.pet_id IS NOT NULL AND t.pet_id = k.pet_id) OR
+			(t.`+locationColumn+` = k.location_id AND t.`+dateColumn+`::date = k.day)
+		)
+		ORDER BY k.idx, t.id
+	`, animalTypes, locations, days, petIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make([]int, len(keys))
+	for rows.Next() {
+		var idx int64
+		var id int
+		if err := rows.Scan(&idx, &id); err != nil {
+			return nil, err
+		}
+		existing[idx-1] = id
+	}
+	return existing, rows.Err()
+}
+
+// ImportReport is the response body of an import. A dry run reports how many rows
+// would have been imported but has no IDs.
+func ImportReport(total, imported int, ids []int, duplicates []ImportDuplicate, rowErrors []ImportRowError, dryRun bool) Json {
+	if ids == nil {
+		ids = []int{}
+	}
+	if duplicates == nil {
+		duplicates = []ImportDuplicate{}
+	}
+	if rowErrors == nil {
+		rowErrors = []ImportRowError{}
+	}
+	return Json{
+		"rows":       total,
+		"imported":   imported,
+		"ids":        ids,
+		"duplicates": duplicates,
+		"errors":     rowErrors,
+		"dry_run":    dryRun,
+	}
+}
+
+// ImportBody returns the raw request body, decoding it if API Gateway base64-encoded it
+func ImportBody(body string, base64Encoded bool) (string, error) {
+	if !base64Encoded {
+		return body, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return "", &ValidationError{Message: "invalid base64 body"}
+	}
+	return string(decoded), nil
+}
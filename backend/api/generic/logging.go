@@ -0,0 +1,77 @@
+package generic
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+var Logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+type requestInfoKey struct{}
+
+// requestInfo collects fields for the access log line written once the handler returns
+type requestInfo struct {
+	mu        sync.Mutex
+	requestID string
+	route     string
+	userUUID  string
+	started   time.Time
+}
+
+func withRequestInfo(ctx context.Context, info *requestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// SetUser records the caller's user UUID so it shows up in the request log and span
+func SetUser(ctx context.Context, userUUID string) {
+	if info := requestInfoFrom(ctx); info != nil {
+		info.mu.Lock()
+		info.userUUID = userUUID
+		info.mu.Unlock()
+	}
+	SpanFromContext(ctx).SetAttributes(attrUserUUID.String(userUUID))
+}
+
+// Log returns a logger carrying the request ID, route, user UUID and trace ID of ctx
+func Log(ctx context.Context) *slog.Logger {
+	logger := Logger
+	if info := requestInfoFrom(ctx); info != nil {
+		info.mu.Lock()
+		logger = logger.With("request_id", info.requestID, "route", info.route)
+		if info.userUUID != "" {
+			logger = logger.With("user_uuid", info.userUUID)
+		}
+		info.mu.Unlock()
+	}
+	if spanCtx := SpanFromContext(ctx).SpanContext(); spanCtx.HasTraceID() {
+		logger = logger.With("trace_id", spanCtx.TraceID().String())
+	}
+	return logger
+}
+
+func logRequest(ctx context.Context, status int) {
+	info := requestInfoFrom(ctx)
+	if info == nil {
+		return
+	}
+
+	level := slog.LevelInfo
+	if status >= 500 {
+		level = slog.LevelError
+	} else if status >= 400 {
+		level = slog.LevelWarn
+	}
+
+	Log(ctx).Log(ctx, level, "request completed",
+		"status", status,
+		"latency_ms", time.Since(info.started).Milliseconds(),
+	)
+}
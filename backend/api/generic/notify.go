@@ -0,0 +1,493 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	htmltemplate "html/template"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	_ "time/tzdata"
+)
+
+// Notification kinds. Each has templates in templates/email and templates/short and a preference.
+const (
+	NotifySightingNearby = "sighting_nearby"
+	NotifyListingStale   = "listing_stale"
+	NotifyMessage        = "message"
+	NotifyListingDigest  = "listing_digest"
+	NotifyAreaAlert      = "area_alert"
+)
+
+var NotificationKinds = []string{NotifySightingNearby, NotifyListingStale, NotifyMessage, NotifyListingDigest, NotifyAreaAlert}
+
+// Notification channels stored in notifications.channel
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelPush  = "push"
+)
+
+var NotificationChannels = []string{ChannelEmail, ChannelSMS, ChannelPush}
+
+// Urgent notifications go to the user's urgent channels, everything else to their
+// digest channels
+const (
+	UrgencyUrgent = "urgent"
+	UrgencyDigest = "digest"
+)
+
+var kindUrgency = map[string]string{
+	NotifySightingNearby: UrgencyUrgent,
+	NotifyMessage:        UrgencyUrgent,
+	NotifyAreaAlert:      UrgencyUrgent,
+	NotifyListingStale:   UrgencyDigest,
+	NotifyListingDigest:  UrgencyDigest,
+}
+
+// NotificationUrgency returns whether kind is urgent or digest
+func NotificationUrgency(kind string) string {
+	if urgency, ok := kindUrgency[kind]; ok {
+		return urgency
+	}
+	return UrgencyDigest
+}
+
+// Notification statuses stored in notifications.status. Skipped notifications were
+// turned off in the user's preferences by the time they were sent.
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+	NotificationSkipped = "skipped"
+)
+
+// NotificationMaxAttempts is how many times a notification is tried before it is marked failed
+const NotificationMaxAttempts = 5
+
+// NotificationRetention is how long sent, skipped and failed notifications are kept
+const NotificationRetention = 90 * 24 * time.Hour
+
+// NearbySightingRadiusKm is how close a new sighting must be to a lost pet's last seen
+// location for its owner to be told
+const NearbySightingRadiusKm = 5
+
+// Listings grey out as stale ListingStaleAfter after they were posted; owners are
+// warned StaleWarning before that
+const (
+	ListingStaleAfter = 30 * 24 * time.Hour
+	StaleWarning      = 3 * 24 * time.Hour
+)
+
+// UnsubscribeAll is the unsubscribe token kind that turns off every email
+const UnsubscribeAll = "all"
+
+// NotificationPreferences are a user's notification settings. Users without a row get
+// DefaultPreferences. Quiet hours are HH:MM in Timezone; during them SMS and push
+// notifications wait, email does not. PhoneNumber is the verified number SMS goes to;
+// it is only changed through phone verification, so SavePreferences leaves it alone.
+// The listing digest covers DigestCityID and/or DigestRadiusKm around DigestLatitude
+// and DigestLongitude.
+type NotificationPreferences struct {
+	EmailEnabled    bool     `json:"email_enabled"`
+	SightingNearby  bool     `json:"sighting_nearby"`
+	ListingStale    bool     `json:"listing_stale"`
+	Messages        bool     `json:"messages"`
+	AreaAlerts      bool     `json:"area_alerts"`
+	UrgentChannels  []string `json:"urgent_channels"`
+	DigestChannels  []string `json:"digest_channels"`
+	QuietHoursStart *string  `json:"quiet_hours_start"`
+	QuietHoursEnd   *string  `json:"quiet_hours_end"`
+	Timezone        string   `json:"timezone"`
+	PhoneNumber     *string  `json:"phone_number"`
+	DigestFrequency string   `json:"digest_frequency"`
+	DigestCityID    *int     `json:"digest_city_id"`
+	DigestLatitude  *float64 `json:"digest_latitude"`
+	DigestLongitude *float64 `json:"digest_longitude"`
+	DigestRadiusKm  *float64 `json:"digest_radius_km"`
+}
+
+var DefaultPreferences = NotificationPreferences{
+	EmailEnabled:    true,
+	SightingNearby:  true,
+	ListingStale:    true,
+	Messages:        true,
+	AreaAlerts:      true,
+	UrgentChannels:  []string{ChannelEmail},
+	DigestChannels:  []string{ChannelEmail},
+	Timezone:        "UTC",
+	DigestFrequency: DigestOff,
+}
+
+// preferenceColumns maps a notification kind, or UnsubscribeAll, to its
+// notification_preferences column and the value that turns it off
+var preferenceColumns = map[string]struct {
+	column string
+	off    any
+}{
+	UnsubscribeAll:       {"email_enabled", false},
+	NotifySightingNearby: {"sighting_nearby", false},
+	NotifyListingStale:   {"listing_stale", false},
+	NotifyMessage:        {"messages", false},
+	NotifyListingDigest:  {"digest_frequency", DigestOff},
+	NotifyAreaAlert:      {"area_alerts", false},
+}
+
+// Allows reports whether the user wants kind on channel
+func (p NotificationPreferences) Allows(kind, channel string) bool {
+	if channel == ChannelEmail && !p.EmailEnabled {
+		return false
+	}
+	channels := p.DigestChannels
+	if NotificationUrgency(kind) == UrgencyUrgent {
+		channels = p.UrgentChannels
+	}
+	if !slices.Contains(channels, channel) {
+		return false
+	}
+	switch kind {
+	case NotifySightingNearby:
+		return p.SightingNearby
+	case NotifyListingStale:
+		return p.ListingStale
+	case NotifyMessage:
+		return p.Messages
+	case NotifyListingDigest:
+		return p.DigestFrequency != DigestOff
+	case NotifyAreaAlert:
+		return p.AreaAlerts
+	}
+	return false
+}
+
+// QuietUntil returns when the user's quiet hours end if now falls inside them
+func (p NotificationPreferences) QuietUntil(now time.Time) (time.Time, bool) {
+	if p.QuietHoursStart == nil || p.QuietHoursEnd == nil {
+		return time.Time{}, false
+	}
+	start, okStart := clockMinutes(*p.QuietHoursStart)
+	end, okEnd := clockMinutes(*p.QuietHoursEnd)
+	if !okStart || !okEnd || start == end {
+		return time.Time{}, false
+	}
+
+	location, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	current := local.Hour()*60 + local.Minute()
+
+	quiet := current >= start && current < end
+	if start > end {
+		// The window wraps past midnight, e.g. 22:00-07:00
+		quiet = current >= start || current < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, location)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+// clockMinutes parses HH:MM as minutes after midnight
+func clockMinutes(value string) (int, bool) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return parsed.Hour()*60 + parsed.Minute(), true
+}
+
+// ValidClock checks an optional HH:MM time of day
+func ValidClock(field string, value *string) Rule {
+	return func() *FieldError {
+		if value == nil {
+			return nil
+		}
+		if _, ok := clockMinutes(*value); !ok {
+			return &FieldError{Field: field, Code: CodeInvalid, Message: "must be a time in HH:MM format"}
+		}
+		return nil
+	}
+}
+
+// ValidTimezone checks an IANA time zone name, e.g. America/Edmonton
+func ValidTimezone(field, value string) Rule {
+	return func() *FieldError {
+		if value == "" {
+			return nil
+		}
+		if _, err := time.LoadLocation(value); err != nil {
+			return &FieldError{Field: field, Code: CodeInvalid, Message: "must be an IANA time zone, e.g. America/Edmonton"}
+		}
+		return nil
+	}
+}
+
+// PreferenceColumns lists the notification_preferences columns in the order
+// ScanPreferences reads them
+const PreferenceColumns = `email_enabled, sighting_nearby, listing_stale, messages, area_alerts,
+	urgent_channels, digest_channels, quiet_hours_start, quiet_hours_end, timezone, phone_number,
+	digest_frequency, digest_city_id, digest_latitude, digest_longitude, digest_radius_km`
+
+// ScanPreferences returns the scan targets for PreferenceColumns
+func ScanPreferences(prefs *NotificationPreferences) []any {
+	return []any{
+		&prefs.EmailEnabled, &prefs.SightingNearby, &prefs.ListingStale, &prefs.Messages, &prefs.AreaAlerts,
+		&prefs.UrgentChannels, &prefs.DigestChannels, &prefs.QuietHoursStart, &prefs.QuietHoursEnd, &prefs.Timezone,
+		&prefs.PhoneNumber,
+		&prefs.DigestFrequency, &prefs.DigestCityID, &prefs.DigestLatitude, &prefs.DigestLongitude, &prefs.DigestRadiusKm,
+	}
+}
+
+// LoadPreferences reads a user's notification preferences
+func LoadPreferences(ctx context.Context, db Querier, userUUID string) (NotificationPreferences, error) {
+	rows, err := db.Query(ctx, `SELECT `+PreferenceColumns+` FROM notification_preferences WHERE user_uuid = $1`, userUUID)
+	if err != nil {
+		return NotificationPreferences{}, err
+	}
+	defer rows.Close()
+
+	prefs := DefaultPreferences
+	if rows.Next() {
+		if err := rows.Scan(ScanPreferences(&prefs)...); err != nil {
+			return NotificationPreferences{}, err
+		}
+	}
+	return prefs, rows.Err()
+}
+
+// SavePreferences creates or replaces a user's notification preferences
+func SavePreferences(ctx context.Context, db Execer, userUUID string, prefs NotificationPreferences) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO notification_preferences (
+			user_uuid, email_enabled, sighting_nearby, listing_stale, messages, area_alerts,
+			urgent_channels, digest_channels, quiet_hours_start, quiet_hours_end, timezone,
+			digest_frequency, digest_city_id, digest_latitude, digest_longitude, digest_radius_km, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		ON CONFLICT (user_uuid) DO UPDATE
+		SET email_enabled = EXCLUDED.email_enabled, sighting_nearby = EXCLUDED.sighting_nearby,
+			listing_stale = EXCLUDED.listing_stale, messages = EXCLUDED.messages, area_alerts = EXCLUDED.area_alerts,
+			urgent_channels = EXCLUDED.urgent_channels, digest_channels = EXCLUDED.digest_channels,
+			quiet_hours_start = EXCLUDED.quiet_hours_start, quiet_hours_end = EXCLUDED.quiet_hours_end,
+			timezone = EXCLUDED.timezone, digest_frequency = EXCLUDED.digest_frequency,
+			digest_city_id = EXCLUDED.digest_city_id, digest_latitude = EXCLUDED.digest_latitude,
+			digest_longitude = EXCLUDED.digest_longitude, digest_radius_km = EXCLUDED.digest_radius_km,
+			updated_at = EXCLUDED.updated_at
+	`, userUUID, prefs.EmailEnabled, prefs.SightingNearby, prefs.ListingStale, prefs.Messages, prefs.AreaAlerts,
+		prefs.UrgentChannels, prefs.DigestChannels, prefs.QuietHoursStart, prefs.QuietHoursEnd, prefs.Timezone,
+		prefs.DigestFrequency, prefs.DigestCityID, prefs.DigestLatitude, prefs.DigestLongitude, prefs.DigestRadiusKm, time.Now())
+	return err
+}
+
+// Unsubscribe turns off one kind of email for a user, or all of them for UnsubscribeAll
+func Unsubscribe(ctx context.Context, db Execer, userUUID, kind string) error {
+	preference, ok := preferenceColumns[kind]
+	if !ok {
+		return &ValidationError{Message: "unknown notification kind", Field: "token"}
+	}
+	column := preference.column
+	_, err := db.Exec(ctx, `
+		INSERT INTO notification_preferences (user_uuid, `+column+`, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_uuid) DO UPDATE SET `+column+` = EXCLUDED.`+column+`, updated_at = EXCLUDED.updated_at
+	`, userUUID, preference.off, time.Now())
+	return err
+}
+
+// RouteChannels is a set-returning SQL expression with the channels a notification of
+// kind goes to for the user in userColumn: their urgent or digest channels, or email
+// if they have no preferences yet
+func RouteChannels(kind, userColumn string) string {
+	column := "digest_channels"
+	if NotificationUrgency(kind) == UrgencyUrgent {
+		column = "urgent_channels"
+	}
+	return `unnest(COALESCE((SELECT ` + column + ` FROM notification_preferences WHERE user_uuid = ` + userColumn + `), ARRAY['email']))`
+}
+
+// QueueNotification adds a notification for notification-dispatch to send, one per
+// channel the user gets kind on. Call it in the transaction of the change that caused
+// it so it is only sent if the change commits.
+func QueueNotification(ctx context.Context, db Execer, userUUID, kind string, data map[string]any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = db.Exec(ctx, `
+		INSERT INTO notifications (user_uuid, kind, channel, data, next_attempt_at, created_at)
+		SELECT $1, $2, c.channel, $3, $4, $4
+		FROM `+RouteChannels(kind, "$1::uuid")+` AS c(channel)
+	`, userUUID, kind, body, now)
+	return err
+}
+
+// QueueSightingAlerts tells the owners of lost pets of the same animal type last seen
+// within NearbySightingRadiusKm of a new sighting. Reporters aren't told about their own
+// sightings.
+func QueueSightingAlerts(ctx context.Context, db Execer, sightingID int) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO notifications (user_uuid, kind, channel, data, next_attempt_at, created_at)
+		SELECT l.listing_owner, $2, c.channel, jsonb_build_object(
+			'lost_listing_id', l.id,
+			'pet_name', l.pet_name,
+			'animal_type', s.animal_type,
+			'sighting_id', s.id,
+			'distance_km', round(`+DistanceKm("ll.geog", "sl.geog")+`::numeric, 1)
+		), $4, $4
+		FROM sighting_listing s
+		JOIN locations sl ON sl.id = s.spotted_location
+		JOIN lost_pet_listing l ON lower(l.animal_type) = lower(s.animal_type)
+			AND NOT l.is_found AND l.deleted_at IS NULL
+			AND l.date_lost <= s.date_spotted
+			AND l.listing_owner IS DISTINCT FROM s.listing_owner
+		JOIN locations ll ON ll.id = l.last_seen_location AND `+WithinKm("ll.geog", "sl.geog", "$3::float8")+`
+		CROSS JOIN LATERAL `+RouteChannels(NotifySightingNearby, "l.listing_owner")+` AS c(channel)
+		WHERE s.id = $1
+	`, sightingID, NotifySightingNearby, NearbySightingRadiusKm, time.Now())
+	return err
+}
+
+// UnsubscribeToken signs a user UUID and notification kind for the one-click
+// unsubscribe link in every email. Tokens don't expire.
+func UnsubscribeToken(userUUID, kind string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userUUID + ":" + kind))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(unsubscribeMAC(payload))
+}
+
+// ParseUnsubscribeToken verifies a token from UnsubscribeToken and returns what it unsubscribes
+func ParseUnsubscribeToken(token string) (string, string, error) {
+	invalid := &ValidationError{Message: "invalid unsubscribe token", Field: "token"}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", invalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, unsubscribeMAC(payload)) {
+		return "", "", invalid
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", invalid
+	}
+	userUUID, kind, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", invalid
+	}
+	if _, known := preferenceColumns[kind]; !known {
+		return "", "", invalid
+	}
+	return userUUID, kind, nil
+}
+
+func unsubscribeMAC(payload string) []byte {
+	key := sha256.Sum256([]byte("unsubscribe:" + os.Getenv("JWT_SECRET")))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// UnsubscribeURL is the frontend page that confirms an unsubscribe token
+func UnsubscribeURL(siteURL, userUUID, kind string) string {
+	return strings.TrimSuffix(siteURL, "/") + "/unsubscribe?token=" + url.QueryEscape(UnsubscribeToken(userUUID, kind))
+}
+
+//go:embed templates/email templates/short
+var notificationTemplates embed.FS
+
+// TemplateData is the data every notification template gets. Data holds the
+// notification's own fields, e.g. pet_name.
+type TemplateData struct {
+	Name           string
+	SiteURL        string
+	UnsubscribeURL string
+	Data           map[string]any
+}
+
+// RenderNotification renders the subject and the text and HTML bodies of a
+// notification. templates/email/<kind>.txt defines "subject" and the text body;
+// <kind>.html defines "content", which layout.html wraps.
+func RenderNotification(kind string, email TemplateData) (EmailMessage, error) {
+	email.SiteURL = strings.TrimSuffix(email.SiteURL, "/")
+
+	text, err := texttemplate.New(kind+".txt").Option("missingkey=zero").
+		ParseFS(notificationTemplates, "templates/email/"+kind+".txt", "templates/email/footer.txt")
+	if err != nil {
+		return EmailMessage{}, err
+	}
+	html, err := htmltemplate.New("layout.html").Option("missingkey=zero").
+		ParseFS(notificationTemplates, "templates/email/layout.html", "templates/email/"+kind+".html")
+	if err != nil {
+		return EmailMessage{}, err
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", email); err != nil {
+		return EmailMessage{}, err
+	}
+	if err := text.Execute(&textBody, email); err != nil {
+		return EmailMessage{}, err
+	}
+	if err := html.Execute(&htmlBody, email); err != nil {
+		return EmailMessage{}, err
+	}
+
+	return EmailMessage{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(textBody.String()) + "\n",
+		HTML:    htmlBody.String(),
+		Headers: map[string]string{"List-Unsubscribe": "<" + email.UnsubscribeURL + ">"},
+	}, nil
+}
+
+// ShortMessage is a notification for SMS and push: a title, a body of a sentence or
+// two, and the page to open
+type ShortMessage struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"`
+}
+
+// SMS is the text message for a short notification
+func (m ShortMessage) SMS() string {
+	return m.Body + " " + m.URL
+}
+
+// RenderShort renders a notification for SMS and push from templates/short/<kind>.txt,
+// which defines "title" and "url" and whose body is the message
+func RenderShort(kind string, data TemplateData) (ShortMessage, error) {
+	data.SiteURL = strings.TrimSuffix(data.SiteURL, "/")
+
+	tmpl, err := texttemplate.New(kind+".txt").Option("missingkey=zero").
+		ParseFS(notificationTemplates, "templates/short/"+kind+".txt")
+	if err != nil {
+		return ShortMessage{}, err
+	}
+
+	var parts [3]bytes.Buffer
+	for i, name := range []string{"title", "url", kind + ".txt"} {
+		if err := tmpl.ExecuteTemplate(&parts[i], name, data); err != nil {
+			return ShortMessage{}, err
+		}
+	}
+	return ShortMessage{
+		Title: strings.TrimSpace(parts[0].String()),
+		URL:   strings.TrimSpace(parts[1].String()),
+		Body:  strings.Join(strings.Fields(parts[2].String()), " "),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package generic
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnsubscribeToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	userUUID := "7f6d1a52-3c1e-4e61-9a55-0b8b9f3d2c11"
+
+	for _, kind := range []string{UnsubscribeAll, NotifySightingNearby, NotifyListingDigest} {
+		gotUser, gotKind, err := ParseUnsubscribeToken(UnsubscribeToken(userUUID, kind))
+		if err != nil || gotUser != userUUID || gotKind != kind {
+			t.Errorf("round trip of %q = %q, %q, %v", kind, gotUser, gotKind, err)
+		}
+	}
+
+	token := UnsubscribeToken(userUUID, NotifyMessage)
+	payload, _, _ := strings.Cut(token, ".")
+	forged := UnsubscribeToken("00000000-0000-0000-0000-000000000000", NotifyMessage)
+	_, forgedSignature, _ := strings.Cut(forged, ".")
+
+	// A correctly signed payload that isn't "<user>:<kind>"
+	signed := func(payload string) string {
+		encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+		return encoded + "." + base64.RawURLEncoding.EncodeToString(unsubscribeMAC(encoded))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no signature", payload},
+		{"signature of another token", payload + "." + forgedSignature},
+		{"unknown kind", UnsubscribeToken(userUUID, "everything")},
+		{"no kind", signed(userUUID)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseUnsubscribeToken(tt.token); err == nil {
+				t.Errorf("ParseUnsubscribeToken(%q) succeeded", tt.token)
+			}
+		})
+	}
+
+	t.Run("other secret", func(t *testing.T) {
+		t.Setenv("JWT_SECRET", "another-secret")
+		if _, _, err := ParseUnsubscribeToken(token); err == nil {
+			t.Error("a token signed with another secret was accepted")
+		}
+	})
+}
+
+func TestQuietUntil(t *testing.T) {
+	clock := func(value string) *string { return &value }
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name      string
+		start     *string
+		end       *string
+		timezone  string
+		now       string
+		wantQuiet bool
+		wantUntil string
+	}{
+		{"no quiet hours", nil, nil, "UTC", "2025-11-08T23:00:00Z", false, ""},
+		{"same start and end", clock("08:00"), clock("08:00"), "UTC", "2025-11-08T08:00:00Z", false, ""},
+		{"inside a daytime window", clock("12:00"), clock("14:00"), "UTC", "2025-11-08T13:15:00Z", true, "2025-11-08T14:00:00Z"},
+		{"end of a daytime window", clock("12:00"), clock("14:00"), "UTC", "2025-11-08T14:00:00Z", false, ""},
+		{"before midnight in a wrapping window", clock("22:00"), clock("07:00"), "UTC", "2025-11-08T23:30:00Z", true, "2025-11-09T07:00:00Z"},
+		{"after midnight in a wrapping window", clock("22:00"), clock("07:00"), "UTC", "2025-11-09T03:00:00Z", true, "2025-11-09T07:00:00Z"},
+		{"outside a wrapping window", clock("22:00"), clock("07:00"), "UTC", "2025-11-09T12:00:00Z", false, ""},
+		// 05:00 UTC is 22:00 in Edmonton, the start of the window there
+		{"in the user's timezone", clock("22:00"), clock("07:00"), "America/Edmonton", "2025-11-09T05:00:00Z", true, "2025-11-09T14:00:00Z"},
+		{"unknown timezone falls back to UTC", clock("22:00"), clock("07:00"), "Nowhere/Special", "2025-11-09T05:00:00Z", true, "2025-11-09T07:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := NotificationPreferences{QuietHoursStart: tt.start, QuietHoursEnd: tt.end, Timezone: tt.timezone}
+			until, quiet := prefs.QuietUntil(at(tt.now))
+			if quiet != tt.wantQuiet {
+				t.Fatalf("quiet = %v, want %v", quiet, tt.wantQuiet)
+			}
+			if quiet && !until.Equal(at(tt.wantUntil)) {
+				t.Errorf("until = %v, want %v", until.UTC(), tt.wantUntil)
+			}
+		})
+	}
+}
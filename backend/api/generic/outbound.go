@@ -0,0 +1,70 @@
+package generic
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// PublicClient returns an HTTP client for user-supplied URLs such as webhook and push
+// endpoints. It refuses loopback, private and link-local addresses, so a URL can't be
+// used to reach internal services, and it doesn't follow redirects, which could point
+// anywhere.
+func PublicClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: publicOnly}).DialContext,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return errors.New("refusing to connect to non-public address " + host)
+	}
+	return nil
+}
+
+// Scheduled jobs work through their backlog in batches of at most these sizes, so a
+// backlog can't make a run exceed the Lambda timeout. What is left over is picked up by
+// the next run.
+const (
+	EventBatchSize        = 500 // webhook events fanned out
+	DeliveryBatchSize     = 100 // webhook deliveries attempted
+	NotificationBatchSize = 50
+	SocialBatchSize       = 25 // listings posted and posts removed, per channel
+	DigestBatchSize       = 200
+	PurgeBatchSize        = 200 // per listing table
+)
+
+// MaxStoredError is how many bytes of an outbound call's error StoredError keeps
+const MaxStoredError = 500
+
+// StoredError prepares an outbound call's error text for a text column. It can quote
+// whatever the remote side answered, and Postgres rejects invalid UTF-8 and NUL bytes, so
+// those are cleaned out and the text is cut to at most MaxStoredError bytes without
+// splitting a character.
+func StoredError(message string) string {
+	message = strings.ReplaceAll(strings.ToValidUTF8(message, "\uFFFD"), "\x00", "")
+	if len(message) <= MaxStoredError {
+		return message
+	}
+	cut := MaxStoredError
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut]
+}
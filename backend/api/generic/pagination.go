@@ -0,0 +1,223 @@
+package generic
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+// Total modes accepted by the includeTotal query parameter
+const (
+	TotalNone     = ""
+	TotalExact    = "exact"
+	TotalEstimate = "estimate"
+)
+
+// SortKey describes an ORDER BY that can be paged with a keyset cursor. Rows are
+// ordered by Column and then IDColumn in the same direction so ties are stable.
+type SortKey struct {
+	Name     string // value of the sort query parameter
+	Column   string // SQL expression the rows are ordered by
+	Type     string // Postgres type cursor values are cast to
+	IDColumn string
+	Desc     bool
+}
+
+// Cursor marks the boundary row of a page. It is handed to clients as an opaque, sealed string.
+type Cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       int    `json:"id"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+type Page struct {
+	Limit  int
+	Cursor *Cursor
+	Total  string
+}
+
+// EncodeCursor seals the cursor so clients can't read the sort value. Distance cursors
+// would otherwise reveal exact distances to listings with a fuzzed location.
+func EncodeCursor(cursor Cursor) string {
+	raw, _ := json.Marshal(cursor)
+	aead := cursorCipher()
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, raw, nil))
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	invalid := &ValidationError{Message: "invalid cursor", Field: "cursor"}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+	aead := cursorCipher()
+	if len(sealed) < aead.NonceSize() {
+		return nil, invalid
+	}
+	raw, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, invalid
+	}
+	return &cursor, nil
+}
+
+// cursorCipher derives the cursor key from JWT_SECRET, which every listing Lambda has
+func cursorCipher() cipher.AEAD {
+	key := sha256.Sum256([]byte("cursor:" + os.Getenv("JWT_SECRET")))
+	block, _ := aes.NewCipher(key[:])
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
+
+// ParsePage reads limit, cursor and includeTotal. A cursor issued for a different sort is rejected.
+func ParsePage(params map[string]string, sort SortKey) (Page, error) {
+	page := Page{Limit: DefaultPageLimit}
+
+	if limitStr, ok := params["limit"]; ok {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= MaxPageLimit {
+			page.Limit = parsedLimit
+		}
+	}
+
+	if encoded := params["cursor"]; encoded != "" {
+		cursor, err := DecodeCursor(encoded)
+		if err != nil {
+			return Page{}, err
+		}
+		if cursor.Sort != sort.Name {
+			return Page{}, &ValidationError{Message: "cursor was issued for a different sort order", Field: "cursor"}
+		}
+		page.Cursor = cursor
+	}
+
+	switch params["includeTotal"] {
+	case "", "false":
+	case "true", TotalExact:
+		page.Total = TotalExact
+	case TotalEstimate:
+		page.Total = TotalEstimate
+	default:
+		return Page{}, &ValidationError{Message: "must be one of: true, exact, estimate", Field: "includeTotal"}
+	}
+
+	return page, nil
+}
+
+// Clause returns the keyset condition, ORDER BY and LIMIT to append after the filters.
+// One extra row is requested so the caller can tell whether another page exists.
+func (p Page) Clause(sort SortKey, args []any) (string, []any) {
+	// Walking backwards flips the scan direction; PageResult restores the order
+	desc := sort.Desc
+	if p.Cursor != nil && p.Cursor.Backward {
+		desc = !desc
+	}
+
+	direction, comparison := "ASC", ">"
+	if desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	clause := ""
+	if p.Cursor != nil {
+		clause += ` AND (` + sort.Column + `, ` + sort.IDColumn + `) ` + comparison +
+			` ($` + strconv.Itoa(len(args)+1) + `::` + sort.Type + `, $` + strconv.Itoa(len(args)+2) + `)`
+		args = append(args, p.Cursor.Value, p.Cursor.ID)
+	}
+
+	clause += ` ORDER BY ` + sort.Column + ` ` + direction + `, ` + sort.IDColumn + ` ` + direction +
+		` LIMIT $` + strconv.Itoa(len(args)+1)
+	args = append(args, p.Limit+1)
+
+	return clause, args
+}
+
+// PageResult trims the extra row fetched by Clause and builds the next/prev cursors.
+// key returns the cursor value and ID of a row.
+func PageResult[T any](items []T, p Page, sort SortKey, key func(T) (string, int)) ([]T, *string, *string) {
+	hasMore := len(items) > p.Limit
+	if hasMore {
+		items = items[:p.Limit]
+	}
+
+	backward := p.Cursor != nil && p.Cursor.Backward
+	if backward {
+		slices.Reverse(items)
+	}
+	if len(items) == 0 {
+		return items, nil, nil
+	}
+
+	cursorAt := func(item T, backward bool) *string {
+		value, id := key(item)
+		encoded := EncodeCursor(Cursor{Sort: sort.Name, Value: value, ID: id, Backward: backward})
+		return &encoded
+	}
+
+	var next, prev *string
+	if backward {
+		next = cursorAt(items[len(items)-1], false)
+		if hasMore {
+			prev = cursorAt(items[0], true)
+		}
+	} else {
+		if hasMore {
+			next = cursorAt(items[len(items)-1], false)
+		}
+		if p.Cursor != nil {
+			prev = cursorAt(items[0], true)
+		}
+	}
+
+	return items, next, prev
+}
+
+// CountTotal counts the rows matched by from (a FROM ... WHERE ... fragment). Estimates
+// come from the query planner and avoid scanning the table.
+func CountTotal(ctx context.Context, conn *pgx.Conn, mode, from string, args []any) (int64, error) {
+	if mode == TotalEstimate {
+		var plan string
+		if err := conn.QueryRow(ctx, `EXPLAIN (FORMAT JSON) SELECT 1 `+from, args...).Scan(&plan); err != nil {
+			return 0, err
+		}
+		var explained []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+		if err := json.Unmarshal([]byte(plan), &explained); err != nil {
+			return 0, err
+		}
+		if len(explained) == 0 {
+			return 0, errors.New("empty query plan")
+		}
+		return int64(explained[0].Plan.Rows), nil
+	}
+
+	var total int64
+	err := conn.QueryRow(ctx, `SELECT COUNT(*) `+from, args...).Scan(&total)
+	return total, err
+}
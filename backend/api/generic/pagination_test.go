@@ -0,0 +1,67 @@
+package generic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	cursors := []Cursor{
+		{Sort: SortCreated, Value: "2025-11-08T17:02:11Z", ID: 42},
+		{Sort: "distance", Value: "1.2345", ID: 7, Backward: true},
+		{Sort: "name", Value: "", ID: 1},
+	}
+	for _, cursor := range cursors {
+		encoded := EncodeCursor(cursor)
+		if strings.Contains(encoded, cursor.Value) && cursor.Value != "" {
+			t.Errorf("cursor %q shows its sort value", encoded)
+		}
+		decoded, err := DecodeCursor(encoded)
+		if err != nil {
+			t.Fatalf("DecodeCursor(%q) failed: %v", encoded, err)
+		}
+		if *decoded != cursor {
+			t.Errorf("DecodeCursor(EncodeCursor(%+v)) = %+v", cursor, *decoded)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	valid := EncodeCursor(Cursor{Sort: SortCreated, Value: "x", ID: 1})
+
+	// Flip a byte of the sealed cursor
+	tampered := []byte(valid)
+	if tampered[len(tampered)-2] == 'A' {
+		tampered[len(tampered)-2] = 'B'
+	} else {
+		tampered[len(tampered)-2] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"not base64", "not a cursor!"},
+		{"too short", "AAAA"},
+		{"tampered", string(tampered)},
+		{"unsealed json", "eyJzIjoiY3JlYXRlZCIsInYiOiJ4IiwiaWQiOjF9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.encoded); err == nil {
+				t.Errorf("DecodeCursor(%q) succeeded", tt.encoded)
+			}
+		})
+	}
+
+	t.Run("other secret", func(t *testing.T) {
+		t.Setenv("JWT_SECRET", "another-secret")
+		if _, err := DecodeCursor(valid); err == nil {
+			t.Error("a cursor sealed with another secret was accepted")
+		}
+	})
+}
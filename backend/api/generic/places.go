@@ -0,0 +1,120 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LocationToleranceM is how close, in metres, an existing location with the same street
+// address has to be for a new one to reuse it instead of adding a near duplicate
+const LocationToleranceM = 10
+
+// CityKey is the place key a city is stored and matched under: its folded name, ISO
+// subdivision and ISO country, like "calgary|CA-AB|CA". Provinces and countries without
+// an ISO code are folded like the name.
+func CityKey(place Place) string {
+	place = NormalizePlace(place)
+	country := CountryCode(place.Country)
+	subdivision := SubdivisionCode(place.ProvinceOrState, country)
+	if country == "" {
+		country = PlaceKey(place.Country)
+	}
+	if subdivision == "" {
+		subdivision = PlaceKey(place.ProvinceOrState)
+	}
+	return PlaceKey(place.City) + "|" + subdivision + "|" + country
+}
+
+// PlaceCodes returns the ISO country and subdivision codes of a place, nil where they
+// aren't known
+func PlaceCodes(place Place) (country, subdivision *string) {
+	if code := CountryCode(place.Country); code != "" {
+		country = &code
+		if code := SubdivisionCode(place.ProvinceOrState, code); code != "" {
+			subdivision = &code
+		}
+	}
+	return country, subdivision
+}
+
+// GetOrCreateCity returns the ID of the city a place is in, matching on its place key
+// or an alias of it, and creates the city if there is none. Pass a place from
+// ResolvePlace or ResolveCity so a new city gets its canonical names.
+func GetOrCreateCity(ctx context.Context, db Querier, place Place) (int, error) {
+	place = NormalizePlace(place)
+	countryCode, subdivisionCode := PlaceCodes(place)
+	rows, err := db.Query(ctx, `
+		WITH existing AS (
+			SELECT id FROM cities WHERE place_key = $1
+			UNION ALL
+			SELECT city_id FROM city_aliases WHERE alias_key = $1
+			LIMIT 1
+		), inserted AS (
+			INSERT INTO cities (city_name, province_or_state, country, country_code, subdivision_code, place_key)
+			SELECT $2, $3, $4, $5, $6, $1
+			WHERE NOT EXISTS (SELECT 1 FROM existing)
+			ON CONFLICT (place_key) DO UPDATE SET place_key = EXCLUDED.place_key
+			RETURNING id
+		)
+		SELECT id FROM existing
+		UNION ALL
+		SELECT id FROM inserted
+	`, CityKey(place), place.City, place.ProvinceOrState, place.Country, countryCode, subdivisionCode)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, errors.New("city was neither found nor created")
+	}
+	return ids[0], nil
+}
+
+// sameLocation is the condition for an existing location l to stand for a new one with
+// the street address, city and geography point given: the same address ignoring case
+// and surrounding spaces, in the same city, within LocationToleranceM
+func sameLocation(street, cityID, point string) string {
+	return `lower(btrim(l.street_address)) = lower(btrim(` + street + `))
+		AND l.city_id = ` + cityID + `
+		AND ST_DWithin(l.geog, ` + point + `, ` + strconv.Itoa(LocationToleranceM) + `)`
+}
+
+// GetOrCreateLocation returns the ID of the nearest location that is the same as the
+// place (see sameLocation), and creates one if there is none
+func GetOrCreateLocation(ctx context.Context, db Querier, place Place, cityID int) (int, error) {
+	point := GeogParam(3, 4)
+	rows, err := db.Query(ctx, `
+		WITH existing AS (
+			SELECT l.id FROM locations l
+			WHERE `+sameLocation("$1", "$5", point)+`
+			ORDER BY `+NearestFirst("l.geog", point)+`, l.id
+			LIMIT 1
+		), inserted AS (
+			INSERT INTO locations (street_address, postal_code, latitude, longitude, city_id, created_at)
+			SELECT $1, $2, $3, $4, $5, $6
+			WHERE NOT EXISTS (SELECT 1 FROM existing)
+			RETURNING id
+		)
+		SELECT id FROM existing
+		UNION ALL
+		SELECT id FROM inserted
+	`, place.StreetAddress, place.PostalCode, place.Lat, place.Lng, cityID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, errors.New("location was neither found nor created")
+	}
+	return ids[0], nil
+}
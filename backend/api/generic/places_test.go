@@ -0,0 +1,24 @@
+package generic
+
+import "testing"
+
+func TestCityKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		place Place
+		want  string
+	}{
+		{"full names", Place{City: "Calgary", ProvinceOrState: "Alberta", Country: "Canada"}, "calgary|CA-AB|CA"},
+		{"codes", Place{City: "CALGARY", ProvinceOrState: "AB", Country: "CA"}, "calgary|CA-AB|CA"},
+		{"province in the city", Place{City: "Calgary, AB"}, "calgary|CA-AB|CA"},
+		{"accents and punctuation", Place{City: "Saint-Jérôme", ProvinceOrState: "QC", Country: "Canada"}, "saint jerome|CA-QC|CA"},
+		{"unknown country", Place{City: "Springfield", ProvinceOrState: "North  Shire", Country: "Atlantis"}, "springfield|north shire|atlantis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CityKey(tt.place); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
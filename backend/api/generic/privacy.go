@@ -0,0 +1,112 @@
+package generic
+
+import (
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Location privacy levels an owner can choose for a listing
+const (
+	PrivacyExact        = "exact"
+	PrivacyBlock        = "block"
+	PrivacyNeighborhood = "neighborhood"
+)
+
+var PrivacyLevels = []string{PrivacyExact, PrivacyBlock, PrivacyNeighborhood}
+
+// DefaultPrivacy applies to listings whose owner hasn't chosen a level
+const DefaultPrivacy = PrivacyBlock
+
+const metersPerDegree = 111320.0
+
+// Grid cell size and distance rounding step for each fuzzed level
+var privacyCells = map[string]struct {
+	meters float64
+	stepKm float64
+}{
+	PrivacyBlock:        {meters: 250, stepKm: 0.5},
+	PrivacyNeighborhood: {meters: 1000, stepKm: 1},
+}
+
+// FuzzCoordinates snaps a point to the level's grid cell and then moves it to a
+// pseudo-random spot inside that cell. The spot is derived from seed (e.g. the listing
+// ID), so the same listing always gets the same public point and repeated requests
+// can't be averaged back to the true location.
+func FuzzCoordinates(level, seed string, lat, lng float64) (float64, float64) {
+	cell, ok := privacyCells[level]
+	if !ok {
+		return lat, lng
+	}
+
+	latStep := cell.meters / metersPerDegree
+	row := math.Floor(lat / latStep)
+	// Longitude degrees shrink towards the poles; size cells from the row's centre so they stay roughly square
+	rowCenter := (row + 0.5) * latStep
+	lngStep := cell.meters / (metersPerDegree * math.Max(math.Cos(rowCenter*math.Pi/180), 0.01))
+	col := math.Floor(lng / lngStep)
+
+	h := fnv.New64a()
+	h.Write([]byte(level + ":" + seed))
+	sum := h.Sum64()
+	latOffset := float64(sum&0xffffffff) / float64(1<<32)
+	lngOffset := float64(sum>>32) / float64(1<<32)
+
+	return (row + latOffset) * latStep, (col + lngOffset) * lngStep
+}
+
+// FuzzAddress drops the house number at block level and the whole street at
+// neighborhood level
+func FuzzAddress(level, streetAddress string) string {
+	switch level {
+	case PrivacyBlock:
+		words := strings.Fields(streetAddress)
+		for len(words) > 1 && strings.IndexFunc(words[0], unicode.IsDigit) >= 0 {
+			words = words[1:]
+		}
+		return strings.Join(words, " ")
+	case PrivacyNeighborhood:
+		return ""
+	}
+	return streetAddress
+}
+
+// FuzzPostalCode keeps only the area prefix of a postal code (e.g. the Canadian FSA)
+func FuzzPostalCode(level string, postalCode *string) *string {
+	if level == PrivacyExact || postalCode == nil {
+		return postalCode
+	}
+	code := strings.TrimSpace(*postalCode)
+	if len(code) > 3 {
+		code = code[:3]
+	}
+	return &code
+}
+
+// FuzzDistance rounds a distance up to the level's step, so distances from chosen
+// points can't be used to trilaterate the true location
+func FuzzDistance(level string, km float64) float64 {
+	cell, ok := privacyCells[level]
+	if !ok {
+		return km
+	}
+	return math.Max(1, math.Ceil(km/cell.stepKm)) * cell.stepKm
+}
+
+// MaxFuzzKm is the furthest FuzzCoordinates can move a point: a neighborhood cell's
+// diagonal, rounded up
+const MaxFuzzKm = 1.5
+
+// PublicDistanceKm is how far a listing's public point at the level is from another
+// point. Pass it through FuzzDistance before showing it.
+func PublicDistanceKm(level, seed string, lat, lng, fromLat, fromLng float64) float64 {
+	lat, lng = FuzzCoordinates(level, seed, lat, lng)
+	return distanceKm(lat, lng, fromLat, fromLng)
+}
+
+// PrivacySeed is the FuzzCoordinates seed for a listing
+func PrivacySeed(listingType string, listingID int) string {
+	return listingType + ":" + strconv.Itoa(listingID)
+}
@@ -0,0 +1,76 @@
+package generic
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFuzzCoordinates(t *testing.T) {
+	lat, lng := 51.0447, -114.0719
+
+	tests := []struct {
+		level string
+		maxKm float64
+	}{
+		{PrivacyBlock, 0.4},
+		{PrivacyNeighborhood, MaxFuzzKm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			seed := PrivacySeed(ListingTypeLost, 42)
+			fuzzedLat, fuzzedLng := FuzzCoordinates(tt.level, seed, lat, lng)
+			if fuzzedLat == lat && fuzzedLng == lng {
+				t.Fatal("the point wasn't moved")
+			}
+			if km := distanceKm(lat, lng, fuzzedLat, fuzzedLng); km > tt.maxKm {
+				t.Errorf("moved %.3f km, want at most %.3f", km, tt.maxKm)
+			}
+
+			againLat, againLng := FuzzCoordinates(tt.level, seed, lat, lng)
+			if againLat != fuzzedLat || againLng != fuzzedLng {
+				t.Error("the same listing got a different public point")
+			}
+
+			// Anywhere in the same cell gives the same public point, so moving the true
+			// location a little doesn't show
+			nearLat, nearLng := FuzzCoordinates(tt.level, seed, lat+0.00001, lng+0.00001)
+			if nearLat != fuzzedLat || nearLng != fuzzedLng {
+				t.Error("a point in the same cell got a different public point")
+			}
+
+			otherLat, otherLng := FuzzCoordinates(tt.level, PrivacySeed(ListingTypeLost, 43), lat, lng)
+			if otherLat == fuzzedLat && otherLng == fuzzedLng {
+				t.Error("two listings got the same public point")
+			}
+		})
+	}
+
+	t.Run(PrivacyExact, func(t *testing.T) {
+		if gotLat, gotLng := FuzzCoordinates(PrivacyExact, "lost:42", lat, lng); gotLat != lat || gotLng != lng {
+			t.Errorf("exact moved the point to %v, %v", gotLat, gotLng)
+		}
+	})
+}
+
+func TestFuzzDistance(t *testing.T) {
+	tests := []struct {
+		level string
+		km    float64
+		want  float64
+	}{
+		{PrivacyExact, 0.123, 0.123},
+		{PrivacyBlock, 0, 0.5},
+		{PrivacyBlock, 0.1, 0.5},
+		{PrivacyBlock, 0.5, 0.5},
+		{PrivacyBlock, 0.51, 1},
+		{PrivacyBlock, 2.2, 2.5},
+		{PrivacyNeighborhood, 0.2, 1},
+		{PrivacyNeighborhood, 1.01, 2},
+		{PrivacyNeighborhood, 7, 7},
+	}
+	for _, tt := range tests {
+		if got := FuzzDistance(tt.level, tt.km); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("FuzzDistance(%q, %v) = %v, want %v", tt.level, tt.km, got, tt.want)
+		}
+	}
+}
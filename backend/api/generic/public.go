@@ -0,0 +1,70 @@
+package generic
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// Anonymous readers get PublicRateLimit requests per PublicRateWindow per source IP
+const (
+	PublicRateLimit  = 60
+	PublicRateWindow = time.Minute
+)
+
+// RateLimitRetention is how long rate limit windows are kept before data-retention removes them
+const RateLimitRetention = 24 * time.Hour
+
+// HasAuthorization reports whether the request carries an Authorization header. Requests
+// without one are anonymous; requests with a bad one are still rejected.
+func HasAuthorization(request events.APIGatewayProxyRequest) bool {
+	return request.Headers["Authorization"] != "" || request.Headers["authorization"] != ""
+}
+
+// CheckPublicRateLimit counts an anonymous request against its source IP and returns a
+// RateLimitError once the IP is over the limit for the current window
+func CheckPublicRateLimit(ctx context.Context, db Querier, request events.APIGatewayProxyRequest) error {
+	key := "public:" + request.RequestContext.Identity.SourceIP
+	return CheckRateLimit(ctx, db, key, PublicRateLimit, PublicRateWindow)
+}
+
+// CheckRateLimit is a fixed-window counter shared by every Lambda instance. Each call
+// counts as a hit; the call that goes over limit and every one after it in the window fail.
+func CheckRateLimit(ctx context.Context, db Querier, key string, limit int, window time.Duration) error {
+	now := time.Now()
+	windowStart := now.Truncate(window)
+
+	rows, err := db.Query(ctx, `
+		INSERT INTO rate_limits (key, window_start, hits) VALUES ($1, $2, 1)
+		ON CONFLICT (key, window_start) DO UPDATE SET hits = rate_limits.hits + 1
+		RETURNING hits
+	`, key, windowStart)
+	if err != nil {
+		return Internal("failed to check rate limit", err)
+	}
+	hits, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[int])
+	if err != nil {
+		return Internal("failed to check rate limit", err)
+	}
+
+	if hits > limit {
+		retryAfter := int(windowStart.Add(window).Sub(now).Seconds()) + 1
+		return &RateLimitError{
+			Message:    "too many requests, sign in or retry in " + strconv.Itoa(retryAfter) + " seconds",
+			RetryAfter: retryAfter,
+		}
+	}
+	return nil
+}
+
+// AnonymousPrivacy is the privacy level applied for callers who aren't signed in. An
+// exact location is still shown at block level, since anonymous reads can be scraped.
+func AnonymousPrivacy(level string) string {
+	if level == PrivacyExact {
+		return PrivacyBlock
+	}
+	return level
+}
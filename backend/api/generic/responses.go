@@ -1,7 +1,9 @@
 package generic
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"net/http"
 
 	"github.com/aws/aws-lambda-go/events"
 )
@@ -36,3 +38,24 @@ func Response(status int, body Json, cookie ...string) (events.APIGatewayProxyRe
 	}, nil
 
 }
+
+// FileResponse returns data as a base64-encoded body. API Gateway decodes it for clients
+// whose Accept header matches one of the API's binary media types; the file opens inline
+// and saves under filename.
+func FileResponse(contentType, filename string, data []byte) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{
+		"Content-Type":                  contentType,
+		"Content-Disposition":           `inline; filename="` + filename + `"`,
+		"Access-Control-Allow-Origin":   "*",
+		"Access-Control-Allow-Headers":  "Content-Type,Authorization",
+		"Access-Control-Allow-Methods":  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		"Access-Control-Expose-Headers": "Content-Disposition",
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode:      http.StatusOK,
+		Headers:         headers,
+		Body:            base64.StdEncoding.EncodeToString(data),
+		IsBase64Encoded: true,
+	}, nil
+}
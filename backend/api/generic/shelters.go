@@ -0,0 +1,181 @@
+package generic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultShelterRadiusKm = 50
+	MaxShelterRadiusKm     = 500
+	DefaultShelterLimit    = 10
+	MaxShelterLimit        = 50
+)
+
+// OpeningHours is one opening window. Day is 0 (Sunday) to 6; times are HH:MM local time.
+type OpeningHours struct {
+	Day    int    `json:"day"`
+	Opens  string `json:"opens"`
+	Closes string `json:"closes"`
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ValidHours checks one opening window
+func ValidHours(field string, hours OpeningHours) Rule {
+	return func() *FieldError {
+		if hours.Day < 0 || hours.Day > 6 {
+			return &FieldError{Field: field + ".day", Code: CodeOutOfRange, Message: "must be between 0 (Sunday) and 6"}
+		}
+		opens, err := time.Parse("15:04", hours.Opens)
+		if err != nil {
+			return &FieldError{Field: field + ".opens", Code: CodeInvalid, Message: "must be a time in HH:MM format"}
+		}
+		closes, err := time.Parse("15:04", hours.Closes)
+		if err != nil {
+			return &FieldError{Field: field + ".closes", Code: CodeInvalid, Message: "must be a time in HH:MM format"}
+		}
+		if !closes.After(opens) {
+			return &FieldError{Field: field + ".closes", Code: CodeOutOfRange, Message: "must be after opens"}
+		}
+		return nil
+	}
+}
+
+// ParseHours reads the compact hours format used by shelter datasets, e.g.
+// "Mon-Fri 09:00-17:00; Sat 10:00-14:00". Days are three-letter English names.
+func ParseHours(value string) ([]OpeningHours, error) {
+	hours := []OpeningHours{}
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		days, times, ok := strings.Cut(part, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid hours %q", part)
+		}
+		opens, closes, ok := strings.Cut(strings.TrimSpace(times), "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid hours %q", part)
+		}
+
+		first, last, isRange := strings.Cut(days, "-")
+		if !isRange {
+			last = first
+		}
+		from := weekday(first)
+		to := weekday(last)
+		if from < 0 || to < 0 {
+			return nil, fmt.Errorf("invalid day in %q", part)
+		}
+
+		for day := from; ; day = (day + 1) % 7 {
+			window := OpeningHours{Day: day, Opens: opens, Closes: closes}
+			if errs := Validate(ValidHours("hours", window)); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid hours %q: %s", part, errs[0].Message)
+			}
+			hours = append(hours, window)
+			if day == to {
+				break
+			}
+		}
+	}
+	return hours, nil
+}
+
+func weekday(name string) int {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, day := range weekdays {
+		if strings.HasPrefix(name, day) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Shelter is a shelter or rescue ranked by distance from a point
+type Shelter struct {
+	ID            int            `json:"id"`
+	Name          string         `json:"name"`
+	Type          string         `json:"type"`
+	Phone         *string        `json:"phone,omitempty"`
+	Email         *string        `json:"email,omitempty"`
+	Website       *string        `json:"website,omitempty"`
+	Hours         []OpeningHours `json:"hours"`
+	Verified      bool           `json:"verified"`
+	StreetAddress string         `json:"street_address"`
+	PostalCode    *string        `json:"postal_code,omitempty"`
+	City          *string        `json:"city,omitempty"`
+	Latitude      float64        `json:"latitude"`
+	Longitude     float64        `json:"longitude"`
+	DistanceKm    float64        `json:"distance_km"`
+}
+
+// ParseShelterSearch reads the radiusKm and limit query parameters
+func ParseShelterSearch(params map[string]string) (float64, int, error) {
+	radius := float64(DefaultShelterRadiusKm)
+	if value := params["radiusKm"]; value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, 0, ValidationErrors{{Field: "radiusKm", Code: CodeInvalid, Message: "must be a number"}}
+		}
+		if errs := Validate(InRange("radiusKm", parsed, 0.1, MaxShelterRadiusKm)); len(errs) > 0 {
+			return 0, 0, errs
+		}
+		radius = parsed
+	}
+
+	limit := DefaultShelterLimit
+	if value := params["limit"]; value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 && parsed <= MaxShelterLimit {
+			limit = parsed
+		}
+	}
+
+	return radius, limit, nil
+}
+
+// NearbyShelters returns organizations within radiusKm of the point, nearest first
+func NearbyShelters(ctx context.Context, conn *pgx.Conn, lat, lng, radiusKm float64, limit int) ([]Shelter, error) {
+	point := GeogParam(1, 2)
+	query := `
+		SELECT
+			o.id, o.name, o.org_type, o.phone, o.email, o.website, o.hours, o.verified,
+			loc.street_address, loc.postal_code, c.city_name, loc.latitude, loc.longitude,
+			` + DistanceKm("loc.geog", point) + `
+		FROM organizations o
+		JOIN locations loc ON o.location_id = loc.id
+		LEFT JOIN cities c ON loc.city_id = c.id
+		WHERE ` + WithinKm("loc.geog", point, "$3::float8") + `
+		ORDER BY ` + NearestFirst("loc.geog", point) + `, o.id
+		LIMIT $4
+	`
+
+	rows, err := conn.Query(ctx, query, lat, lng, radiusKm, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shelters := []Shelter{}
+	for rows.Next() {
+		var shelter Shelter
+		err := rows.Scan(
+			&shelter.ID, &shelter.Name, &shelter.Type, &shelter.Phone, &shelter.Email, &shelter.Website, &shelter.Hours, &shelter.Verified,
+			&shelter.StreetAddress, &shelter.PostalCode, &shelter.City, &shelter.Latitude, &shelter.Longitude,
+			&shelter.DistanceKm,
+		)
+		if err != nil {
+			return nil, err
+		}
+		shelters = append(shelters, shelter)
+	}
+	return shelters, rows.Err()
+}
@@ -0,0 +1,147 @@
+package generic
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMS providers selected with SMS_PROVIDER
+const (
+	SMSProviderTwilio  = "twilio"
+	SMSProviderCapture = "capture"
+)
+
+// Phone verification codes expire after PhoneCodeTTL and allow PhoneCodeMaxAttempts guesses
+const (
+	PhoneCodeTTL         = 10 * time.Minute
+	PhoneCodeMaxAttempts = 5
+)
+
+// SMSProvider sends text messages. to is an E.164 number.
+type SMSProvider interface {
+	SendSMS(ctx context.Context, to, body string) error
+}
+
+// NewSMSProvider returns the provider named by SMS_PROVIDER, capture by default so
+// nothing is sent by accident locally
+func NewSMSProvider() (SMSProvider, error) {
+	switch provider := os.Getenv("SMS_PROVIDER"); provider {
+	case SMSProviderTwilio:
+		sms := &TwilioSMS{
+			AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+			From:       os.Getenv("TWILIO_FROM"),
+			Client:     &http.Client{Timeout: 10 * time.Second},
+		}
+		if sms.AccountSID == "" || sms.AuthToken == "" || sms.From == "" {
+			return nil, errors.New("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM must be configured")
+		}
+		return sms, nil
+	case "", SMSProviderCapture:
+		return &CaptureSMS{}, nil
+	default:
+		return nil, errors.New("unknown SMS provider " + provider)
+	}
+}
+
+// TwilioSMS sends through Twilio's Messages API
+type TwilioSMS struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	Client     *http.Client
+}
+
+func (t *TwilioSMS) SendSMS(ctx context.Context, to, body string) error {
+	endpoint := "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(t.AccountSID) + "/Messages.json"
+	form := url.Values{"To": {to}, "From": {t.From}, "Body": {body}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return errors.New("twilio: " + resp.Status + ": " + string(snippet))
+	}
+	return nil
+}
+
+type SMSMessage struct {
+	To   string
+	Body string
+}
+
+// CaptureSMS keeps every message in memory instead of sending it. Use it in tests and
+// local runs.
+type CaptureSMS struct {
+	mu       sync.Mutex
+	messages []SMSMessage
+}
+
+func (c *CaptureSMS) SendSMS(ctx context.Context, to, body string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, SMSMessage{To: to, Body: body})
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (c *CaptureSMS) Messages() []SMSMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]SMSMessage(nil), c.messages...)
+}
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// PhoneNumber checks an E.164 phone number, e.g. +14035550123
+func PhoneNumber(field, value string) Rule {
+	return func() *FieldError {
+		if value != "" && !e164.MatchString(value) {
+			return &FieldError{Field: field, Code: CodeInvalid, Message: "must be an E.164 phone number, e.g. +14035550123"}
+		}
+		return nil
+	}
+}
+
+// NewPhoneCode generates a six digit verification code
+func NewPhoneCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	code := strconv.FormatInt(n.Int64(), 10)
+	return strings.Repeat("0", 6-len(code)) + code, nil
+}
+
+// PhoneCodeHash is what is stored for a verification code, bound to the user and number
+func PhoneCodeHash(userUUID, phone, code string) string {
+	key := sha256.Sum256([]byte("phone:" + os.Getenv("JWT_SECRET")))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(userUUID + ":" + phone + ":" + code))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,170 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Social post statuses stored in social_posts.status
+const (
+	SocialPosted  = "posted"
+	SocialRemoved = "removed"
+	SocialFailed  = "failed"
+)
+
+// SocialMaxAttempts is how many times publishing a listing to a channel is tried
+const SocialMaxAttempts = 5
+
+// maxPostDescription keeps posts within the shortest channel's caption limit
+const maxPostDescription = 300
+
+// SocialPost is a lost pet listing formatted for social media
+type SocialPost struct {
+	ListingID int    `json:"listing_id"`
+	Text      string `json:"text"`
+	ImageURL  string `json:"image_url,omitempty"`
+	Link      string `json:"link"`
+}
+
+// Publisher posts to one social media channel. Publish returns the channel's ID for
+// the post, which Delete takes to remove it again, and a link to it if the channel has one.
+type Publisher interface {
+	Publish(ctx context.Context, post SocialPost) (externalID, postURL string, err error)
+	Delete(ctx context.Context, externalID string) error
+}
+
+var publisherFactories = map[string]func() (Publisher, error){
+	"file": newFilePublisher,
+}
+
+// RegisterPublisher makes a channel available to SOCIAL_CHANNELS. The factory reads the
+// channel's own configuration (tokens, page IDs) from the environment.
+func RegisterPublisher(channel string, factory func() (Publisher, error)) {
+	publisherFactories[channel] = factory
+}
+
+// Publishers returns the channels listed in SOCIAL_CHANNELS (comma separated, e.g.
+// "file"), keyed by channel name
+func Publishers() (map[string]Publisher, error) {
+	publishers := map[string]Publisher{}
+	for _, channel := range strings.Split(os.Getenv("SOCIAL_CHANNELS"), ",") {
+		channel = strings.TrimSpace(channel)
+		if channel == "" {
+			continue
+		}
+		factory, ok := publisherFactories[channel]
+		if !ok {
+			return nil, errors.New("unknown social channel " + channel)
+		}
+		publisher, err := factory()
+		if err != nil {
+			return nil, errors.New(channel + ": " + err.Error())
+		}
+		publishers[channel] = publisher
+	}
+	return publishers, nil
+}
+
+// LostPetPost holds the listing fields a social post is made from
+type LostPetPost struct {
+	ID          int
+	PetName     string
+	AnimalType  string
+	Breed       []string
+	Color       []string
+	Description string
+	DateLost    time.Time
+	Area        string
+	ImageURLs   []string
+}
+
+// FormatLostPetPost writes the post text for a listing, linking to its public page
+func FormatLostPetPost(listing LostPetPost, siteURL string) SocialPost {
+	link := strings.TrimSuffix(siteURL, "/") + "/pet/" + strconv.Itoa(listing.ID)
+
+	lines := []string{"LOST " + strings.ToUpper(listing.AnimalType) + ": " + listing.PetName}
+	if details := strings.Join(append(append([]string{}, listing.Breed...), listing.Color...), ", "); details != "" {
+		lines = append(lines, details)
+	}
+	seen := "Missing since " + listing.DateLost.Format("January 2, 2006")
+	if listing.Area != "" {
+		seen = "Last seen near " + listing.Area + " on " + listing.DateLost.Format("January 2, 2006")
+	}
+	lines = append(lines, seen)
+	if description := strings.TrimSpace(listing.Description); description != "" {
+		if runes := []rune(description); len(runes) > maxPostDescription {
+			description = strings.TrimSpace(string(runes[:maxPostDescription])) + "..."
+		}
+		lines = append(lines, "", description)
+	}
+	lines = append(lines, "", "Seen them? Share a sighting: "+link, "#Lost"+strings.ReplaceAll(listing.AnimalType, " ", ""))
+
+	post := SocialPost{ListingID: listing.ID, Text: strings.Join(lines, "\n"), Link: link}
+	if len(listing.ImageURLs) > 0 {
+		post.ImageURL = listing.ImageURLs[0]
+	}
+	return post
+}
+
+// PublicArea describes where a pet was last seen at the anonymous privacy level, for
+// flyers and posts: the street (unless the level hides it), city and province
+func PublicArea(level string, street, city, province *string) string {
+	var parts []string
+	if street != nil {
+		if public := FuzzAddress(AnonymousPrivacy(level), *street); public != "" {
+			parts = append(parts, public)
+		}
+	}
+	for _, part := range []*string{city, province} {
+		if part != nil && *part != "" {
+			parts = append(parts, *part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// filePublisher is the "file" channel. It writes each post as a JSON file in
+// SOCIAL_FILE_DIR (default /tmp/social-posts) so the pipeline can be run and checked
+// without a real network.
+type filePublisher struct {
+	dir string
+}
+
+func newFilePublisher() (Publisher, error) {
+	dir := os.Getenv("SOCIAL_FILE_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "social-posts")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &filePublisher{dir: dir}, nil
+}
+
+func (p *filePublisher) Publish(ctx context.Context, post SocialPost) (string, string, error) {
+	body, err := json.MarshalIndent(post, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	externalID := "listing-" + strconv.Itoa(post.ListingID) + "-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	path := filepath.Join(p.dir, externalID+".json")
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return "", "", err
+	}
+	return externalID, "file://" + path, nil
+}
+
+func (p *filePublisher) Delete(ctx context.Context, externalID string) error {
+	// externalID comes from our own table, but keep it inside dir regardless
+	err := os.Remove(filepath.Join(p.dir, filepath.Base(externalID)+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
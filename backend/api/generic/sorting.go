@@ -0,0 +1,92 @@
+package generic
+
+import (
+	"strconv"
+)
+
+// Sort names shared by both listing feeds
+const (
+	SortCreated   = "created"
+	SortUpdated   = "updated"
+	SortDistance  = "distance"
+	SortRelevance = "relevance"
+)
+
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// WithOrder applies the order query parameter to sort. An empty order keeps the
+// sort's natural direction. The direction is folded into the name so a cursor
+// can't be replayed against the opposite ordering.
+func WithOrder(sort SortKey, order string) (SortKey, error) {
+	switch order {
+	case "":
+	case OrderAsc:
+		sort.Desc = false
+	case OrderDesc:
+		sort.Desc = true
+	default:
+		return SortKey{}, &ValidationError{Message: "must be one of: asc, desc", Field: "order"}
+	}
+
+	if sort.Desc {
+		sort.Name += ":" + OrderDesc
+	} else {
+		sort.Name += ":" + OrderAsc
+	}
+	return sort, nil
+}
+
+// ParseOrigin reads the lat/lng query parameters used for distance sorting
+func ParseOrigin(params map[string]string) (float64, float64, error) {
+	lat, latErr := strconv.ParseFloat(params["lat"], 64)
+	lng, lngErr := strconv.ParseFloat(params["lng"], 64)
+
+	errs := Validate(
+		RequiredValue("lat", latErr == nil),
+		RequiredValue("lng", lngErr == nil),
+	)
+	if latErr == nil {
+		errs = append(errs, Validate(InRange("lat", lat, -90, 90))...)
+	}
+	if lngErr == nil {
+		errs = append(errs, Validate(InRange("lng", lng, -180, 180))...)
+	}
+	if len(errs) > 0 {
+		return 0, 0, errs
+	}
+
+	return lat, lng, nil
+}
+
+// GeogPoint returns a geography point expression for a lat/lng pair of SQL expressions,
+// comparable with locations.geog
+func GeogPoint(lat, lng string) string {
+	return `ST_SetSRID(ST_MakePoint(` + lng + `, ` + lat + `), 4326)::geography`
+}
+
+// GeogParam returns a geography point expression for the lat/lng bound to placeholders
+// latPos and lngPos
+func GeogParam(latPos, lngPos int) string {
+	return GeogPoint("$"+strconv.Itoa(latPos)+"::float8", "$"+strconv.Itoa(lngPos)+"::float8")
+}
+
+// DistanceKm returns the distance expression in kilometres between two geographies
+func DistanceKm(geog1, geog2 string) string {
+	return `(ST_Distance(` + geog1 + `, ` + geog2 + `) / 1000)`
+}
+
+// WithinKm returns a condition that two geographies are at most km kilometres apart.
+// Unlike comparing DistanceKm, it can be answered from a spatial index on either side.
+func WithinKm(geog1, geog2, km string) string {
+	return `ST_DWithin(` + geog1 + `, ` + geog2 + `, (` + km + `) * 1000)`
+}
+
+// NearestFirst returns the distance expression in metres to order rows nearest first
+// with. It is the <-> operator so the order can be read from a spatial index on geog,
+// and measures on a sphere rather than the spheroid, a little off DistanceKm.
+func NearestFirst(geog, point string) string {
+	return `(` + geog + ` <-> ` + point + `)`
+}
@@ -9,7 +9,13 @@ import (
 
 func SupabaseConnect() (*pgx.Conn, error) {
 
-	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	config, err := pgx.ParseConfig(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return nil, err
+	}
+	config.Tracer = queryTracer{}
+
+	conn, err := pgx.ConnectConfig(context.Background(), config)
 	if err != nil {
 		return nil, err
 	}
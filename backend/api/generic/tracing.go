@@ -0,0 +1,100 @@
+package generic
+
+import (
+	"context"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/MaiTra10/HackTheChange2025/backend/api"
+
+var (
+	attrRequestID = attribute.Key("faas.invocation_id")
+	attrUserUUID  = attribute.Key("enduser.id")
+	attrRoute     = attribute.Key("http.route")
+	attrStatus    = attribute.Key("http.response.status_code")
+	attrDBQuery   = attribute.Key("db.query.text")
+	attrDBRows    = attribute.Key("db.response.rows_affected")
+)
+
+// initTracing installs a tracer provider that exports over OTLP/HTTP when
+// OTEL_EXPORTER_OTLP_ENDPOINT (or the traces-specific variant) is set. Without
+// an endpoint spans are still created so trace IDs appear in the logs, but
+// nothing is exported. The returned function flushes pending spans.
+func initTracing(service string) func(context.Context) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	resource, _ := sdkresource.Merge(sdkresource.Default(), sdkresource.NewSchemaless(
+		semconv.ServiceName(service),
+	))
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(resource)}
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		exporter, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			Logger.Error("failed to create OTLP exporter", "error", err.Error())
+		} else {
+			// Lambda freezes between invocations, so spans are batched and flushed per request
+			options = append(options, sdktrace.WithBatcher(exporter))
+		}
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) {
+		if err := provider.ForceFlush(ctx); err != nil {
+			Logger.Error("failed to flush spans", "error", err.Error())
+		}
+	}
+}
+
+// StartSpan starts a child span of whatever span is in ctx. Callers must End the span.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func SpanFromContext(ctx context.Context) trace.Span {
+	return trace.SpanFromContext(ctx)
+}
+
+// EndSpan records err on span (if any) before ending it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// queryTracer wraps every pgx query in a span
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = StartSpan(ctx, "db.query",
+		semconv.DBSystemPostgreSQL,
+		attrDBQuery.String(data.SQL),
+	)
+	return ctx
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := SpanFromContext(ctx)
+	span.SetAttributes(attrDBRows.Int64(data.CommandTag.RowsAffected()))
+
+	err := data.Err
+	if err == pgx.ErrNoRows {
+		err = nil
+	}
+	EndSpan(span, err)
+}
@@ -0,0 +1,127 @@
+package generic
+
+import (
+	"math"
+	"time"
+)
+
+// Kinds of point on a lost pet's trail
+const (
+	TrailLastSeen    = "last_seen"
+	TrailSighting    = "sighting"
+	TrailOwnerUpdate = "owner_update"
+)
+
+// A predicted search area starts as a circle of MinSearchRadiusKm around the latest point
+// on the trail. It grows with the time since at the speed the pet moved along its last
+// TrailHeadingPoints points, or at DefaultRoamKmh without a trail, up to MaxSearchRadiusKm.
+// When those points head somewhere it is an ellipse pushed ahead in that direction.
+const (
+	MinSearchRadiusKm  = 0.5
+	MaxSearchRadiusKm  = 25
+	DefaultRoamKmh     = 0.5
+	MaxTravelKmh       = 5
+	TrailHeadingPoints = 3
+	// Legs shorter than this count as this long, since dates without a time of day put
+	// several points on the same midnight
+	MinTrailLegHours = 1
+)
+
+// searchAreaVertices is how many points the search area polygon is drawn with
+const searchAreaVertices = 32
+
+const kmPerDegree = metersPerDegree / 1000
+
+// TrailPoint is a place a lost pet was seen, and when
+type TrailPoint struct {
+	Lat    float64
+	Lng    float64
+	SeenAt time.Time
+}
+
+// SearchArea is where to look for a lost pet next: an ellipse around the center,
+// stretched along the heading the pet was last travelling in. Without a heading it is a
+// circle.
+type SearchArea struct {
+	CenterLat    float64
+	CenterLng    float64
+	HeadingDeg   *float64
+	SpeedKmh     float64
+	ElapsedHours float64
+	SemiMajorKm  float64
+	SemiMinorKm  float64
+}
+
+// PredictSearchArea returns the search area for a trail in time order as of now, or nil
+// for an empty trail
+func PredictSearchArea(points []TrailPoint, now time.Time) *SearchArea {
+	if len(points) == 0 {
+		return nil
+	}
+	last := points[len(points)-1]
+	area := &SearchArea{CenterLat: last.Lat, CenterLng: last.Lng, SpeedKmh: DefaultRoamKmh}
+	area.ElapsedHours = math.Max(now.Sub(last.SeenAt).Hours(), 0)
+
+	recent := points[max(0, len(points)-TrailHeadingPoints):]
+	first := recent[0]
+	if len(recent) > 1 {
+		pathKm := 0.0
+		for i := 1; i < len(recent); i++ {
+			pathKm += distanceKm(recent[i-1].Lat, recent[i-1].Lng, recent[i].Lat, recent[i].Lng)
+		}
+		hours := math.Max(last.SeenAt.Sub(first.SeenAt).Hours(), MinTrailLegHours*float64(len(recent)-1))
+		area.SpeedKmh = math.Min(pathKm/hours, MaxTravelKmh)
+
+		// A pet that ended up about where it started is circling, not heading anywhere
+		if distanceKm(first.Lat, first.Lng, last.Lat, last.Lng) >= MinSearchRadiusKm {
+			heading := bearingDeg(first.Lat, first.Lng, last.Lat, last.Lng)
+			area.HeadingDeg = &heading
+		}
+	}
+
+	reachKm := area.SpeedKmh * area.ElapsedHours
+	if area.HeadingDeg == nil {
+		area.SemiMajorKm = math.Min(MinSearchRadiusKm+reachKm, MaxSearchRadiusKm)
+		area.SemiMinorKm = area.SemiMajorKm
+		return area
+	}
+
+	// Ahead of the pet it may have kept going, so the area is centred half way to how far
+	// it could have got and is half as wide as it is long
+	area.SemiMajorKm = math.Min(MinSearchRadiusKm+reachKm/2, MaxSearchRadiusKm)
+	area.SemiMinorKm = math.Min(MinSearchRadiusKm+reachKm/4, MaxSearchRadiusKm)
+	area.CenterLat, area.CenterLng = offsetKm(last.Lat, last.Lng, *area.HeadingDeg, area.SemiMajorKm-MinSearchRadiusKm, 0)
+	return area
+}
+
+// Polygon returns the outline of the area as a closed GeoJSON ring of [longitude, latitude]
+// positions
+func (a SearchArea) Polygon() [][]float64 {
+	heading := 0.0
+	if a.HeadingDeg != nil {
+		heading = *a.HeadingDeg
+	}
+	ring := make([][]float64, 0, searchAreaVertices+1)
+	for i := 0; i < searchAreaVertices; i++ {
+		angle := 2 * math.Pi * float64(i) / searchAreaVertices
+		lat, lng := offsetKm(a.CenterLat, a.CenterLng, heading, a.SemiMajorKm*math.Cos(angle), a.SemiMinorKm*math.Sin(angle))
+		ring = append(ring, []float64{lng, lat})
+	}
+	return append(ring, ring[0])
+}
+
+// bearingDeg is the compass heading from one point to another, in degrees clockwise from
+// north. Trails are short enough to treat the ground as flat.
+func bearingDeg(lat1, lng1, lat2, lng2 float64) float64 {
+	north := lat2 - lat1
+	east := (lng2 - lng1) * math.Cos((lat1+lat2)/2*math.Pi/180)
+	return math.Mod(math.Atan2(east, north)*180/math.Pi+360, 360)
+}
+
+// offsetKm moves a point ahead km along a heading and right km across it
+func offsetKm(lat, lng, headingDeg, ahead, right float64) (float64, float64) {
+	h := headingDeg * math.Pi / 180
+	north := ahead*math.Cos(h) - right*math.Sin(h)
+	east := ahead*math.Sin(h) + right*math.Cos(h)
+	return lat + north/kmPerDegree, lng + east/(kmPerDegree*math.Max(math.Cos(lat*math.Pi/180), 0.01))
+}
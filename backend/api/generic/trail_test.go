@@ -0,0 +1,103 @@
+package generic
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPredictSearchArea(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(hoursAgo float64) time.Time {
+		return now.Add(-time.Duration(hoursAgo * float64(time.Hour)))
+	}
+	// north returns a point km north of the start of every trail
+	north := func(km float64, hoursAgo float64) TrailPoint {
+		lat, lng := offsetKm(51, -114, 0, km, 0)
+		return TrailPoint{Lat: lat, Lng: lng, SeenAt: at(hoursAgo)}
+	}
+
+	tests := []struct {
+		name        string
+		points      []TrailPoint
+		wantHeading bool
+		wantSpeed   float64
+		wantMajor   float64
+		wantMinor   float64
+	}{
+		{"just seen", []TrailPoint{north(0, 0)}, false, DefaultRoamKmh, MinSearchRadiusKm, MinSearchRadiusKm},
+		{"seen 4 hours ago", []TrailPoint{north(0, 4)}, false, DefaultRoamKmh, 2.5, 2.5},
+		{"seen in the future", []TrailPoint{north(0, -4)}, false, DefaultRoamKmh, MinSearchRadiusKm, MinSearchRadiusKm},
+		{"seen long ago", []TrailPoint{north(0, 1000)}, false, DefaultRoamKmh, MaxSearchRadiusKm, MaxSearchRadiusKm},
+		{"heading north", []TrailPoint{north(0, 6), north(2, 4), north(4, 2)}, true, 1, 1.5, 1},
+		{"too fast", []TrailPoint{north(0, 2), north(40, 1)}, true, MaxTravelKmh, 3, 1.75},
+		{"same midnight", []TrailPoint{north(0, 2), north(2, 2)}, true, 2, 2.5, 1.5},
+		{"circling", []TrailPoint{north(0, 4), north(2, 3), north(0.1, 2)}, false, 1.95, 4.4, 4.4},
+		{"only the last points count", []TrailPoint{north(-50, 40), north(0, 4), north(2, 3), north(0.1, 2)}, false, 1.95, 4.4, 4.4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			area := PredictSearchArea(tt.points, now)
+			if area == nil {
+				t.Fatal("got no area")
+			}
+			if (area.HeadingDeg != nil) != tt.wantHeading {
+				t.Fatalf("got heading %v, want a heading: %v", area.HeadingDeg, tt.wantHeading)
+			}
+			if tt.wantHeading && math.Abs(*area.HeadingDeg) > 0.01 {
+				t.Errorf("got heading %.2f, want 0", *area.HeadingDeg)
+			}
+			for _, got := range []struct {
+				what      string
+				got, want float64
+			}{
+				{"speed", area.SpeedKmh, tt.wantSpeed},
+				{"semi-major axis", area.SemiMajorKm, tt.wantMajor},
+				{"semi-minor axis", area.SemiMinorKm, tt.wantMinor},
+			} {
+				if math.Abs(got.got-got.want) > 0.01 {
+					t.Errorf("got %s %.3f, want %.3f", got.what, got.got, got.want)
+				}
+			}
+
+			// A heading pushes the area ahead of the last point, otherwise it is centred on it
+			last := tt.points[len(tt.points)-1]
+			ahead := area.SemiMajorKm - MinSearchRadiusKm
+			if !tt.wantHeading {
+				ahead = 0
+			}
+			if got := distanceKm(last.Lat, last.Lng, area.CenterLat, area.CenterLng); math.Abs(got-ahead) > 0.01 {
+				t.Errorf("got center %.3f km from the last point, want %.3f", got, ahead)
+			}
+		})
+	}
+}
+
+func TestPredictSearchAreaEmpty(t *testing.T) {
+	if area := PredictSearchArea(nil, time.Now()); area != nil {
+		t.Errorf("got %+v, want nil", area)
+	}
+}
+
+func TestSearchAreaPolygon(t *testing.T) {
+	heading := 45.0
+	for _, area := range []SearchArea{
+		{CenterLat: 51, CenterLng: -114, SemiMajorKm: 2, SemiMinorKm: 2},
+		{CenterLat: 51, CenterLng: -114, HeadingDeg: &heading, SemiMajorKm: 3, SemiMinorKm: 1},
+	} {
+		ring := area.Polygon()
+		if len(ring) != searchAreaVertices+1 {
+			t.Fatalf("got %d positions, want %d", len(ring), searchAreaVertices+1)
+		}
+		if ring[0][0] != ring[len(ring)-1][0] || ring[0][1] != ring[len(ring)-1][1] {
+			t.Errorf("ring isn't closed: %v to %v", ring[0], ring[len(ring)-1])
+		}
+		for _, position := range ring {
+			got := distanceKm(area.CenterLat, area.CenterLng, position[1], position[0])
+			if got < area.SemiMinorKm-0.01 || got > area.SemiMajorKm+0.01 {
+				t.Errorf("position %v is %.3f km from the center, outside %.1f to %.1f km",
+					position, got, area.SemiMinorKm, area.SemiMajorKm)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package generic
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stable error codes returned to clients for field violations
+const (
+	CodeInvalid     = "invalid"
+	CodeRequired    = "required"
+	CodeInvalidEnum = "invalid_enum"
+	CodeOutOfRange  = "out_of_range"
+	CodeInvalidDate = "invalid_date"
+	CodeFutureDate  = "future_date"
+	CodeTooLong     = "too_long"
+)
+
+var (
+	AnimalTypes = []string{"Dog", "Cat", "Bunny", "Bird", "Other"}
+	Genders     = []string{"Male", "Female", "Unknown"}
+)
+
+const MaxDescriptionLength = 2000
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Rule checks a single field and returns nil when the value is acceptable
+type Rule func() *FieldError
+
+// Validate runs every rule and collects all violations instead of stopping at the first
+func Validate(rules ...Rule) ValidationErrors {
+	var errs ValidationErrors
+	for _, rule := range rules {
+		if fieldErr := rule(); fieldErr != nil {
+			errs = append(errs, *fieldErr)
+		}
+	}
+	return errs
+}
+
+func Required(field, value string) Rule {
+	return func() *FieldError {
+		if strings.TrimSpace(value) == "" {
+			return &FieldError{Field: field, Code: CodeRequired, Message: "is required"}
+		}
+		return nil
+	}
+}
+
+func RequiredList(field string, values []string) Rule {
+	return func() *FieldError {
+		if len(values) == 0 {
+			return &FieldError{Field: field, Code: CodeRequired, Message: "at least one value is required"}
+		}
+		return nil
+	}
+}
+
+func RequiredValue(field string, present bool) Rule {
+	return func() *FieldError {
+		if !present {
+			return &FieldError{Field: field, Code: CodeRequired, Message: "is required"}
+		}
+		return nil
+	}
+}
+
+// OneOf skips empty values so it can be combined with Required for mandatory enums
+func OneOf(field, value string, allowed []string) Rule {
+	return func() *FieldError {
+		if value == "" {
+			return nil
+		}
+		for _, option := range allowed {
+			if value == option {
+				return nil
+			}
+		}
+		return &FieldError{
+			Field:   field,
+			Code:    CodeInvalidEnum,
+			Message: "must be one of: " + strings.Join(allowed, ", "),
+		}
+	}
+}
+
+func InRange(field string, value, min, max float64) Rule {
+	return func() *FieldError {
+		if value < min || value > max {
+			return &FieldError{
+				Field:   field,
+				Code:    CodeOutOfRange,
+				Message: fmt.Sprintf("must be between %g and %g", min, max),
+			}
+		}
+		return nil
+	}
+}
+
+func MaxLength(field, value string, max int) Rule {
+	return func() *FieldError {
+		if len([]rune(value)) > max {
+			return &FieldError{
+				Field:   field,
+				Code:    CodeTooLong,
+				Message: fmt.Sprintf("must be at most %d characters", max),
+			}
+		}
+		return nil
+	}
+}
+
+// PastDate checks that value parses as a date and is not in the future. Empty values are skipped.
+func PastDate(field, value string) Rule {
+	return func() *FieldError {
+		if value == "" {
+			return nil
+		}
+		date, err := ParseDate(value)
+		if err != nil {
+			return &FieldError{Field: field, Code: CodeInvalidDate, Message: "must be an RFC3339 timestamp or YYYY-MM-DD date"}
+		}
+		if date.After(time.Now()) {
+			return &FieldError{Field: field, Code: CodeFutureDate, Message: "must not be in the future"}
+		}
+		return nil
+	}
+}
+
+func ParseDate(dateStr string) (time.Time, error) {
+	dateFormats := []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02",
+		time.RFC3339Nano,
+	}
+
+	var err error
+	for _, format := range dateFormats {
+		var date time.Time
+		if date, err = time.Parse(format, dateStr); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, err
+}
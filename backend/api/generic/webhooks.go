@@ -0,0 +1,189 @@
+package generic
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Webhook event types. Each listing history action except purge has one.
+const (
+	EventLostListingCreated  = "lost_listing.created"
+	EventLostListingUpdated  = "lost_listing.updated"
+	EventLostListingFound    = "lost_listing.found"
+	EventLostListingUnfound  = "lost_listing.unfound"
+	EventLostListingDeleted  = "lost_listing.deleted"
+	EventLostListingRestored = "lost_listing.restored"
+	EventSightingCreated     = "sighting.created"
+	EventSightingUpdated     = "sighting.updated"
+	EventSightingFound       = "sighting.found"
+	EventSightingUnfound     = "sighting.unfound"
+	EventSightingDeleted     = "sighting.deleted"
+	EventSightingRestored    = "sighting.restored"
+)
+
+var EventTypes = []string{
+	EventLostListingCreated, EventLostListingUpdated, EventLostListingFound,
+	EventLostListingUnfound, EventLostListingDeleted, EventLostListingRestored,
+	EventSightingCreated, EventSightingUpdated, EventSightingFound,
+	EventSightingUnfound, EventSightingDeleted, EventSightingRestored,
+}
+
+var eventSuffixes = map[string]string{
+	ActionCreate:  "created",
+	ActionUpdate:  "updated",
+	ActionFound:   "found",
+	ActionUnfound: "unfound",
+	ActionDelete:  "deleted",
+	ActionRestore: "restored",
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookMaxAttempts is how many times a delivery is tried before it is marked failed
+const WebhookMaxAttempts = 8
+
+// Retries back off exponentially from WebhookRetryBase, capped at WebhookRetryMax
+const (
+	WebhookRetryBase = 30 * time.Second
+	WebhookRetryMax  = 6 * time.Hour
+)
+
+// WebhookRetention is how long dispatched events and their delivery log are kept
+const WebhookRetention = 30 * 24 * time.Hour
+
+// WebhookEvent is the JSON body POSTed to webhook endpoints
+type WebhookEvent struct {
+	ID        int64            `json:"id"`
+	Type      string           `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      WebhookEventData `json:"data"`
+}
+
+type WebhookEventData struct {
+	ListingType string  `json:"listing_type"`
+	ListingID   int     `json:"listing_id"`
+	Changes     Changes `json:"changes,omitempty"`
+}
+
+// eventColumns are the listing columns webhook events carry changes to. Locations, pet
+// IDs and owner settings are left out: location IDs are shared with sightings and
+// organizations shown at their exact point, so they would give away where a private
+// listing is.
+var eventColumns = map[string]bool{
+	"pet_name":     true,
+	"animal_type":  true,
+	"gender":       true,
+	"breed":        true,
+	"color":        true,
+	"age":          true,
+	"description":  true,
+	"date_lost":    true,
+	"date_spotted": true,
+	"is_found":     true,
+	"date_found":   true,
+}
+
+// ListingEvent returns the webhook event type for a listing history action, or "" for
+// actions that don't emit one
+func ListingEvent(listingType, action string) string {
+	suffix, ok := eventSuffixes[action]
+	if !ok {
+		return ""
+	}
+	if listingType == ListingTypeSighting {
+		return "sighting." + suffix
+	}
+	return "lost_listing." + suffix
+}
+
+// RecordEvent writes the webhook event for a listing change to the outbox. Call it with
+// the transaction making the change, next to RecordHistory, so an event exists exactly
+// when the change is committed; webhook-dispatch delivers it from there. The event only
+// carries the changes to eventColumns, and an update that changes none of them has no
+// event.
+func RecordEvent(ctx context.Context, db Execer, listingType string, listingID any, action string, changes Changes) error {
+	eventType := ListingEvent(listingType, action)
+	if eventType == "" {
+		return nil
+	}
+
+	public := Changes{}
+	for column, change := range changes {
+		if eventColumns[column] {
+			public[column] = change
+		}
+	}
+	if action == ActionUpdate && len(public) == 0 {
+		return nil
+	}
+
+	var changesJSON []byte
+	if len(public) > 0 {
+		var err error
+		if changesJSON, err = json.Marshal(public); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO event_outbox (event_type, listing_type, listing_id, changes, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := db.Exec(ctx, query, eventType, listingType, listingID, changesJSON, time.Now())
+	return err
+}
+
+// SignWebhook returns the X-Webhook-Signature header for a body sent at timestamp:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>">". Receivers should
+// recompute it with their secret and reject old timestamps to stop replays.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix + "."))
+	mac.Write(body)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookBackoff is the wait before retrying a delivery that has failed attempts times
+func WebhookBackoff(attempts int) time.Duration {
+	wait := WebhookRetryBase
+	for i := 1; i < attempts && wait < WebhookRetryMax; i++ {
+		wait *= 2
+	}
+	return min(wait, WebhookRetryMax)
+}
+
+// NewWebhookSecret generates a signing secret for a webhook endpoint
+func NewWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// WebhookURL checks that value is an absolute https URL. Empty values are skipped.
+func WebhookURL(field, value string) Rule {
+	return func() *FieldError {
+		if value == "" {
+			return nil
+		}
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return &FieldError{Field: field, Code: CodeInvalid, Message: "must be an https URL"}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,261 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PushTTL is how long a push service holds a notification for an offline browser
+const PushTTL = 24 * time.Hour
+
+// Push services accept bodies up to pushRecordSize, sent as one aes128gcm record. The
+// header, padding delimiter and tag leave maxPushPayload bytes for the payload.
+const (
+	pushRecordSize = 4096
+	maxPushPayload = pushRecordSize - 86 - 17
+)
+
+// ErrPushGone means the browser unsubscribed, so the subscription should be deleted
+var ErrPushGone = errors.New("push subscription has expired or was removed")
+
+// PushSubscription is a browser's PushSubscription: its push service endpoint and the
+// keys its payloads are encrypted with, base64url encoded
+type PushSubscription struct {
+	ID       int    `json:"id"`
+	Endpoint string `json:"endpoint"`
+	P256dh   string `json:"-"`
+	Auth     string `json:"-"`
+}
+
+// PushSender delivers a payload to one subscription
+type PushSender interface {
+	Push(ctx context.Context, sub PushSubscription, payload []byte, urgent bool) error
+}
+
+// NewPushSender returns a Web Push sender when VAPID_PRIVATE_KEY is set, and a
+// capturing sender otherwise so nothing is sent by accident locally
+func NewPushSender() (PushSender, error) {
+	if os.Getenv("VAPID_PRIVATE_KEY") == "" {
+		return &CapturePush{}, nil
+	}
+	return NewWebPushSender(os.Getenv("VAPID_PRIVATE_KEY"), os.Getenv("VAPID_SUBJECT"))
+}
+
+// WebPushSender sends Web Push messages (RFC 8030) with VAPID authentication
+// (RFC 8292) and aes128gcm payload encryption (RFC 8291)
+type WebPushSender struct {
+	key       *ecdsa.PrivateKey
+	publicKey string
+	subject   string
+	client    *http.Client
+}
+
+// NewWebPushSender takes the base64url VAPID private key (the raw 32-byte P-256
+// scalar) and a mailto: or https: contact for push services
+func NewWebPushSender(privateKey, subject string) (*WebPushSender, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, errors.New("VAPID_PRIVATE_KEY is not base64url: " + err.Error())
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, errors.New("VAPID_PRIVATE_KEY is not a P-256 key: " + err.Error())
+	}
+	if subject == "" {
+		return nil, errors.New("VAPID_SUBJECT is not configured")
+	}
+	public, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &WebPushSender{
+		key:       key,
+		publicKey: base64.RawURLEncoding.EncodeToString(public),
+		subject:   subject,
+		client:    PublicClient(10 * time.Second),
+	}, nil
+}
+
+func (w *WebPushSender) Push(ctx context.Context, sub PushSubscription, payload []byte, urgent bool) error {
+	body, err := encryptPush(sub, payload)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+	token, err := w.vapidToken(endpoint.Scheme + "://" + endpoint.Host)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "vapid t="+token+", k="+w.publicKey)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(PushTTL.Seconds())))
+	if urgent {
+		req.Header.Set("Urgency", "high")
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrPushGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return errors.New("push service: " + resp.Status + ": " + string(snippet))
+	}
+	return nil
+}
+
+// vapidToken signs the ES256 JWT that identifies us to the push service at audience
+func (w *WebPushSender) vapidToken(audience string) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": w.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, w.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// encryptPush encrypts payload for a subscription as a single aes128gcm record
+// (RFC 8188) with the key derivation of RFC 8291
+func encryptPush(sub PushSubscription, payload []byte) ([]byte, error) {
+	clientPublic, err := base64.RawURLEncoding.DecodeString(sub.P256dh)
+	if err != nil {
+		return nil, errors.New("invalid subscription p256dh key")
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(sub.Auth)
+	if err != nil || len(authSecret) != 16 {
+		return nil, errors.New("invalid subscription auth secret")
+	}
+	clientKey, err := ecdh.P256().NewPublicKey(clientPublic)
+	if err != nil {
+		return nil, errors.New("invalid subscription p256dh key")
+	}
+	if len(payload) > maxPushPayload {
+		return nil, errors.New("push payload is too large")
+	}
+
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := serverKey.ECDH(clientKey)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), clientPublic...), serverPublic...)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	contentKey, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 marks the last (and only) record, with no padding
+	ciphertext := gcm.Seal(nil, nonce, append(append([]byte{}, payload...), 0x02), nil)
+
+	header := make([]byte, 0, 16+4+1+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+	return append(header, ciphertext...), nil
+}
+
+type CapturedPush struct {
+	Subscription PushSubscription
+	Payload      []byte
+	Urgent       bool
+}
+
+// CapturePush keeps every push in memory instead of sending it. Use it in tests and
+// local runs; set Gone to make pushes to an endpoint fail with ErrPushGone.
+type CapturePush struct {
+	Gone map[string]bool
+
+	mu     sync.Mutex
+	pushes []CapturedPush
+}
+
+func (c *CapturePush) Push(ctx context.Context, sub PushSubscription, payload []byte, urgent bool) error {
+	if c.Gone[sub.Endpoint] {
+		return ErrPushGone
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pushes = append(c.pushes, CapturedPush{Subscription: sub, Payload: payload, Urgent: urgent})
+	return nil
+}
+
+// Pushes returns the pushes sent so far
+func (c *CapturePush) Pushes() []CapturedPush {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CapturedPush(nil), c.pushes...)
+}
@@ -9,7 +9,6 @@ import (
 
 	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/lestrrat-go/jwx/v3/jwa"
 	"github.com/lestrrat-go/jwx/v3/jwt"
 	"google.golang.org/api/idtoken"
@@ -22,11 +21,11 @@ type GoogleLoginRequest struct {
 
 func main() {
 
-	lambda.Start(handler)
+	generic.Start("google-log-in", handler)
 
 }
 
-func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// Handle OPTIONS preflight requests
 	if request.HTTPMethod == "OPTIONS" {
 		return generic.Response(http.StatusOK, generic.Json{})
@@ -34,26 +33,28 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 	// Parse JSON body
 	var req GoogleLoginRequest
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
-		return generic.Response(http.StatusBadRequest, generic.Json{"error": "invalid request body"})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
 	}
 	// Validate Google ID token
 	clientID := os.Getenv("GOOGLE_CLIENT_ID")
-	payload, err := idtoken.Validate(context.Background(), req.Token, clientID)
+	validateCtx, span := generic.StartSpan(ctx, "google.idtoken.validate")
+	payload, err := idtoken.Validate(validateCtx, req.Token, clientID)
+	generic.EndSpan(span, err)
 	if err != nil {
-		return generic.Response(http.StatusUnauthorized, generic.Json{"error": "invalid Google token", "message": err.Error()})
+		return generic.ErrorResponse(ctx, request, &generic.AuthError{Message: "invalid Google token", Err: err})
 	}
 	// Extract user info from claims
 	email := payload.Claims["email"].(string)
 	name := payload.Claims["name"].(string)
 	picture := payload.Claims["picture"].(string)
 	// Upsert user in Supabase (placeholder)
-	if err := upsertUserInSupabase(email, name, picture); err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to upsert user", "message": err.Error()})
+	if err := upsertUserInSupabase(ctx, email, name, picture); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to upsert user", err))
 	}
 	// Generate app JWT
 	token, err := generateJWT(email, name, picture)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to generate token"})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to generate token", err))
 	}
 	// Return success response
 	return generic.Response(http.StatusOK, generic.Json{
@@ -62,8 +63,7 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 	})
 }
 
-func upsertUserInSupabase(email, name, picture string) error {
-	ctx := context.Background()
+func upsertUserInSupabase(ctx context.Context, email, name, picture string) error {
 	conn, err := generic.SupabaseConnect()
 	if err != nil {
 		return err
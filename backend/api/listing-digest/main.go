@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackc/pgx/v5"
+)
+
+// subscriber is a user whose digest is due, with the area it covers
+type subscriber struct {
+	userUUID     string
+	frequency    string
+	cityID       *int
+	latitude     *float64
+	longitude    *float64
+	radiusKm     *float64
+	lastDigestAt *time.Time
+}
+
+type digestListing struct {
+	listingType string
+	id          int
+	petName     *string
+	animalType  string
+	city        *string
+	province    *string
+	distanceKm  *float64
+}
+
+func main() {
+	lambda.Start(handler)
+}
+
+// handler runs every hour and queues a listing_digest notification for each user whose
+// daily or weekly digest is due. notification-dispatch sends them on the user's digest
+// channels.
+func handler(ctx context.Context) error {
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	now := time.Now()
+	due, err := dueSubscribers(ctx, conn, now)
+	if err != nil {
+		generic.Logger.Error("failed to query due digests", "error", err.Error())
+		return err
+	}
+
+	queued, empty := 0, 0
+	for _, sub := range due {
+		count, err := queueDigest(ctx, conn, sub, now)
+		if err != nil {
+			generic.Logger.Error("failed to queue digest", "user_uuid", sub.userUUID, "error", err.Error())
+			return err
+		}
+		if count == 0 {
+			empty++
+		} else {
+			queued++
+		}
+	}
+
+	generic.Logger.Info("queued listing digests", "due", len(due), "queued", queued, "empty", empty)
+	return nil
+}
+
+// dueSubscribers returns the users who opted in to a digest and haven't had one today
+// (daily) or in the last week (weekly), once it is DigestHour in their timezone
+func dueSubscribers(ctx context.Context, conn *pgx.Conn, now time.Time) ([]subscriber, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT user_uuid, digest_frequency, digest_city_id, digest_latitude, digest_longitude,
+			digest_radius_km, last_digest_at
+		FROM notification_preferences
+		WHERE digest_frequency IN ($2, $3)
+			AND (digest_city_id IS NOT NULL OR digest_radius_km IS NOT NULL)
+			AND extract(hour FROM $1::timestamptz AT TIME ZONE timezone) >= $4
+			AND (last_digest_at IS NULL OR (last_digest_at AT TIME ZONE timezone)::date
+				<= ($1::timestamptz AT TIME ZONE timezone)::date - CASE digest_frequency WHEN $3 THEN 7 ELSE 1 END)
+		ORDER BY last_digest_at NULLS FIRST
+		LIMIT $5
+	`, now, generic.DigestDaily, generic.DigestWeekly, generic.DigestHour, generic.DigestBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (subscriber, error) {
+		var sub subscriber
+		err := row.Scan(&sub.userUUID, &sub.frequency, &sub.cityID, &sub.latitude, &sub.longitude,
+			&sub.radiusKm, &sub.lastDigestAt)
+		return sub, err
+	})
+}
+
+// queueDigest finds the listings posted in the subscriber's area since their last
+// digest that they haven't been sent yet, records them and queues the digest. Users
+// with nothing new get no digest. It returns how many listings matched.
+func queueDigest(ctx context.Context, conn *pgx.Conn, sub subscriber, now time.Time) (int, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Claim the digest, so a run that overlaps this one skips the user
+	tag, err := tx.Exec(ctx, `
+		UPDATE notification_preferences SET last_digest_at = $2
+		WHERE user_uuid = $1 AND last_digest_at IS NOT DISTINCT FROM $3
+	`, sub.userUUID, now, sub.lastDigestAt)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, nil
+	}
+
+	since := now.AddDate(0, 0, -generic.DigestPeriodDays(sub.frequency))
+	if sub.lastDigestAt != nil {
+		since = *sub.lastDigestAt
+	}
+
+	listings, total, err := findListings(ctx, tx, sub, since)
+	if err != nil {
+		return 0, err
+	}
+	if total == 0 {
+		return 0, tx.Commit(ctx)
+	}
+
+	types := make([]string, 0, len(listings))
+	ids := make([]int, 0, len(listings))
+	lost, sightings := []map[string]any{}, []map[string]any{}
+	for _, l := range listings {
+		types = append(types, l.listingType)
+		ids = append(ids, l.id)
+
+		item := map[string]any{"id": l.id, "animal_type": l.animalType, "area": area(l.city, l.province)}
+		if l.petName != nil && *l.petName != "" {
+			item["pet_name"] = *l.petName
+		}
+		if l.distanceKm != nil {
+			item["distance_km"] = *l.distanceKm
+		}
+		if l.listingType == "lost" {
+			lost = append(lost, item)
+		} else {
+			sightings = append(sightings, item)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO digest_items (user_uuid, listing_type, listing_id, sent_at)
+		SELECT $1, t.listing_type, t.listing_id, $4
+		FROM unnest($2::text[], $3::integer[]) AS t(listing_type, listing_id)
+		ON CONFLICT DO NOTHING
+	`, sub.userUUID, types, ids, now)
+	if err != nil {
+		return 0, err
+	}
+
+	err = generic.QueueNotification(ctx, tx, sub.userUUID, generic.NotifyListingDigest, map[string]any{
+		"frequency": sub.frequency,
+		"total":     total,
+		"more":      total - len(listings),
+		"lost":      lost,
+		"sightings": sightings,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return total, tx.Commit(ctx)
+}
+
+// findListings returns the newest DigestMaxListings open lost and sighting listings
+// posted since since in the subscriber's city or within their radius, other than their
+// own and ones already in a digest, with how many matched in all. Lost listings are
+// matched and measured from their public point, so moving a small digest area around
+// can't find where a private one is.
+func findListings(ctx context.Context, tx pgx.Tx, sub subscriber, since time.Time) ([]digestListing, int, error) {
+	var radiusKm *float64
+	if sub.radiusKm != nil {
+		// Radii saved before the minimum existed are widened to it
+		radius := math.Max(*sub.radiusKm, generic.MinAreaRadiusKm)
+		radiusKm = &radius
+	}
+
+	point := generic.GeogParam(4, 5)
+	candidates := func(table, locationColumn, privacyColumn, listingType string) string {
+		return `
+			SELECT '` + listingType + `' AS listing_type, x.id, x.pet_name, x.animal_type, x.created_at,
+				` + privacyColumn + ` AS privacy, loc.city_id, c.city_name, c.province_or_state, loc.latitude, loc.longitude
+			FROM ` + table + ` x
+			JOIN locations loc ON loc.id = x.` + locationColumn + `
+			LEFT JOIN cities c ON c.id = loc.city_id
+			WHERE NOT x.is_found AND x.deleted_at IS NULL AND x.created_at > $2
+				AND x.listing_owner IS DISTINCT FROM $1
+				AND (loc.city_id = $3::integer OR ` + generic.WithinKm("loc.geog", point, "$6::float8 + $7") + `)`
+	}
+
+	// The radius is widened by MaxFuzzKm here and narrowed to the public points below
+	rows, err := tx.Query(ctx, `
+		SELECT listing_type, id, pet_name, animal_type, privacy, city_id, city_name, province_or_state, latitude, longitude
+		FROM (`+candidates("lost_pet_listing", "last_seen_location", "x.location_privacy", generic.ListingTypeLost)+`
+			UNION ALL`+candidates("sighting_listing", "spotted_location", "'"+generic.PrivacyExact+"'", generic.ListingTypeSighting)+`
+		) listing
+		WHERE NOT EXISTS (
+			SELECT 1 FROM digest_items i
+			WHERE i.user_uuid = $1 AND i.listing_type = listing.listing_type AND i.listing_id = listing.id
+		)
+		ORDER BY created_at DESC
+	`, sub.userUUID, since, sub.cityID, sub.latitude, sub.longitude, radiusKm, generic.MaxFuzzKm)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	var listings []digestListing
+	for rows.Next() {
+		var (
+			l        digestListing
+			privacy  string
+			cityID   *int
+			lat, lng float64
+		)
+		if err := rows.Scan(&l.listingType, &l.id, &l.petName, &l.animalType, &privacy, &cityID, &l.city, &l.province, &lat, &lng); err != nil {
+			return nil, 0, err
+		}
+
+		inCity := sub.cityID != nil && cityID != nil && *cityID == *sub.cityID
+		if sub.latitude != nil && sub.longitude != nil && radiusKm != nil {
+			km := generic.PublicDistanceKm(privacy, generic.PrivacySeed(l.listingType, l.id), lat, lng, *sub.latitude, *sub.longitude)
+			if !inCity && km > *radiusKm {
+				continue
+			}
+			km = math.Round(generic.FuzzDistance(privacy, km)*10) / 10
+			l.distanceKm = &km
+		} else if !inCity {
+			continue
+		}
+
+		total++
+		if len(listings) < generic.DigestMaxListings {
+			listings = append(listings, l)
+		}
+	}
+	return listings, total, rows.Err()
+}
+
+// area is where a listing is, to city level. Digests never show addresses.
+func area(city, province *string) string {
+	var parts []string
+	for _, part := range []*string{city, province} {
+		if part != nil && *part != "" {
+			parts = append(parts, *part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,194 @@
+// listing-import uploads a CSV or JSON file of listings to the bulk import endpoint.
+//
+// Usage:
+//
+//	API_URL=https://... API_TOKEN=... go run ./listing-import -type sighting -file found.csv [-dry-run] [-organization 12]
+//
+// CSV headers and JSON keys are the fields of SightingRequest or LostPetRequest
+// (animalType, color, dateSpotted, location, lat, lng, city, ...); list columns such as
+// color and breed are separated with ";". Large files are sent in batches of the
+// endpoint's row limit and the per-batch reports are merged, with row numbers counted
+// from the start of the file. The merged report is printed as JSON.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+)
+
+type report struct {
+	Rows       int                       `json:"rows"`
+	Imported   int                       `json:"imported"`
+	IDs        []int                     `json:"ids"`
+	Duplicates []generic.ImportDuplicate `json:"duplicates"`
+	Errors     []generic.ImportRowError  `json:"errors"`
+	DryRun     bool                      `json:"dry_run"`
+}
+
+// batch is one request body and the file row number its first row corresponds to
+type batch struct {
+	body        []byte
+	contentType string
+	offset      int
+}
+
+func main() {
+	file := flag.String("file", "", "path to a .csv or .json file of listings")
+	listingType := flag.String("type", "sighting", "listing type: sighting or lost")
+	dryRun := flag.Bool("dry-run", false, "validate and check for duplicates without creating listings")
+	organization := flag.String("organization", "", "organization ID to import sightings as intake (verified staff only)")
+	flag.Parse()
+
+	apiURL := strings.TrimSuffix(os.Getenv("API_URL"), "/")
+	token := os.Getenv("API_TOKEN")
+	if *file == "" || apiURL == "" || token == "" {
+		fmt.Fprintln(os.Stderr, "API_URL and API_TOKEN must be set and -file given")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var resource string
+	switch *listingType {
+	case generic.ListingTypeSighting:
+		resource = "sighting-listing"
+	case generic.ListingTypeLost:
+		resource = "lost-listing"
+	default:
+		log.Fatalf("unknown listing type %q, expected sighting or lost", *listingType)
+	}
+
+	batches, err := readBatches(*file)
+	if err != nil {
+		log.Fatalf("failed to read %s: %v", *file, err)
+	}
+
+	query := url.Values{}
+	if *dryRun {
+		query.Set("dryRun", "true")
+	}
+	if *organization != "" {
+		query.Set("organizationId", *organization)
+	}
+	endpoint := apiURL + "/" + resource + "/import"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	merged := report{IDs: []int{}, Duplicates: []generic.ImportDuplicate{}, Errors: []generic.ImportRowError{}, DryRun: *dryRun}
+	for i, b := range batches {
+		result, err := send(client, endpoint, token, b)
+		if err != nil {
+			log.Fatalf("batch %d of %d failed: %v", i+1, len(batches), err)
+		}
+
+		merged.Rows += result.Rows
+		merged.Imported += result.Imported
+		merged.IDs = append(merged.IDs, result.IDs...)
+		for _, duplicate := range result.Duplicates {
+			duplicate.Row += b.offset
+			if duplicate.SameAsRow != nil {
+				row := *duplicate.SameAsRow + b.offset
+				duplicate.SameAsRow = &row
+			}
+			merged.Duplicates = append(merged.Duplicates, duplicate)
+		}
+		for _, rowErr := range result.Errors {
+			rowErr.Row += b.offset
+			merged.Errors = append(merged.Errors, rowErr)
+		}
+		log.Printf("batch %d of %d: %d rows, %d imported", i+1, len(batches), result.Rows, result.Imported)
+	}
+
+	out, _ := json.MarshalIndent(merged, "", "  ")
+	fmt.Println(string(out))
+	if len(merged.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+// readBatches splits the file into request bodies of at most generic.MaxImportRows rows
+func readBatches(path string) ([]batch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var batches []batch
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		reader := csv.NewReader(f)
+		header, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		for start := 0; start < len(records); start += generic.MaxImportRows {
+			end := min(start+generic.MaxImportRows, len(records))
+			var buf bytes.Buffer
+			writer := csv.NewWriter(&buf)
+			writer.Write(header)
+			writer.WriteAll(records[start:end])
+			batches = append(batches, batch{body: buf.Bytes(), contentType: "text/csv", offset: start})
+		}
+	case ".json":
+		var rows []json.RawMessage
+		if err := json.NewDecoder(f).Decode(&rows); err != nil {
+			return nil, err
+		}
+		for start := 0; start < len(rows); start += generic.MaxImportRows {
+			end := min(start+generic.MaxImportRows, len(rows))
+			body, _ := json.Marshal(rows[start:end])
+			batches = append(batches, batch{body: body, contentType: "application/json", offset: start})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file type, expected .csv or .json")
+	}
+	return batches, nil
+}
+
+func send(client *http.Client, endpoint, token string, b batch) (report, error) {
+	var result report
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(b.body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", b.contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return result, fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return result, fmt.Errorf("unexpected response: %s", body)
+	}
+	return result, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jackc/pgx/v5"
+)
+
+type listingTable struct {
+	name        string
+	listingType string
+	// keep is an extra condition on x, the listing, that holds its purge back
+	keep string
+}
+
+var tables = []listingTable{
+	// A social post that couldn't be taken down is still live, and its row is the only
+	// record of it, so the listing waits until social-publish removes it
+	{name: "lost_pet_listing", listingType: generic.ListingTypeLost,
+		keep: `EXISTS (SELECT 1 FROM social_posts p WHERE p.listing_id = x.id AND p.status = 'posted')`},
+	{name: "sighting_listing", listingType: generic.ListingTypeSighting, keep: "false"},
+}
+
+type expiredListing struct {
+	id        int
+	imageURLs []string
+}
+
+func main() {
+	lambda.Start(handler)
+}
+
+// handler runs on a schedule and hard-deletes listings whose soft-delete grace period is
+// over. Other expired rows are left to data-retention.
+func handler(ctx context.Context) error {
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	awsConfig, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+	s3Client := s3.NewFromConfig(awsConfig)
+	bucket := os.Getenv("S3_BUCKET_NAME")
+
+	cutoff := time.Now().Add(-generic.DeleteGracePeriod)
+	for _, table := range tables {
+		purged, err := purgeTable(ctx, conn, s3Client, bucket, table, cutoff)
+		if err != nil {
+			generic.Logger.Error("failed to purge listings", "table", table.name, "error", err.Error())
+			return err
+		}
+		generic.Logger.Info("purged listings", "table", table.name, "count", purged)
+	}
+
+	return nil
+}
+
+func purgeTable(ctx context.Context, conn *pgx.Conn, s3Client *s3.Client, bucket string, table listingTable, cutoff time.Time) (int, error) {
+	query := `SELECT id, image_urls FROM ` + table.name + ` x WHERE deleted_at < $1 AND NOT ` + table.keep + ` ORDER BY deleted_at LIMIT $2`
+	rows, err := conn.Query(ctx, query, cutoff, generic.PurgeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	listings, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (expiredListing, error) {
+		var listing expiredListing
+		err := row.Scan(&listing.id, &listing.imageURLs)
+		return listing, err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, listing := range listings {
+		// Images go first: if the row delete fails the next run retries, but an
+		// image whose row is already gone would never be cleaned up
+		if err := deleteImages(ctx, s3Client, bucket, listing.imageURLs); err != nil {
+			return purged, err
+		}
+
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return purged, err
+		}
+		tag, err := tx.Exec(ctx, `DELETE FROM `+table.name+` x WHERE id = $1 AND deleted_at < $2 AND NOT `+table.keep, listing.id, cutoff)
+		if err == nil && tag.RowsAffected() == 0 {
+			// Restored or held back since it was selected
+			tx.Rollback(ctx)
+			continue
+		}
+		if err == nil {
+			err = generic.RecordHistory(ctx, tx, table.listingType, listing.id, "", generic.ActionPurge, nil)
+		}
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			tx.Rollback(ctx)
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+func deleteImages(ctx context.Context, s3Client *s3.Client, bucket string, imageURLs []string) error {
+	var objects []types.ObjectIdentifier
+	for _, imageURL := range imageURLs {
+		if key := imageKey(imageURL, bucket); key != "" {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+
+	_, err := s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+	})
+	return err
+}
+
+// imageKey extracts the object key from a virtual-hosted or path-style S3 URL.
+// URLs pointing at other hosts are ignored.
+func imageKey(rawURL, bucket string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || !strings.Contains(parsed.Host, "amazonaws.com") {
+		return ""
+	}
+
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if !strings.HasPrefix(parsed.Host, bucket+".") {
+		key = strings.TrimPrefix(key, bucket+"/")
+	}
+	return key
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+var exportColumns = []string{
+	"id", "pet_name", "pet_id", "animal_type", "gender", "breed", "color", "age", "description",
+	"is_found", "date_found", "date_lost", "created_at", "updated_at",
+	"street_address", "postal_code", "city", "province_or_state", "country",
+}
+
+// GET /lost-listing/export?format=csv|geojson|kml - the listings matching the same filters
+// as GET /lost-listing, newest first. Owner identities are left out and locations are
+// fuzzed to each listing's privacy level unless the caller owns it.
+func exportLostPets(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, email string) (events.APIGatewayProxyResponse, error) {
+	queryParams := request.QueryStringParameters
+
+	exporter, err := generic.NewExporter(queryParams["format"], exportColumns, "Lost pets")
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	where, args, _, err := listFilters(ctx, conn, queryParams, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	viewer := viewerUUID(ctx, conn, email)
+
+	query := `
+		SELECT
+			l.listing_owner, l.location_privacy,
+			l.id, l.pet_name, l.pet_id, l.animal_type, l.gender, l.breed, l.color, l.age, l.description,
+			l.is_found, l.date_found, l.date_lost, l.created_at, l.updated_at,
+			loc.street_address, loc.postal_code, c.city_name, c.province_or_state, c.country,
+			loc.latitude, loc.longitude
+		FROM lost_pet_listing l
+		LEFT JOIN locations loc ON l.last_seen_location = loc.id
+		LEFT JOIN cities c ON loc.city_id = c.id
+	` + where + `
+		ORDER BY l.created_at DESC, l.id DESC
+		LIMIT $` + strconv.Itoa(len(args)+1)
+	args = append(args, generic.MaxExportRows+1)
+
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query lost pet listings", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if exporter.Rows() == generic.MaxExportRows {
+			exporter.Truncated = true
+			break
+		}
+
+		var (
+			owner, privacy                     string
+			id                                 int
+			petName, animalType, description   string
+			petID, gender, age                 *string
+			breed, color                       []string
+			isFound                            bool
+			dateFound                          *time.Time
+			dateLost, createdAt, updatedAt     time.Time
+			street, postalCode, city, province *string
+			country                            *string
+			lat, lng                           *float64
+		)
+		err := rows.Scan(
+			&owner, &privacy,
+			&id, &petName, &petID, &animalType, &gender, &breed, &color, &age, &description,
+			&isFound, &dateFound, &dateLost, &createdAt, &updatedAt,
+			&street, &postalCode, &city, &province, &country,
+			&lat, &lng,
+		)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to scan lost pet listing", err))
+		}
+
+		if privacy = visibleLevel(privacy, owner, viewer); privacy != generic.PrivacyExact {
+			if street != nil {
+				public := generic.FuzzAddress(privacy, *street)
+				street = &public
+			}
+			postalCode = generic.FuzzPostalCode(privacy, postalCode)
+			if lat != nil && lng != nil {
+				fuzzedLat, fuzzedLng := generic.FuzzCoordinates(privacy, generic.PrivacySeed(generic.ListingTypeLost, id), *lat, *lng)
+				lat, lng = &fuzzedLat, &fuzzedLng
+			}
+		}
+
+		if !exporter.Add(petName, []any{
+			id, petName, petID, animalType, gender, breed, color, age, description,
+			isFound, dateFound, dateLost, createdAt, updatedAt,
+			street, postalCode, city, province, country,
+		}, lat, lng) {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query lost pet listings", err))
+	}
+
+	return exporter.Response("lost-pets-" + time.Now().Format("2006-01-02"))
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+	"github.com/skip2/go-qrcode"
+	_ "golang.org/x/image/webp"
+)
+
+// Flyer formats accepted by the format query parameter
+const (
+	FlyerPDF = "pdf"
+	FlyerPNG = "png"
+)
+
+// Photos larger than maxPhotoBytes, or that declare more than maxPhotoPixels (about
+// 100 MB once decoded), are skipped rather than decoded
+const (
+	maxPhotoBytes  = 10 << 20
+	maxPhotoPixels = 25_000_000
+)
+
+var photoClient = &http.Client{Timeout: 5 * time.Second}
+
+// flyer is everything printed on a flyer, already made safe for the public
+type flyer struct {
+	Title       string // e.g. "LOST DOG"
+	Name        string
+	Details     string // breed and colours
+	Description string
+	DateLost    time.Time
+	Area        string // approximate last seen area, never the exact address
+	URL         string
+	QR          [][]bool
+	Photo       image.Image // nil when the listing has no loadable photo
+}
+
+// GET /lost-listing/{id}/flyer?format=pdf|png - a printable PDF poster (?paper=letter|a4)
+// or a 1200x630 PNG social card. Clients must send a matching Accept header
+// (application/pdf or image/png) for API Gateway to return the file as binary.
+func getFlyer(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id string) (events.APIGatewayProxyResponse, error) {
+	format := request.QueryStringParameters["format"]
+	if format == "" {
+		format = FlyerPDF
+	}
+	if format != FlyerPDF && format != FlyerPNG {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "must be one of: pdf, png", Field: "format"})
+	}
+	paper := request.QueryStringParameters["paper"]
+	if paper == "" {
+		paper = "letter"
+	}
+	if paper != "letter" && paper != "a4" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "must be one of: letter, a4", Field: "paper"})
+	}
+
+	siteURL := strings.TrimSuffix(os.Getenv("PUBLIC_SITE_URL"), "/")
+	if siteURL == "" {
+		return generic.ErrorResponse(ctx, request, generic.Internal("PUBLIC_SITE_URL is not configured", nil))
+	}
+
+	query := `
+		SELECT
+			l.id, l.pet_name, l.animal_type, l.breed, l.color, l.description, l.date_lost,
+			l.image_urls, l.is_found, l.location_privacy,
+			loc.street_address, c.city_name, c.province_or_state
+		FROM lost_pet_listing l
+		LEFT JOIN locations loc ON l.last_seen_location = loc.id
+		LEFT JOIN cities c ON loc.city_id = c.id
+		WHERE l.id = $1 AND l.deleted_at IS NULL
+	`
+
+	var (
+		listingID               int
+		f                       flyer
+		animalType, privacy     string
+		breed, color, imageURLs []string
+		isFound                 bool
+		street, city, province  *string
+	)
+	err := conn.QueryRow(ctx, query, id).Scan(
+		&listingID, &f.Name, &animalType, &breed, &color, &f.Description, &f.DateLost,
+		&imageURLs, &isFound, &privacy,
+		&street, &city, &province,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query lost pet listing", err))
+	}
+	if isFound {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "this pet has been found, so it no longer needs a flyer"})
+	}
+
+	f.Title = "LOST " + strings.ToUpper(animalType)
+	f.Details = strings.Join(breed, ", ")
+	if len(color) > 0 {
+		if f.Details != "" {
+			f.Details += " · "
+		}
+		f.Details += strings.Join(color, ", ")
+	}
+
+	// A flyer is posted in public, so it gets the anonymous view of the location
+	// whoever asks for it
+	f.Area = generic.PublicArea(privacy, street, city, province)
+
+	f.URL = siteURL + "/pet/" + id
+	code, err := qrcode.New(f.URL, qrcode.Medium)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to encode flyer QR code", err))
+	}
+	f.QR = code.Bitmap()
+
+	if len(imageURLs) > 0 {
+		f.Photo = loadPhoto(ctx, imageURLs[0])
+	}
+
+	filename := "lost-" + strings.ToLower(animalType) + "-" + id
+	if format == FlyerPNG {
+		data, err := renderFlyerPNG(f)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to render flyer", err))
+		}
+		return generic.FileResponse("image/png", filename+".png", data)
+	}
+
+	data, err := renderFlyerPDF(f, paper)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to render flyer", err))
+	}
+	return generic.FileResponse("application/pdf", filename+".pdf", data)
+}
+
+// loadPhoto downloads and decodes a listing photo. Only images in our own bucket are
+// fetched, so a listing can't make the Lambda request arbitrary URLs. A photo that can't
+// be loaded is logged and left off the flyer.
+func loadPhoto(ctx context.Context, rawURL string) image.Image {
+	bucket := os.Getenv("S3_BUCKET_NAME")
+	parsed, err := url.Parse(rawURL)
+	if err != nil || bucket == "" || parsed.Scheme != "https" {
+		return nil
+	}
+	virtualHosted := strings.HasPrefix(parsed.Host, bucket+".") && isS3Host(strings.TrimPrefix(parsed.Host, bucket+"."))
+	pathStyle := isS3Host(parsed.Host) && strings.HasPrefix(parsed.Path, "/"+bucket+"/")
+	if !virtualHosted && !pathStyle {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := photoClient.Do(req)
+	if err != nil {
+		generic.Log(ctx).Warn("failed to download flyer photo", "url", rawURL, "error", err.Error())
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		generic.Log(ctx).Warn("failed to download flyer photo", "url", rawURL, "status", resp.StatusCode)
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPhotoBytes))
+	if err != nil {
+		generic.Log(ctx).Warn("failed to download flyer photo", "url", rawURL, "error", err.Error())
+		return nil
+	}
+
+	// A small file can declare a huge image, so check its size before decoding it
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		generic.Log(ctx).Warn("failed to decode flyer photo", "url", rawURL, "error", err.Error())
+		return nil
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxPhotoPixels/config.Height {
+		generic.Log(ctx).Warn("flyer photo is too large", "url", rawURL, "width", config.Width, "height", config.Height)
+		return nil
+	}
+
+	photo, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		generic.Log(ctx).Warn("failed to decode flyer photo", "url", rawURL, "error", err.Error())
+		return nil
+	}
+	return photo
+}
+
+// isS3Host reports whether host is an S3 endpoint, like s3.amazonaws.com or
+// s3.us-west-2.amazonaws.com. Other amazonaws.com hosts can serve anyone's content.
+func isS3Host(host string) bool {
+	endpoint, ok := strings.CutSuffix(host, ".amazonaws.com")
+	return ok && (endpoint == "s3" || strings.HasPrefix(endpoint, "s3.") || strings.HasPrefix(endpoint, "s3-"))
+}
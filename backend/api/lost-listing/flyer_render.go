@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+var flyerRed = color.RGBA{R: 200, G: 30, B: 30, A: 255}
+
+// PDF layout in millimetres
+const (
+	pdfMargin      = 15.0
+	pdfBannerH     = 38.0
+	pdfPhotoH      = 105.0
+	pdfQRSize      = 48.0
+	pdfLineH       = 6.0
+	pdfMaxPhotoPix = 1600
+)
+
+// renderFlyerPDF lays out a one-page poster: banner, name, photo, details and
+// description, with the QR code in the bottom corner
+func renderFlyerPDF(f flyer, paper string) ([]byte, error) {
+	size := "Letter"
+	if paper == "a4" {
+		size = "A4"
+	}
+	pdf := fpdf.New("P", "mm", size, "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetTitle(f.Title+": "+f.Name, true)
+	pdf.AddPage()
+
+	// The core fonts are cp1252, so accented names need translating
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pageW, pageH := pdf.GetPageSize()
+	contentW := pageW - 2*pdfMargin
+
+	pdf.SetFillColor(int(flyerRed.R), int(flyerRed.G), int(flyerRed.B))
+	pdf.Rect(0, 0, pageW, pdfBannerH, "F")
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont("Helvetica", "B", 56)
+	pdf.SetXY(pdfMargin, 8)
+	pdf.CellFormat(contentW, 22, tr(f.Title), "", 1, "C", false, 0, "")
+
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 36)
+	pdf.SetXY(pdfMargin, pdfBannerH+6)
+	pdf.CellFormat(contentW, 16, tr(f.Name), "", 1, "C", false, 0, "")
+
+	y := pdfBannerH + 26
+	if f.Photo != nil {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, shrinkImage(f.Photo, pdfMaxPhotoPix), &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		options := fpdf.ImageOptions{ImageType: "JPG"}
+		pdf.RegisterImageOptionsReader("photo", options, &buf)
+
+		bounds := f.Photo.Bounds()
+		w, h := fitBox(float64(bounds.Dx()), float64(bounds.Dy()), contentW, pdfPhotoH)
+		pdf.ImageOptions("photo", pdfMargin+(contentW-w)/2, y+(pdfPhotoH-h)/2, w, h, false, options, 0, "")
+		y += pdfPhotoH + 6
+	}
+
+	pdf.SetXY(pdfMargin, y)
+	if f.Details != "" {
+		pdf.SetFont("Helvetica", "B", 18)
+		pdf.MultiCell(contentW, 8, tr(f.Details), "", "C", false)
+	}
+	pdf.SetFont("Helvetica", "", 16)
+	pdf.MultiCell(contentW, 8, "Lost on "+f.DateLost.Format("January 2, 2006"), "", "C", false)
+	if f.Area != "" {
+		pdf.MultiCell(contentW, 8, tr("Last seen near "+f.Area), "", "C", false)
+	}
+
+	// The description gets whatever space is left above the footer
+	footerTop := pageH - pdfMargin - pdfQRSize
+	pdf.Ln(3)
+	pdf.SetFont("Helvetica", "", 13)
+	maxLines := int((footerTop - 4 - pdf.GetY()) / pdfLineH)
+	var lines []string
+	for _, line := range pdf.SplitLines([]byte(tr(f.Description)), contentW) {
+		lines = append(lines, string(line))
+	}
+	for _, line := range truncateLines(lines, maxLines) {
+		pdf.CellFormat(contentW, pdfLineH, line, "", 1, "C", false, 0, "")
+	}
+
+	pdf.SetFillColor(0, 0, 0)
+	cell := pdfQRSize / float64(len(f.QR))
+	for row, modules := range f.QR {
+		for col, dark := range modules {
+			if dark {
+				pdf.Rect(pdfMargin+float64(col)*cell, footerTop+float64(row)*cell, cell, cell, "F")
+			}
+		}
+	}
+
+	textX := pdfMargin + pdfQRSize + 8
+	pdf.SetLeftMargin(textX)
+	pdf.SetXY(textX, footerTop+6)
+	pdf.SetFont("Helvetica", "B", 22)
+	pdf.CellFormat(pageW-pdfMargin-textX, 10, "Have you seen me?", "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 13)
+	pdf.MultiCell(pageW-pdfMargin-textX, pdfLineH, "Scan the code to see the latest details or to report a sighting.", "", "L", false)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.MultiCell(pageW-pdfMargin-textX, pdfLineH, f.URL, "", "L", false)
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Social card layout in pixels, sized for Open Graph previews
+const (
+	cardW       = 1200
+	cardH       = 630
+	cardPadding = 40
+	cardBannerH = 90
+	cardQRSize  = 200
+)
+
+// renderFlyerPNG draws the social card: the photo as a square on the left and the
+// details with a QR code on the right
+func renderFlyerPNG(f flyer) ([]byte, error) {
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	face := func(ttf *opentype.Font, size float64) (font.Face, error) {
+		return opentype.NewFace(ttf, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	}
+	titleFace, err := face(bold, 52)
+	if err != nil {
+		return nil, err
+	}
+	nameFace, err := face(bold, 46)
+	if err != nil {
+		return nil, err
+	}
+	textFace, err := face(regular, 26)
+	if err != nil {
+		return nil, err
+	}
+	smallFace, err := face(regular, 22)
+	if err != nil {
+		return nil, err
+	}
+	captionFace, err := face(bold, 28)
+	if err != nil {
+		return nil, err
+	}
+
+	card := image.NewRGBA(image.Rect(0, 0, cardW, cardH))
+	draw.Draw(card, card.Bounds(), image.White, image.Point{}, draw.Src)
+
+	photoRect := image.Rect(0, 0, cardH, cardH)
+	if f.Photo != nil {
+		xdraw.CatmullRom.Scale(card, photoRect, f.Photo, coverRect(f.Photo.Bounds()), draw.Src, nil)
+	} else {
+		draw.Draw(card, photoRect, image.NewUniform(color.RGBA{R: 235, G: 235, B: 235, A: 255}), image.Point{}, draw.Src)
+		width := font.MeasureString(titleFace, f.Title).Ceil()
+		drawLines(card, titleFace, flyerRed, []string{f.Title}, (cardH-width)/2, cardH/2, 0)
+	}
+
+	left := cardH + cardPadding
+	width := cardW - cardPadding - left
+	draw.Draw(card, image.Rect(cardH, 0, cardW, cardBannerH), image.NewUniform(flyerRed), image.Point{}, draw.Src)
+	drawLines(card, titleFace, color.White, []string{f.Title}, left, 66, 0)
+
+	// Text stops above the QR code, which takes the bottom of the right-hand side
+	textBottom := cardH - cardPadding - cardQRSize - 10
+	y := drawLines(card, nameFace, color.Black, truncateLines(wrapText(nameFace, f.Name, width), 1), left, 150, 0)
+	y = drawLines(card, textFace, color.Black, truncateLines(wrapText(textFace, f.Details, width), 2), left, y+42, 34)
+	y = drawLines(card, textFace, color.Black, []string{"Lost on " + f.DateLost.Format("January 2, 2006")}, left, y, 34)
+	if f.Area != "" {
+		y = drawLines(card, textFace, color.Black, truncateLines(wrapText(textFace, "Near "+f.Area, width), 2), left, y, 34)
+	}
+	if remaining := (textBottom - y) / 30; remaining > 0 {
+		drawLines(card, smallFace, color.RGBA{R: 80, G: 80, B: 80, A: 255}, truncateLines(wrapText(smallFace, f.Description, width), remaining), left, y+4, 30)
+	}
+
+	module := cardQRSize / len(f.QR)
+	qrX := cardW - cardPadding - module*len(f.QR)
+	qrY := cardH - cardPadding - module*len(f.QR)
+	for row, modules := range f.QR {
+		for col, dark := range modules {
+			if dark {
+				draw.Draw(card, image.Rect(qrX+col*module, qrY+row*module, qrX+(col+1)*module, qrY+(row+1)*module), image.Black, image.Point{}, draw.Src)
+			}
+		}
+	}
+	captionWidth := qrX - 20 - left
+	caption := truncateLines(wrapText(captionFace, "Have you seen me? Scan for details or to report a sighting.", captionWidth), 4)
+	drawLines(card, captionFace, color.Black, caption, left, cardH-cardPadding-36*(len(caption)-1)-8, 36)
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, card); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// drawLines draws lines with their first baseline at y and returns the baseline after the last
+func drawLines(dst draw.Image, face font.Face, c color.Color, lines []string, x, y, lineHeight int) int {
+	drawer := font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face}
+	for _, line := range lines {
+		drawer.Dot = fixed.P(x, y)
+		drawer.DrawString(line)
+		y += lineHeight
+	}
+	return y
+}
+
+// wrapText breaks text into lines no wider than width. A single word wider than width
+// gets a line of its own.
+func wrapText(face font.Face, text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && font.MeasureString(face, candidate).Ceil() > width {
+			lines = append(lines, line)
+			line = word
+		} else {
+			line = candidate
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// truncateLines keeps at most maxLines lines, marking the cut with an ellipsis
+func truncateLines(lines []string, maxLines int) []string {
+	if maxLines <= 0 {
+		return nil
+	}
+	if len(lines) <= maxLines {
+		return lines
+	}
+	lines = lines[:maxLines]
+	lines[maxLines-1] = strings.TrimRight(lines[maxLines-1], " ") + "..."
+	return lines
+}
+
+// fitBox scales w x h down or up to fit inside boxW x boxH, keeping the aspect ratio
+func fitBox(w, h, boxW, boxH float64) (float64, float64) {
+	scale := min(boxW/w, boxH/h)
+	return w * scale, h * scale
+}
+
+// coverRect is the centred square of bounds, so a photo can fill a square without stretching
+func coverRect(bounds image.Rectangle) image.Rectangle {
+	side := min(bounds.Dx(), bounds.Dy())
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// shrinkImage scales img down so neither side exceeds maxSide pixels
+func shrinkImage(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= maxSide && bounds.Dy() <= maxSide {
+		return img
+	}
+	w, h := fitBox(float64(bounds.Dx()), float64(bounds.Dy()), float64(maxSide), float64(maxSide))
+	dst := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	xdraw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// importRow is a row that passed validation and is waiting to be inserted
+type importRow struct {
+	row      int
+	req      LostPetRequest
+	place    generic.Place
+	dateLost time.Time
+}
+
+// POST /lost-listing/import - creates lost pet listings from a CSV or JSON array of
+// LostPetRequest rows. Invalid rows and duplicates are reported and skipped; the rest are
+// imported in one transaction. ?dryRun=true validates without writing anything.
+func handleImport(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	dryRun := request.QueryStringParameters["dryRun"] == "true"
+
+	body, err := generic.ImportBody(request.Body, request.IsBase64Encoded)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	contentType := request.Headers["Content-Type"]
+	if contentType == "" {
+		contentType = request.Headers["content-type"]
+	}
+
+	rawRows, err := generic.DecodeImportRows(body, contentType, "color", "breed")
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+	if len(rawRows) == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "import has no rows"})
+	}
+	if len(rawRows) > generic.MaxImportRows {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "import is limited to " + strconv.Itoa(generic.MaxImportRows) + " rows per request"})
+	}
+
+	var rows []importRow
+	var rowErrors []generic.ImportRowError
+	for i, raw := range rawRows {
+		rowNumber := i + 1
+
+		var req LostPetRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			rowErrors = append(rowErrors, generic.ImportRowError{Row: rowNumber, Message: "row does not match the listing format"})
+			continue
+		}
+		if errs := req.Validate(false); len(errs) > 0 {
+			rowErrors = append(rowErrors, generic.ImportRowError{Row: rowNumber, Fields: errs})
+			continue
+		}
+		dateLost, err := parseDate(req.DateLost)
+		if err != nil {
+			rowErrors = append(rowErrors, generic.ImportRowError{Row: rowNumber, Message: err.Error()})
+			continue
+		}
+
+		place, err := generic.ResolvePlace(ctx, req.place())
+		if err != nil {
+			var validationErr *generic.ValidationError
+			if !errors.As(err, &validationErr) {
+				return generic.ErrorResponse(ctx, request, err)
+			}
+			rowErrors = append(rowErrors, generic.ImportRowError{Row: rowNumber, Fields: generic.ValidationErrors{
+				{Field: validationErr.Field, Code: generic.CodeInvalid, Message: validationErr.Message},
+			}})
+			continue
+		}
+
+		rows = append(rows, importRow{row: rowNumber, req: req, place: place, dateLost: dateLost})
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	places := make([]generic.Place, len(rows))
+	for i, row := range rows {
+		places[i] = row.place
+	}
+	locationIDs, err := generic.ResolveLocations(ctx, tx, places)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to resolve locations", err))
+	}
+
+	keys := make([]generic.ListingKey, len(rows))
+	for i, row := range rows {
+		keys[i] = generic.ListingKey{AnimalType: row.req.AnimalType, LocationID: locationIDs[i], Date: row.dateLost, PetID: row.req.PetID}
+	}
+	existing, err := generic.FindDuplicates(ctx, tx, generic.ListingTypeLost, keys)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to check for duplicates", err))
+	}
+
+	insertQuery := `
+		INSERT INTO lost_pet_listing (
+			listing_owner, is_found, pet_name, pet_id, gender, breed, color,
+			animal_type, age, description, date_lost, last_seen_location, location_privacy, social_posting, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+		) RETURNING id
+	`
+
+	var ids []int
+	var duplicates []generic.ImportDuplicate
+	seen := map[string]int{}
+	imported := 0
+	for i, row := range rows {
+		if existing[i] != 0 {
+			duplicates = append(duplicates, generic.ImportDuplicate{Row: row.row, ExistingID: &existing[i]})
+			continue
+		}
+		if earlier, ok := seen[keys[i].String()]; ok {
+			duplicates = append(duplicates, generic.ImportDuplicate{Row: row.row, SameAsRow: &earlier})
+			continue
+		}
+		seen[keys[i].String()] = row.row
+		imported++
+
+		if dryRun {
+			continue
+		}
+
+		req := row.req
+		privacy := generic.DefaultPrivacy
+		if req.LocationPrivacy != nil {
+			privacy = *req.LocationPrivacy
+		}
+		socialPosting := req.SocialPosting != nil && *req.SocialPosting
+
+		var listingID int
+		err := tx.QueryRow(ctx, insertQuery,
+			userUUID, false, req.Name, req.PetID, req.Gender, req.Breed, req.Color,
+			req.AnimalType, req.Age, req.Description, row.dateLost, locationIDs[i], privacy, socialPosting, time.Now(),
+		).Scan(&listingID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to insert lost pet listing", err))
+		}
+
+		changes := generic.Diff(nil, map[string]any{
+			"pet_name":           req.Name,
+			"pet_id":             req.PetID,
+			"gender":             req.Gender,
+			"breed":              req.Breed,
+			"color":              req.Color,
+			"animal_type":        req.AnimalType,
+			"age":                req.Age,
+			"description":        req.Description,
+			"date_lost":          row.dateLost,
+			"last_seen_location": locationIDs[i],
+			"location_privacy":   privacy,
+			"social_posting":     socialPosting,
+		})
+		if err := generic.RecordHistory(ctx, tx, generic.ListingTypeLost, listingID, userUUID, generic.ActionCreate, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+		}
+		if err := generic.RecordEvent(ctx, tx, generic.ListingTypeLost, listingID, generic.ActionCreate, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+		}
+		if err := generic.QueueAreaAlerts(ctx, tx, listingID, privacy, row.place.Lat, row.place.Lng); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to queue area alerts", err))
+		}
+
+		ids = append(ids, listingID)
+	}
+
+	// A dry run leaves new cities and locations uncommitted too
+	if !dryRun {
+		if err := tx.Commit(ctx); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to import lost pet listings", err))
+		}
+	}
+
+	status := http.StatusOK
+	if len(ids) > 0 {
+		status = http.StatusCreated
+	}
+	return generic.Response(status, generic.ImportReport(len(rawRows), imported, ids, duplicates, rowErrors, dryRun))
+}
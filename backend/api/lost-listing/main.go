@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,7 +10,6 @@ import (
 
 	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
@@ -39,11 +37,66 @@ type LostPetRequest struct {
 	Description string  `json:"description"`
 	PetID       *string `json:"petId,omitempty"`
 	IsFound     *bool   `json:"isFound,omitempty"`
+
+	// Who sees the exact last seen location: exact, block or neighborhood
+	LocationPrivacy *string `json:"locationPrivacy,omitempty"`
+	// Opt in to automatic posts on the configured social media channels
+	SocialPosting *bool `json:"socialPosting,omitempty"`
+}
+
+// Validate checks the request and returns every violation. Partial requests (updates)
+// only validate the fields that are present.
+func (r *LostPetRequest) Validate(partial bool) generic.ValidationErrors {
+	rules := []generic.Rule{
+		generic.OneOf("animalType", r.AnimalType, generic.AnimalTypes),
+		generic.PastDate("dateLost", r.DateLost),
+		generic.MaxLength("name", r.Name, 100),
+		generic.MaxLength("description", r.Description, generic.MaxDescriptionLength),
+	}
+	if r.Gender != nil {
+		rules = append(rules, generic.OneOf("gender", *r.Gender, generic.Genders))
+	}
+	if r.LocationPrivacy != nil {
+		rules = append(rules, generic.OneOf("locationPrivacy", *r.LocationPrivacy, generic.PrivacyLevels))
+	}
+	if !partial {
+		rules = append(rules,
+			generic.Required("name", r.Name),
+			generic.Required("animalType", r.AnimalType),
+			generic.RequiredList("color", r.Color),
+			generic.Required("dateLost", r.DateLost),
+			generic.Required("location", r.Location),
+			// Without coordinates the listing is placed at the centre of its city
+			generic.RequiredValue("locationCoords", r.LocationCoords != nil || r.City != ""),
+		)
+	}
+	if r.LocationCoords != nil {
+		rules = append(rules,
+			generic.InRange("locationCoords.lat", r.LocationCoords.Lat, -90, 90),
+			generic.InRange("locationCoords.lng", r.LocationCoords.Lng, -180, 180),
+		)
+	}
+	return generic.Validate(rules...)
+}
+
+// place is the address in the request, without coordinates if it has none
+func (r *LostPetRequest) place() generic.Place {
+	place := generic.Place{
+		StreetAddress:   r.Location,
+		PostalCode:      r.PostalCode,
+		City:            r.City,
+		ProvinceOrState: r.ProvinceOrState,
+		Country:         r.Country,
+	}
+	if r.LocationCoords != nil {
+		place.Lat, place.Lng = r.LocationCoords.Lat, r.LocationCoords.Lng
+	}
+	return place
 }
 
 type LostPetResponse struct {
 	ID               int        `json:"id"`
-	ListingOwner     string     `json:"listing_owner"`
+	ListingOwner     string     `json:"listing_owner,omitempty"`
 	IsFound          bool       `json:"is_found"`
 	DateFound        *time.Time `json:"date_found,omitempty"`
 	PetName          string     `json:"pet_name"`
@@ -56,13 +109,19 @@ type LostPetResponse struct {
 	Description      string     `json:"description"`
 	ImageURLs        []string   `json:"image_urls,omitempty"`
 	DateLost         time.Time  `json:"date_lost"`
-	LastSeenLocation int        `json:"last_seen_location"`
+	LastSeenLocation int        `json:"last_seen_location,omitempty"`
+	LocationPrivacy  string     `json:"location_privacy"`
+	SocialPosting    bool       `json:"social_posting"`
 	Location         *Location  `json:"location,omitempty"`
 	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	DistanceKm       *float64   `json:"distance_km,omitempty"`
+
+	sortValue string
 }
 
 type Location struct {
-	ID            int     `json:"id"`
+	ID            int     `json:"id,omitempty"`
 	StreetAddress string  `json:"street_address"`
 	PostalCode    *string `json:"postal_code"`
 	Latitude      float64 `json:"latitude"`
@@ -71,249 +130,162 @@ type Location struct {
 }
 
 func main() {
-	lambda.Start(handler)
+	generic.Start("lost-listing", handler)
 }
 
-func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	if request.HTTPMethod == "OPTIONS" {
 		return generic.Response(http.StatusOK, generic.Json{})
 	}
 
 	switch request.HTTPMethod {
 	case "GET":
-		return handleGet(request)
+		return handleGet(ctx, request)
 	case "POST":
-		return handleCreate(request)
+		if strings.HasSuffix(request.Resource, "/import") {
+			return handleImport(ctx, request)
+		}
+		if strings.HasSuffix(request.Resource, "/restore") {
+			return handleRestore(ctx, request)
+		}
+		if strings.HasSuffix(request.Resource, "/reunification") {
+			return handleCreateReunification(ctx, request)
+		}
+		if strings.HasSuffix(request.Resource, "/trail") {
+			return handleCreateTrailPoint(ctx, request)
+		}
+		return handleCreate(ctx, request)
 	case "PUT", "PATCH":
-		return handleUpdate(request)
+		return handleUpdate(ctx, request)
 	case "DELETE":
-		return handleDelete(request)
+		if strings.HasSuffix(request.Resource, "/social-posts") {
+			return handleRemoveSocialPosts(ctx, request)
+		}
+		if strings.HasSuffix(request.Resource, "/trail/{pointId}") {
+			return handleDeleteTrailPoint(ctx, request)
+		}
+		return handleDelete(ctx, request)
 	default:
-		return generic.Response(http.StatusMethodNotAllowed, generic.Json{
-			"error": "method not allowed",
-		})
+		return generic.MethodNotAllowed(request)
 	}
 }
 
-func getOrCreateCity(ctx context.Context, conn *pgx.Conn, cityName, province, country string) (int, error) {
-	var cityID int
-	query := `SELECT id FROM cities WHERE city_name = $1 AND province_or_state = $2 AND country = $3 LIMIT 1`
-	err := conn.QueryRow(ctx, query, cityName, province, country).Scan(&cityID)
-	if err == nil {
-		return cityID, nil
-	}
-	if err != pgx.ErrNoRows {
-		return 0, err
-	}
-
-	insertQuery := `INSERT INTO cities (city_name, province_or_state, country) VALUES ($1, $2, $3) RETURNING id`
-	err = conn.QueryRow(ctx, insertQuery, cityName, province, country).Scan(&cityID)
-	if err != nil {
-		return 0, err
-	}
-
-	return cityID, nil
-}
-
 func extractUserFromToken(request events.APIGatewayProxyRequest) (string, string, error) {
 	authHeader := request.Headers["Authorization"]
+	if authHeader == "" {
+		authHeader = request.Headers["authorization"]
+	}
+	if authHeader == "" {
+		return "", "", &generic.AuthError{Message: "missing authorization header"}
+	}
 
 	token := strings.TrimPrefix(authHeader, "Bearer ")
 	token = strings.TrimSpace(token)
 
 	tokenClaims, err := generic.TokenClaims(token)
 	if err != nil {
-		return "", "", &AuthError{Message: fmt.Sprintf("invalid or expired token!, Token:%s, Error: %s", token, err.Error())}
+		return "", "", &generic.AuthError{Message: "invalid or expired token", Err: err}
 	}
 
 	email, ok := tokenClaims["email"].(string)
 	if !ok || email == "" {
-		return "", "", &AuthError{Message: "email not found in token"}
+		return "", "", &generic.AuthError{Message: "email not found in token"}
 	}
 
 	return token, email, nil
 }
 
+// GetUserUUID is an alias for getUserUUID (for compatibility)
 func GetUserUUID(ctx context.Context, conn *pgx.Conn, email string) (string, error) {
-	var userUUID string
-	queryUser := `SELECT user_uuid FROM users WHERE email = $1`
-	err := conn.QueryRow(ctx, queryUser, email).Scan(&userUUID)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return "", &NotFoundError{Message: "user not found"}
-		}
-		return "", err
-	}
-	return userUUID, nil
+	return getUserUUID(ctx, conn, email)
 }
 
 func parseDate(dateStr string) (time.Time, error) {
-	dateFormats := []string{
-		time.RFC3339,
-		"2006-01-02T15:04:05Z07:00",
-		"2006-01-02",
-		time.RFC3339Nano,
-	}
-
-	for _, format := range dateFormats {
-		if date, err := time.Parse(format, dateStr); err == nil {
-			return date, nil
-		}
-	}
-
-	return time.Time{}, &ValidationError{Message: "invalid date format", Field: "dateLost"}
-}
-
-type AuthError struct {
-	Message string
-	Err     error
-}
-
-func (e *AuthError) Error() string {
-	if e.Err != nil {
-		return e.Message + ": " + e.Err.Error()
-	}
-	return e.Message
-}
-
-type NotFoundError struct {
-	Message string
-}
-
-func (e *NotFoundError) Error() string {
-	return e.Message
-}
-
-type ValidationError struct {
-	Message string
-	Field   string
-}
-
-func (e *ValidationError) Error() string {
-	return e.Message
-}
-
-func getOrCreateLocation(ctx context.Context, conn *pgx.Conn, streetAddress string, postalCode *string, lat, lng float64, cityID int) (int, error) {
-	var locationID int
-	query := `SELECT id FROM locations WHERE street_address = $1 AND latitude = $2 AND longitude = $3 LIMIT 1`
-	err := conn.QueryRow(ctx, query, streetAddress, lat, lng).Scan(&locationID)
-	if err == nil {
-		return locationID, nil
-	}
-	if err != pgx.ErrNoRows {
-		return 0, err
-	}
-
-	insertQuery := `INSERT INTO locations (street_address, postal_code, latitude, longitude, city_id, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
-	err = conn.QueryRow(ctx, insertQuery, streetAddress, postalCode, lat, lng, cityID, time.Now()).Scan(&locationID)
+	date, err := generic.ParseDate(dateStr)
 	if err != nil {
-		return 0, err
+		return time.Time{}, &generic.ValidationError{Message: "invalid date format", Field: "dateLost"}
 	}
-	return locationID, nil
+	return date, nil
 }
 
 // CREATE - POST /lost-listing
-func handleCreate(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handleCreate(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	_, email, err := extractUserFromToken(request)
 	if err != nil {
-		if authErr, ok := err.(*AuthError); ok {
-			return generic.Response(http.StatusUnauthorized, generic.Json{
-				"error": authErr.Message, "message": authErr.Err,
-			})
-		}
-		return generic.Response(http.StatusUnauthorized, generic.Json{
-			"error": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	var req LostPetRequest
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error":   "invalid request body",
-			"message": err.Error(),
-		})
-	}
-
-	// Validate required fields
-	if req.Name == "" || req.AnimalType == "" || len(req.Color) == 0 || req.DateLost == "" || req.Location == "" {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error": "missing required fields: name, animalType, color, dateLost, and location are required",
-		})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
 	}
 
-	if req.LocationCoords == nil {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error": "locationCoords (lat, lng) are required",
-		})
+	if errs := req.Validate(false); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
 	}
 
-	ctx := context.Background()
 	conn, err := generic.SupabaseConnect()
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to connect to database",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
 	}
 	defer conn.Close(ctx)
 
 	userUUID, err := getUserUUID(ctx, conn, email)
 	if err != nil {
-		if _, ok := err.(*NotFoundError); ok {
-			return generic.Response(http.StatusNotFound, generic.Json{
-				"error": err.Error(),
-			})
-		}
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to query user",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	dateLost, err := parseDate(req.DateLost)
 	if err != nil {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	place, err := generic.ResolvePlace(ctx, req.place())
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	// Create or get city
-	cityID, err := getOrCreateCity(ctx, conn, req.City, req.ProvinceOrState, req.Country)
+	cityID, err := generic.GetOrCreateCity(ctx, conn, place)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to create or fetch city",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create or fetch city", err))
 	}
 
 	// Create or get location
-	locationID, err := getOrCreateLocation(ctx, conn, req.Location, req.PostalCode, req.LocationCoords.Lat, req.LocationCoords.Lng, cityID)
+	locationID, err := generic.GetOrCreateLocation(ctx, conn, place, cityID)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to create location",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create location", err))
 	}
 
 	// Convert userUUID string to UUID type
 	userUUIDParsed, err := uuid.Parse(userUUID)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error": "invalid user UUID format",
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("invalid user UUID format", err))
 	}
 
+	privacy := generic.DefaultPrivacy
+	if req.LocationPrivacy != nil {
+		privacy = *req.LocationPrivacy
+	}
+	socialPosting := req.SocialPosting != nil && *req.SocialPosting
+
 	insertQuery := `
 		INSERT INTO lost_pet_listing (
 			listing_owner, is_found, pet_name, pet_id, gender, breed, color,
-			animal_type, age, description, date_lost, last_seen_location, created_at
+			animal_type, age, description, date_lost, last_seen_location, location_privacy, social_posting, created_at
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
 		) RETURNING id
 	`
 
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
 	var listingID int
-	err = conn.QueryRow(
+	err = tx.QueryRow(
 		ctx, insertQuery,
 		userUUIDParsed,  // listing_owner (UUID)
 		false,           // is_found (default false)
@@ -327,14 +299,41 @@ func handleCreate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 		req.Description, // description
 		dateLost,        // date_lost
 		locationID,      // last_seen_location
+		privacy,         // location_privacy
+		socialPosting,   // social_posting
 		time.Now(),      // created_at
 	).Scan(&listingID)
 
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to insert lost pet listing",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to insert lost pet listing", err))
+	}
+
+	changes := generic.Diff(nil, map[string]any{
+		"pet_name":           req.Name,
+		"pet_id":             req.PetID,
+		"gender":             req.Gender,
+		"breed":              req.Breed,
+		"color":              req.Color,
+		"animal_type":        req.AnimalType,
+		"age":                req.Age,
+		"description":        req.Description,
+		"date_lost":          dateLost,
+		"last_seen_location": locationID,
+		"location_privacy":   privacy,
+		"social_posting":     socialPosting,
+	})
+	if err := generic.RecordHistory(ctx, tx, generic.ListingTypeLost, listingID, userUUID, generic.ActionCreate, changes); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+	}
+	if err := generic.RecordEvent(ctx, tx, generic.ListingTypeLost, listingID, generic.ActionCreate, changes); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+	}
+	if err := generic.QueueAreaAlerts(ctx, tx, listingID, privacy, place.Lat, place.Lng); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to queue area alerts", err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to insert lost pet listing", err))
 	}
 
 	return generic.Response(http.StatusCreated, generic.Json{
@@ -344,48 +343,74 @@ func handleCreate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 	})
 }
 
-// READ - GET /lost-listing or GET /lost-listing/{id}
-func handleGet(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	_, email, err := extractUserFromToken(request)
-	if err != nil {
-		if authErr, ok := err.(*AuthError); ok {
-			return generic.Response(http.StatusUnauthorized, generic.Json{
-				"error": authErr.Message,
-			})
+// READ - GET /lost-listing or GET /lost-listing/{id}. Listings can be read without
+// signing in; anonymous callers get public fields and locations and are rate limited.
+func handleGet(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	email := ""
+	if generic.HasAuthorization(request) {
+		var err error
+		_, email, err = extractUserFromToken(request)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, err)
 		}
-		return generic.Response(http.StatusUnauthorized, generic.Json{
-			"error": err.Error(),
-		})
 	}
 
-	ctx := context.Background()
 	conn, err := generic.SupabaseConnect()
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to connect to database",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
 	}
 	defer conn.Close(ctx)
 
+	if email == "" {
+		if strings.HasSuffix(request.Resource, "/export") || strings.HasSuffix(request.Resource, "/history") ||
+			strings.HasSuffix(request.Resource, "/social-posts") {
+			return generic.ErrorResponse(ctx, request, &generic.AuthError{Message: "missing authorization header"})
+		}
+		if err := generic.CheckPublicRateLimit(ctx, conn, request); err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+	}
+
+	if strings.HasSuffix(request.Resource, "/export") {
+		return exportLostPets(ctx, conn, request, email)
+	}
+
 	listingID := request.PathParameters["id"]
+	if listingID != "" && strings.HasSuffix(request.Resource, "/history") {
+		return getLostPetHistory(ctx, conn, request, listingID, email)
+	}
+	if listingID != "" && strings.HasSuffix(request.Resource, "/reunification") {
+		return getReunification(ctx, conn, request, listingID, email)
+	}
+	if listingID != "" && strings.HasSuffix(request.Resource, "/social-posts") {
+		return getSocialPosts(ctx, conn, request, listingID, email)
+	}
+	if listingID != "" && strings.HasSuffix(request.Resource, "/flyer") {
+		return getFlyer(ctx, conn, request, listingID)
+	}
+	if listingID != "" && strings.HasSuffix(request.Resource, "/trail") {
+		return getTrail(ctx, conn, request, listingID, email)
+	}
+	if listingID != "" && strings.HasSuffix(request.Resource, "/nearby-shelters") {
+		return getNearbyShelters(ctx, conn, request, listingID, email)
+	}
 	if listingID != "" {
-		return getLostPetByID(ctx, conn, listingID, email)
+		return getLostPetByID(ctx, conn, request, listingID, email)
 	}
 
-	return getAllLostPets(ctx, conn, request.QueryStringParameters, email)
+	return getAllLostPets(ctx, conn, request, email)
 }
 
-func getLostPetByID(ctx context.Context, conn *pgx.Conn, id, email string) (events.APIGatewayProxyResponse, error) {
+func getLostPetByID(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id, email string) (events.APIGatewayProxyResponse, error) {
 	query := `
 		SELECT 
 			l.id, l.listing_owner, l.is_found, l.date_found, l.pet_name, l.pet_id,
 			l.gender, l.breed, l.color, l.animal_type, l.age, l.description,
-			l.image_urls, l.date_lost, l.last_seen_location, l.created_at,
+			l.image_urls, l.date_lost, l.last_seen_location, l.location_privacy, l.social_posting, l.created_at, l.updated_at,
 			loc.id, loc.street_address, loc.postal_code, loc.latitude, loc.longitude, loc.city_id
 		FROM lost_pet_listing l
 		LEFT JOIN locations loc ON l.last_seen_location = loc.id
-		WHERE l.id = $1
+		WHERE l.id = $1 AND l.deleted_at IS NULL
 	`
 
 	var pet LostPetResponse
@@ -395,40 +420,117 @@ func getLostPetByID(ctx context.Context, conn *pgx.Conn, id, email string) (even
 	err := conn.QueryRow(ctx, query, id).Scan(
 		&pet.ID, &pet.ListingOwner, &pet.IsFound, &dateFound, &pet.PetName, &pet.PetID,
 		&pet.Gender, &pet.Breed, &pet.Color, &pet.AnimalType, &pet.Age, &pet.Description,
-		&pet.ImageURLs, &pet.DateLost, &pet.LastSeenLocation, &pet.CreatedAt,
+		&pet.ImageURLs, &pet.DateLost, &pet.LastSeenLocation, &pet.LocationPrivacy, &pet.SocialPosting, &pet.CreatedAt, &pet.UpdatedAt,
 		&location.ID, &location.StreetAddress, &location.PostalCode, &location.Latitude, &location.Longitude, &location.CityID,
 	)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return generic.Response(http.StatusNotFound, generic.Json{
-				"error": "lost pet listing not found",
-			})
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
 		}
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to query lost pet listing",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query lost pet listing", err))
 	}
 
 	pet.DateFound = dateFound
 	pet.Location = &location
 
+	// Only the owner sees the exact last seen location
+	viewer := viewerUUID(ctx, conn, email)
+	if level := visibleLevel(pet.LocationPrivacy, pet.ListingOwner, viewer); level != generic.PrivacyExact {
+		pet.LastSeenLocation = 0
+		publicLocation(pet.Location, pet.ID, level)
+	}
+	if email == "" {
+		publicFields(&pet)
+	}
+
 	return generic.Response(http.StatusOK, generic.Json{
 		"data": pet,
 	})
 }
 
-func getAllLostPets(ctx context.Context, conn *pgx.Conn, queryParams map[string]string, email string) (events.APIGatewayProxyResponse, error) {
-	query := `
-		SELECT 
-			l.id, l.listing_owner, l.is_found, l.date_found, l.pet_name, l.pet_id,
-			l.gender, l.breed, l.color, l.animal_type, l.age, l.description,
-			l.image_urls, l.date_lost, l.last_seen_location, l.created_at
-		FROM lost_pet_listing l
-		WHERE 1=1
-	`
+// GET /lost-listing/{id}/history - visible to the listing owner and moderators
+func getLostPetHistory(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id, email string) (events.APIGatewayProxyResponse, error) {
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	// History outlives deleted listings, so a missing row is only a problem for non-moderators
+	var ownerID string
+	err = conn.QueryRow(ctx, `SELECT listing_owner FROM lost_pet_listing WHERE id = $1`, id).Scan(&ownerID)
+	if err != nil && err != pgx.ErrNoRows {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
+	}
 
+	if ownerID != userUUID {
+		isModerator, err := generic.IsModerator(ctx, conn, userUUID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to check moderator role", err))
+		}
+		if !isModerator {
+			if ownerID == "" {
+				return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+			}
+			return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "you do not have permission to view this listing's history"})
+		}
+	}
+
+	entries, err := generic.ListHistory(ctx, conn, generic.ListingTypeLost, id)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query listing history", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data":  entries,
+		"count": len(entries),
+	})
+}
+
+// resolveSort maps the sort and order query parameters to a keyset sort. Distance
+// binds the origin point, so those arguments are appended to args.
+func resolveSort(params map[string]string, args []interface{}, searchPos int) (generic.SortKey, []interface{}, error) {
+	sort := generic.SortKey{Name: params["sort"], IDColumn: "l.id", Type: "timestamptz", Desc: true}
+
+	switch sort.Name {
+	case "", generic.SortCreated:
+		sort.Name = generic.SortCreated
+		sort.Column = "l.created_at"
+	case "dateLost":
+		sort.Column = "l.date_lost"
+	case generic.SortUpdated:
+		sort.Column = "l.updated_at"
+	case generic.SortDistance:
+		lat, lng, err := generic.ParseOrigin(params)
+		if err != nil {
+			return generic.SortKey{}, args, err
+		}
+		args = append(args, lat, lng)
+		// Metres, so the order comes straight off the locations spatial index
+		sort.Column = generic.NearestFirst("loc.geog", generic.GeogParam(len(args)-1, len(args)))
+		sort.Type = "float8"
+		sort.Desc = false
+	case generic.SortRelevance:
+		if searchPos == 0 {
+			return generic.SortKey{}, args, &generic.ValidationError{Message: "relevance sort requires a search query", Field: "q"}
+		}
+		sort.Column = "ts_rank(l.search_vector, websearch_to_tsquery('english', $" + strconv.Itoa(searchPos) + "))"
+		sort.Type = "real"
+	default:
+		return generic.SortKey{}, args, &generic.ValidationError{
+			Message: "must be one of: created, dateLost, updated, distance, relevance",
+			Field:   "sort",
+		}
+	}
+
+	sort, err := generic.WithOrder(sort, params["order"])
+	return sort, args, err
+}
+
+// listFilters builds the WHERE conditions shared by the list and export handlers from the
+// query parameters. The conditions assume lost_pet_listing is aliased l and locations loc.
+func listFilters(ctx context.Context, conn *pgx.Conn, queryParams map[string]string, email string) (string, []interface{}, int, error) {
+	where := ` WHERE l.deleted_at IS NULL`
 	args := []interface{}{}
 	argPos := 1
 
@@ -436,7 +538,7 @@ func getAllLostPets(ctx context.Context, conn *pgx.Conn, queryParams map[string]
 	if isFound, ok := queryParams["isFound"]; ok && isFound != "" {
 		isFoundBool, err := strconv.ParseBool(isFound)
 		if err == nil {
-			query += ` AND l.is_found = $` + strconv.Itoa(argPos)
+			where += ` AND l.is_found = $` + strconv.Itoa(argPos)
 			args = append(args, isFoundBool)
 			argPos++
 		}
@@ -444,51 +546,91 @@ func getAllLostPets(ctx context.Context, conn *pgx.Conn, queryParams map[string]
 
 	// Filter by animal type
 	if animalType, ok := queryParams["animalType"]; ok && animalType != "" {
-		query += ` AND l.animal_type = $` + strconv.Itoa(argPos)
+		where += ` AND l.animal_type = $` + strconv.Itoa(argPos)
 		args = append(args, animalType)
 		argPos++
 	}
 
 	// Filter by user
 	if mine, ok := queryParams["mine"]; ok && mine == "true" {
+		if email == "" {
+			return "", nil, 0, &generic.AuthError{Message: "sign in to list your own listings"}
+		}
 		userUUID, err := getUserUUID(ctx, conn, email)
 		if err != nil {
-			return generic.Response(http.StatusInternalServerError, generic.Json{
-				"error": "failed to get user",
-			})
+			return "", nil, 0, err
 		}
-		query += ` AND l.listing_owner = $` + strconv.Itoa(argPos)
+		where += ` AND l.listing_owner = $` + strconv.Itoa(argPos)
 		args = append(args, userUUID)
 		argPos++
 	}
 
-	// Pagination
-	limit := 50
-	if limitStr, ok := queryParams["limit"]; ok {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
-			limit = parsedLimit
-		}
+	// Full-text search over name, description and animal type
+	searchPos := 0
+	if q, ok := queryParams["q"]; ok && q != "" {
+		where += ` AND l.search_vector @@ websearch_to_tsquery('english', $` + strconv.Itoa(argPos) + `)`
+		args = append(args, q)
+		searchPos = argPos
+		argPos++
+	}
+
+	// Listings without a location can't be ranked by distance
+	if queryParams["sort"] == generic.SortDistance {
+		where += ` AND loc.id IS NOT NULL`
 	}
 
-	offset := 0
-	if offsetStr, ok := queryParams["offset"]; ok {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
+	return where, args, searchPos, nil
+}
+
+func getAllLostPets(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, email string) (events.APIGatewayProxyResponse, error) {
+	queryParams := request.QueryStringParameters
+
+	where, args, searchPos, err := listFilters(ctx, conn, queryParams, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+	from := `
+		FROM lost_pet_listing l
+		LEFT JOIN locations loc ON l.last_seen_location = loc.id
+	` + where
+	filterArgs := args
+
+	// Sorting and pagination
+	sort, args, err := resolveSort(queryParams, args, searchPos)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	page, err := generic.ParsePage(queryParams, sort)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var total int64
+	if page.Total != generic.TotalNone {
+		total, err = generic.CountTotal(ctx, conn, page.Total, from, filterArgs)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to count lost pet listings", err))
 		}
 	}
 
-	query += ` ORDER BY l.created_at DESC LIMIT $` + strconv.Itoa(argPos) + ` OFFSET $` + strconv.Itoa(argPos+1)
-	args = append(args, limit, offset)
+	pageClause, pageArgs := page.Clause(sort, args)
+	query := `
+		SELECT 
+			l.id, l.listing_owner, l.is_found, l.date_found, l.pet_name, l.pet_id,
+			l.gender, l.breed, l.color, l.animal_type, l.age, l.description,
+			l.image_urls, l.date_lost, l.last_seen_location, l.location_privacy, l.social_posting, l.created_at, l.updated_at,
+			(` + sort.Column + `)::text
+	` + from + pageClause
 
-	rows, err := conn.Query(ctx, query, args...)
+	rows, err := conn.Query(ctx, query, pageArgs...)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to query lost pet listings",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query lost pet listings", err))
 	}
 	defer rows.Close()
 
+	viewer := viewerUUID(ctx, conn, email)
+
 	var pets []LostPetResponse
 	for rows.Next() {
 		var pet LostPetResponse
@@ -497,97 +639,95 @@ func getAllLostPets(ctx context.Context, conn *pgx.Conn, queryParams map[string]
 		err := rows.Scan(
 			&pet.ID, &pet.ListingOwner, &pet.IsFound, &dateFound, &pet.PetName, &pet.PetID,
 			&pet.Gender, &pet.Breed, &pet.Color, &pet.AnimalType, &pet.Age, &pet.Description,
-			&pet.ImageURLs, &pet.DateLost, &pet.LastSeenLocation, &pet.CreatedAt,
+			&pet.ImageURLs, &pet.DateLost, &pet.LastSeenLocation, &pet.LocationPrivacy, &pet.SocialPosting, &pet.CreatedAt, &pet.UpdatedAt,
+			&pet.sortValue,
 		)
 		if err != nil {
-			return generic.Response(http.StatusInternalServerError, generic.Json{
-				"error":   "failed to scan lost pet listing",
-				"message": err.Error(),
-			})
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to scan lost pet listing", err))
 		}
 
 		pet.DateFound = dateFound
+		level := visibleLevel(pet.LocationPrivacy, pet.ListingOwner, viewer)
+		if level != generic.PrivacyExact {
+			pet.LastSeenLocation = 0
+		}
+		// Distances are ranked on the true location but rounded for everyone but the owner
+		if queryParams["sort"] == generic.SortDistance {
+			if metres, err := strconv.ParseFloat(pet.sortValue, 64); err == nil {
+				distance := generic.FuzzDistance(level, metres/1000)
+				pet.DistanceKm = &distance
+			}
+		}
+		if email == "" {
+			publicFields(&pet)
+		}
 		pets = append(pets, pet)
 	}
 
-	return generic.Response(http.StatusOK, generic.Json{
-		"data":   pets,
-		"count":  len(pets),
-		"limit":  limit,
-		"offset": offset,
+	pets, nextCursor, prevCursor := generic.PageResult(pets, page, sort, func(pet LostPetResponse) (string, int) {
+		return pet.sortValue, pet.ID
 	})
+
+	body := generic.Json{
+		"data":        pets,
+		"count":       len(pets),
+		"limit":       page.Limit,
+		"next_cursor": nextCursor,
+		"prev_cursor": prevCursor,
+	}
+	if page.Total != generic.TotalNone {
+		body["total"] = total
+		body["total_estimated"] = page.Total == generic.TotalEstimate
+	}
+
+	return generic.Response(http.StatusOK, body)
 }
 
 // UPDATE - PUT/PATCH /lost-listing/{id}
-func handleUpdate(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handleUpdate(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	_, email, err := extractUserFromToken(request)
 	if err != nil {
-		if authErr, ok := err.(*AuthError); ok {
-			return generic.Response(http.StatusUnauthorized, generic.Json{
-				"error": authErr.Message,
-			})
-		}
-		return generic.Response(http.StatusUnauthorized, generic.Json{
-			"error": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	listingID := request.PathParameters["id"]
 	if listingID == "" {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error": "listing ID is required",
-		})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID is required", Field: "id"})
 	}
 
 	var req LostPetRequest
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error":   "invalid request body",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+
+	if errs := req.Validate(true); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
 	}
 
-	ctx := context.Background()
 	conn, err := generic.SupabaseConnect()
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to connect to database",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
 	}
 	defer conn.Close(ctx)
 
 	userUUID, err := getUserUUID(ctx, conn, email)
 	if err != nil {
-		if _, ok := err.(*NotFoundError); ok {
-			return generic.Response(http.StatusNotFound, generic.Json{
-				"error": err.Error(),
-			})
-		}
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error": "failed to get user",
-		})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	// Verify ownership
 	var ownerID string
-	checkQuery := `SELECT listing_owner FROM lost_pet_listing WHERE id = $1`
+	checkQuery := `SELECT listing_owner FROM lost_pet_listing WHERE id = $1 AND deleted_at IS NULL`
 	err = conn.QueryRow(ctx, checkQuery, listingID).Scan(&ownerID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return generic.Response(http.StatusNotFound, generic.Json{
-				"error": "lost pet listing not found",
-			})
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
 		}
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error": "failed to verify ownership",
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
 	}
 
 	if ownerID != userUUID {
-		return generic.Response(http.StatusForbidden, generic.Json{
-			"error": "you do not have permission to update this listing",
-		})
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "you do not have permission to update this listing"})
 	}
 
 	// Build update query dynamically
@@ -628,9 +768,7 @@ func handleUpdate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 	if req.DateLost != "" {
 		dateLost, err := parseDate(req.DateLost)
 		if err != nil {
-			return generic.Response(http.StatusBadRequest, generic.Json{
-				"error": err.Error(),
-			})
+			return generic.ErrorResponse(ctx, request, err)
 		}
 		updateFields = append(updateFields, "date_lost = $"+strconv.Itoa(argPos))
 		args = append(args, dateLost)
@@ -646,6 +784,16 @@ func handleUpdate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 		args = append(args, req.PetID)
 		argPos++
 	}
+	if req.LocationPrivacy != nil {
+		updateFields = append(updateFields, "location_privacy = $"+strconv.Itoa(argPos))
+		args = append(args, *req.LocationPrivacy)
+		argPos++
+	}
+	if req.SocialPosting != nil {
+		updateFields = append(updateFields, "social_posting = $"+strconv.Itoa(argPos))
+		args = append(args, *req.SocialPosting)
+		argPos++
+	}
 	if req.IsFound != nil {
 		updateFields = append(updateFields, "is_found = $"+strconv.Itoa(argPos))
 		args = append(args, *req.IsFound)
@@ -656,21 +804,22 @@ func handleUpdate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 			argPos++
 		}
 	}
-	if req.Location != "" && req.LocationCoords != nil {
+	if req.Location != "" && (req.LocationCoords != nil || req.City != "") {
+		place, err := generic.ResolvePlace(ctx, req.place())
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+
 		// 1. Create or get city
-		cityID, err := getOrCreateCity(ctx, conn, req.City, req.ProvinceOrState, req.Country)
+		cityID, err := generic.GetOrCreateCity(ctx, conn, place)
 		if err != nil {
-			return generic.Response(http.StatusInternalServerError, generic.Json{
-				"error": "failed to create or fetch city",
-			})
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to create or fetch city", err))
 		}
 
 		// 2. Create or get location with cityID
-		locationID, err := getOrCreateLocation(ctx, conn, req.Location, req.PostalCode, req.LocationCoords.Lat, req.LocationCoords.Lng, cityID)
+		locationID, err := generic.GetOrCreateLocation(ctx, conn, place, cityID)
 		if err != nil {
-			return generic.Response(http.StatusInternalServerError, generic.Json{
-				"error": "failed to update location",
-			})
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to update location", err))
 		}
 
 		updateFields = append(updateFields, "last_seen_location = $"+strconv.Itoa(argPos))
@@ -679,26 +828,66 @@ func handleUpdate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 	}
 
 	if len(updateFields) == 0 {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error": "no fields to update",
-		})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "no fields to update"})
+	}
+
+	// Each update field binds exactly one argument, so the new values line up with args
+	columns := make([]string, len(updateFields))
+	after := make(map[string]any, len(updateFields))
+	for i, field := range updateFields {
+		columns[i] = strings.TrimSpace(strings.SplitN(field, "=", 2)[0])
+		after[columns[i]] = args[i]
+	}
+
+	updateFields = append(updateFields, "updated_at = $"+strconv.Itoa(argPos))
+	args = append(args, time.Now())
+	argPos++
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := generic.RowValues(ctx, tx, `SELECT `+strings.Join(columns, ", ")+` FROM lost_pet_listing WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, columns, listingID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to read lost pet listing", err))
 	}
 
 	args = append(args, listingID)
 	updateQuery := `UPDATE lost_pet_listing SET ` + strings.Join(updateFields, ", ") + ` WHERE id = $` + strconv.Itoa(argPos)
 
-	result, err := conn.Exec(ctx, updateQuery, args...)
+	result, err := tx.Exec(ctx, updateQuery, args...)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to update lost pet listing",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update lost pet listing", err))
 	}
 
 	if result.RowsAffected() == 0 {
-		return generic.Response(http.StatusNotFound, generic.Json{
-			"error": "lost pet listing not found",
-		})
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+	}
+
+	changes := generic.Diff(before, after)
+	action := generic.ActionUpdate
+	if change, ok := changes["is_found"]; ok {
+		action = generic.ActionUnfound
+		if found, _ := change.New.(bool); found {
+			action = generic.ActionFound
+		}
+	}
+	if len(changes) > 0 {
+		if err := generic.RecordHistory(ctx, tx, generic.ListingTypeLost, listingID, userUUID, action, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+		}
+		if err := generic.RecordEvent(ctx, tx, generic.ListingTypeLost, listingID, action, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update lost pet listing", err))
 	}
 
 	return generic.Response(http.StatusOK, generic.Json{
@@ -713,94 +902,160 @@ func getUserUUID(ctx context.Context, conn *pgx.Conn, email string) (string, err
 	err := conn.QueryRow(ctx, queryUser, email).Scan(&userUUID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return "", &NotFoundError{Message: "user not found"}
+			return "", &generic.NotFoundError{Message: "user not found"}
 		}
-		return "", err
+		return "", generic.Internal("failed to query user", err)
 	}
+	generic.SetUser(ctx, userUUID)
 	return userUUID, nil
 }
 
 // DELETE - DELETE /lost-listing/{id}
-func handleDelete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handleDelete(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	_, email, err := extractUserFromToken(request)
 	if err != nil {
-		if authErr, ok := err.(*AuthError); ok {
-			return generic.Response(http.StatusUnauthorized, generic.Json{
-				"error": authErr.Message,
-			})
-		}
-		return generic.Response(http.StatusUnauthorized, generic.Json{
-			"error": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	listingID := request.PathParameters["id"]
 	if listingID == "" {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error": "listing ID is required",
-		})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID is required", Field: "id"})
 	}
 
-	ctx := context.Background()
 	conn, err := generic.SupabaseConnect()
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to connect to database",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
 	}
 	defer conn.Close(ctx)
 
 	userUUID, err := getUserUUID(ctx, conn, email)
 	if err != nil {
-		if _, ok := err.(*NotFoundError); ok {
-			return generic.Response(http.StatusNotFound, generic.Json{
-				"error": err.Error(),
-			})
-		}
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error": "failed to get user",
-		})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	// Verify ownership
 	var ownerID string
-	checkQuery := `SELECT listing_owner FROM lost_pet_listing WHERE id = $1`
+	checkQuery := `SELECT listing_owner FROM lost_pet_listing WHERE id = $1 AND deleted_at IS NULL`
 	err = conn.QueryRow(ctx, checkQuery, listingID).Scan(&ownerID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return generic.Response(http.StatusNotFound, generic.Json{
-				"error": "lost pet listing not found",
-			})
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
 		}
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error": "failed to verify ownership",
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
 	}
 
 	if ownerID != userUUID {
-		return generic.Response(http.StatusForbidden, generic.Json{
-			"error": "you do not have permission to delete this listing",
-		})
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "you do not have permission to delete this listing"})
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
 	}
+	defer tx.Rollback(ctx)
 
-	deleteQuery := `DELETE FROM lost_pet_listing WHERE id = $1`
-	result, err := conn.Exec(ctx, deleteQuery, listingID)
+	// Soft delete; the purge job removes the row and its images once the grace period is over
+	deleteQuery := `UPDATE lost_pet_listing SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	result, err := tx.Exec(ctx, deleteQuery, time.Now(), listingID)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to delete lost pet listing",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to delete lost pet listing", err))
 	}
 
 	if result.RowsAffected() == 0 {
-		return generic.Response(http.StatusNotFound, generic.Json{
-			"error": "lost pet listing not found",
-		})
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+	}
+
+	if err := generic.RecordHistory(ctx, tx, generic.ListingTypeLost, listingID, userUUID, generic.ActionDelete, nil); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+	}
+	if err := generic.RecordEvent(ctx, tx, generic.ListingTypeLost, listingID, generic.ActionDelete, nil); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to delete lost pet listing", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"message":       "Lost pet listing deleted successfully",
+		"id":            listingID,
+		"restore_until": time.Now().Add(generic.DeleteGracePeriod),
+	})
+}
+
+// RESTORE - POST /lost-listing/{id}/restore
+func handleRestore(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	listingID := request.PathParameters["id"]
+	if listingID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID is required", Field: "id"})
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var ownerID string
+	var deletedAt *time.Time
+	checkQuery := `SELECT listing_owner, deleted_at FROM lost_pet_listing WHERE id = $1`
+	err = conn.QueryRow(ctx, checkQuery, listingID).Scan(&ownerID, &deletedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
+	}
+
+	if ownerID != userUUID {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "you do not have permission to restore this listing"})
+	}
+	if deletedAt == nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "lost pet listing is not deleted"})
+	}
+	if time.Since(*deletedAt) > generic.DeleteGracePeriod {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing can no longer be restored"})
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	restoreQuery := `UPDATE lost_pet_listing SET deleted_at = NULL, updated_at = $1 WHERE id = $2 AND deleted_at IS NOT NULL`
+	result, err := tx.Exec(ctx, restoreQuery, time.Now(), listingID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to restore lost pet listing", err))
+	}
+
+	if result.RowsAffected() == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+	}
+
+	if err := generic.RecordHistory(ctx, tx, generic.ListingTypeLost, listingID, userUUID, generic.ActionRestore, nil); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+	}
+	if err := generic.RecordEvent(ctx, tx, generic.ListingTypeLost, listingID, generic.ActionRestore, nil); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to restore lost pet listing", err))
 	}
 
 	return generic.Response(http.StatusOK, generic.Json{
-		"message": "Lost pet listing deleted successfully",
+		"message": "Lost pet listing restored successfully",
 		"id":      listingID,
 	})
 }
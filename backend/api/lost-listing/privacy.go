@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/jackc/pgx/v5"
+)
+
+// viewerUUID returns the caller's user UUID, or "" when they are anonymous or have no
+// account. Unlike getUserUUID a missing user is not an error, since reads only need it
+// to spot owners.
+func viewerUUID(ctx context.Context, conn *pgx.Conn, email string) string {
+	if email == "" {
+		return ""
+	}
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return ""
+	}
+	return userUUID
+}
+
+// visibleLevel is the privacy level a listing's location is shown to the viewer at: exact
+// for its owner, the owner's choice for other users and at least block for anonymous callers
+func visibleLevel(level, owner, viewer string) string {
+	switch {
+	case viewer == "":
+		return generic.AnonymousPrivacy(level)
+	case viewer == owner:
+		return generic.PrivacyExact
+	}
+	return level
+}
+
+// publicFields clears the fields only signed-in users see: who owns the listing and the
+// pet's microchip or tag ID
+func publicFields(pet *LostPetResponse) {
+	pet.ListingOwner = ""
+	pet.PetID = nil
+}
+
+// publicLocation replaces a listing's location with the fuzzed version non-owners see.
+// The location ID is dropped too, since other rows sharing it would reveal the exact point.
+func publicLocation(location *Location, listingID int, level string) {
+	if location == nil || level == generic.PrivacyExact {
+		return
+	}
+	seed := generic.PrivacySeed(generic.ListingTypeLost, listingID)
+	location.ID = 0
+	location.Latitude, location.Longitude = generic.FuzzCoordinates(level, seed, location.Latitude, location.Longitude)
+	location.StreetAddress = generic.FuzzAddress(level, location.StreetAddress)
+	location.PostalCode = generic.FuzzPostalCode(level, location.PostalCode)
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// How a lost pet was found
+const (
+	OutcomeReturnedHome     = "returned_home"
+	OutcomeFoundViaSighting = "found_via_sighting"
+	OutcomeShelter          = "shelter"
+	OutcomeDeceased         = "deceased"
+)
+
+var reunificationOutcomes = []string{OutcomeReturnedHome, OutcomeFoundViaSighting, OutcomeShelter, OutcomeDeceased}
+
+type ReunificationRequest struct {
+	Outcome    string  `json:"outcome"`
+	SightingID *int    `json:"sightingId,omitempty"`
+	HelperUser *string `json:"helperUserId,omitempty"`
+	DateFound  string  `json:"dateFound,omitempty"`
+	Note       *string `json:"note,omitempty"`
+
+	Location       string `json:"location,omitempty"`
+	LocationCoords *struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"locationCoords,omitempty"`
+	PostalCode      *string `json:"postalCode,omitempty"`
+	City            string  `json:"city,omitempty"`
+	ProvinceOrState string  `json:"provinceOrState,omitempty"`
+	Country         string  `json:"country,omitempty"`
+}
+
+type ReunificationResponse struct {
+	ID            int       `json:"id"`
+	LostListingID int       `json:"lost_listing_id"`
+	Outcome       string    `json:"outcome"`
+	SightingID    *int      `json:"sighting_id,omitempty"`
+	HelperUser    *string   `json:"helper_user,omitempty"`
+	FoundLocation *Location `json:"found_location,omitempty"`
+	Note          *string   `json:"note,omitempty"`
+	DateFound     time.Time `json:"date_found"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (r *ReunificationRequest) Validate() generic.ValidationErrors {
+	rules := []generic.Rule{
+		generic.Required("outcome", r.Outcome),
+		generic.OneOf("outcome", r.Outcome, reunificationOutcomes),
+		generic.PastDate("dateFound", r.DateFound),
+	}
+	if r.Outcome == OutcomeFoundViaSighting {
+		rules = append(rules, generic.RequiredValue("sightingId", r.SightingID != nil))
+	}
+	if r.HelperUser != nil {
+		_, err := uuid.Parse(*r.HelperUser)
+		rules = append(rules, func() *generic.FieldError {
+			if err != nil {
+				return &generic.FieldError{Field: "helperUserId", Code: generic.CodeInvalid, Message: "must be a user UUID"}
+			}
+			return nil
+		})
+	}
+	if r.Note != nil {
+		rules = append(rules, generic.MaxLength("note", *r.Note, 1000))
+	}
+	if r.LocationCoords != nil || r.City != "" {
+		rules = append(rules, generic.Required("location", r.Location))
+	}
+	if r.LocationCoords != nil {
+		rules = append(rules,
+			generic.InRange("locationCoords.lat", r.LocationCoords.Lat, -90, 90),
+			generic.InRange("locationCoords.lng", r.LocationCoords.Lng, -180, 180),
+		)
+	}
+	return generic.Validate(rules...)
+}
+
+// place is the address in the request, without coordinates if it has none
+func (r *ReunificationRequest) place() generic.Place {
+	place := generic.Place{
+		StreetAddress:   r.Location,
+		PostalCode:      r.PostalCode,
+		City:            r.City,
+		ProvinceOrState: r.ProvinceOrState,
+		Country:         r.Country,
+	}
+	if r.LocationCoords != nil {
+		place.Lat, place.Lng = r.LocationCoords.Lat, r.LocationCoords.Lng
+	}
+	return place
+}
+
+// POST /lost-listing/{id}/reunification - records how the pet was found and marks the listing found
+func handleCreateReunification(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	listingID := request.PathParameters["id"]
+	if listingID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID is required", Field: "id"})
+	}
+
+	var req ReunificationRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+
+	if errs := req.Validate(); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	// Verify ownership
+	var ownerID, animalType string
+	var dateLost time.Time
+	checkQuery := `SELECT listing_owner, animal_type, date_lost FROM lost_pet_listing WHERE id = $1 AND deleted_at IS NULL`
+	err = conn.QueryRow(ctx, checkQuery, listingID).Scan(&ownerID, &animalType, &dateLost)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
+	}
+
+	if ownerID != userUUID {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "you do not have permission to update this listing"})
+	}
+
+	// The sighting's reporter is credited unless the owner names someone else
+	helperUser := req.HelperUser
+	if req.SightingID != nil {
+		var sightingOwner, sightingType string
+		var dateSpotted time.Time
+		sightingQuery := `SELECT listing_owner, animal_type, date_spotted FROM sighting_listing WHERE id = $1 AND deleted_at IS NULL`
+		err = conn.QueryRow(ctx, sightingQuery, *req.SightingID).Scan(&sightingOwner, &sightingType, &dateSpotted)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "sighting not found", Field: "sightingId"})
+			}
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to query sighting", err))
+		}
+		// The sighting's reporter gets reputation for it, so it has to be of this pet
+		if !strings.EqualFold(sightingType, animalType) {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "the sighting is of a different animal type", Field: "sightingId"})
+		}
+		if dateSpotted.Before(dateLost) {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "the sighting is from before the pet was lost", Field: "sightingId"})
+		}
+		if helperUser == nil && sightingOwner != userUUID {
+			helperUser = &sightingOwner
+		}
+	}
+
+	if req.HelperUser != nil {
+		// Already parsed by Validate
+		helper := uuid.MustParse(*req.HelperUser).String()
+		if helper == userUUID {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "can't credit yourself", Field: "helperUserId"})
+		}
+		var exists bool
+		err = conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE user_uuid = $1)`, helper).Scan(&exists)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to query helper user", err))
+		}
+		if !exists {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "user not found", Field: "helperUserId"})
+		}
+		helperUser = &helper
+	}
+
+	dateFound := time.Now()
+	if req.DateFound != "" {
+		if dateFound, err = generic.ParseDate(req.DateFound); err != nil {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid date format", Field: "dateFound"})
+		}
+	}
+
+	var foundLocation *int
+	if req.LocationCoords != nil || req.City != "" {
+		place, err := generic.ResolvePlace(ctx, req.place())
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+		cityID, err := generic.GetOrCreateCity(ctx, conn, place)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to create or fetch city", err))
+		}
+		locationID, err := generic.GetOrCreateLocation(ctx, conn, place, cityID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to create location", err))
+		}
+		foundLocation = &locationID
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	var wasFound bool
+	var previousDateFound *time.Time
+	err = tx.QueryRow(ctx, `SELECT is_found, date_found FROM lost_pet_listing WHERE id = $1 FOR UPDATE`, listingID).Scan(&wasFound, &previousDateFound)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to read lost pet listing", err))
+	}
+
+	insertQuery := `
+		INSERT INTO reunifications (
+			lost_listing_id, outcome, sighting_id, helper_user, found_location, note, date_found, created_by, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9
+		)
+		ON CONFLICT (lost_listing_id) DO NOTHING
+		RETURNING id
+	`
+	var reunificationID int
+	err = tx.QueryRow(ctx, insertQuery,
+		listingID, req.Outcome, req.SightingID, helperUser, foundLocation, req.Note, dateFound, userUUID, time.Now(),
+	).Scan(&reunificationID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "a reunification has already been recorded for this listing"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record reunification", err))
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE lost_pet_listing SET is_found = TRUE, date_found = $1, updated_at = $2 WHERE id = $3`, dateFound, time.Now(), listingID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update lost pet listing", err))
+	}
+
+	changes := generic.Diff(
+		map[string]any{"is_found": wasFound, "date_found": previousDateFound},
+		map[string]any{"is_found": true, "date_found": dateFound, "reunification_outcome": req.Outcome},
+	)
+	if err := generic.RecordHistory(ctx, tx, generic.ListingTypeLost, listingID, userUUID, generic.ActionFound, changes); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+	}
+	if err := generic.RecordEvent(ctx, tx, generic.ListingTypeLost, listingID, generic.ActionFound, changes); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record reunification", err))
+	}
+
+	return generic.Response(http.StatusCreated, generic.Json{
+		"message": "Reunification recorded successfully",
+		"id":      reunificationID,
+	})
+}
+
+// GET /lost-listing/{id}/reunification
+func getReunification(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id, email string) (events.APIGatewayProxyResponse, error) {
+	query := `
+		SELECT
+			l.listing_owner, l.location_privacy,
+			r.id, r.lost_listing_id, r.outcome, r.sighting_id, r.helper_user, r.note, r.date_found, r.created_at,
+			loc.id, loc.street_address, loc.postal_code, loc.latitude, loc.longitude, loc.city_id
+		FROM reunifications r
+		JOIN lost_pet_listing l ON l.id = r.lost_listing_id AND l.deleted_at IS NULL
+		LEFT JOIN locations loc ON r.found_location = loc.id
+		WHERE r.lost_listing_id = $1
+	`
+
+	var reunification ReunificationResponse
+	var owner, privacy string
+	var locationID *int
+	var location Location
+	var streetAddress *string
+	var latitude, longitude *float64
+
+	err := conn.QueryRow(ctx, query, id).Scan(
+		&owner, &privacy,
+		&reunification.ID, &reunification.LostListingID, &reunification.Outcome, &reunification.SightingID,
+		&reunification.HelperUser, &reunification.Note, &reunification.DateFound, &reunification.CreatedAt,
+		&locationID, &streetAddress, &location.PostalCode, &latitude, &longitude, &location.CityID,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "reunification not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query reunification", err))
+	}
+
+	if locationID != nil {
+		location.ID = *locationID
+		location.StreetAddress = *streetAddress
+		location.Latitude = *latitude
+		location.Longitude = *longitude
+		reunification.FoundLocation = &location
+		// Where the pet was found is often the owner's home, so it gets the listing's privacy level
+		publicLocation(reunification.FoundLocation, reunification.LostListingID, visibleLevel(privacy, owner, viewerUUID(ctx, conn, email)))
+	}
+	// Anonymous callers don't see who helped or the owner's note
+	if email == "" {
+		reunification.HelperUser = nil
+		reunification.Note = nil
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data": reunification,
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// GET /lost-listing/{id}/nearby-shelters - shelters and rescues nearest the pet's last seen location
+func getNearbyShelters(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id, email string) (events.APIGatewayProxyResponse, error) {
+	radius, limit, err := generic.ParseShelterSearch(request.QueryStringParameters)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var owner, privacy string
+	var lat, lng *float64
+	query := `
+		SELECT l.listing_owner, l.location_privacy, loc.latitude, loc.longitude
+		FROM lost_pet_listing l
+		LEFT JOIN locations loc ON l.last_seen_location = loc.id
+		WHERE l.id = $1 AND l.deleted_at IS NULL
+	`
+	err = conn.QueryRow(ctx, query, id).Scan(&owner, &privacy, &lat, &lng)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query lost pet listing", err))
+	}
+	if lat == nil || lng == nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing has no last seen location"})
+	}
+
+	shelters, err := generic.NearbyShelters(ctx, conn, *lat, *lng, radius, limit)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query nearby shelters", err))
+	}
+
+	// Exact distances to known shelters would pin down a private last seen location
+	if level := visibleLevel(privacy, owner, viewerUUID(ctx, conn, email)); level != generic.PrivacyExact {
+		for i := range shelters {
+			shelters[i].DistanceKm = generic.FuzzDistance(level, shelters[i].DistanceKm)
+		}
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data":      shelters,
+		"count":     len(shelters),
+		"radius_km": radius,
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+type SocialPostResponse struct {
+	Channel    string     `json:"channel"`
+	Status     string     `json:"status"`
+	ExternalID *string    `json:"external_id,omitempty"`
+	URL        *string    `json:"url,omitempty"`
+	LastError  *string    `json:"last_error,omitempty"`
+	Attempts   int        `json:"attempts"`
+	PostedAt   *time.Time `json:"posted_at,omitempty"`
+	RemovedAt  *time.Time `json:"removed_at,omitempty"`
+}
+
+// GET /lost-listing/{id}/social-posts - the listing's posts on each social channel, for its owner
+func getSocialPosts(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id, email string) (events.APIGatewayProxyResponse, error) {
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var ownerID string
+	var socialPosting bool
+	err = conn.QueryRow(ctx, `SELECT listing_owner, social_posting FROM lost_pet_listing WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&ownerID, &socialPosting)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
+	}
+
+	if ownerID != userUUID {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "you do not have permission to view this listing's social posts"})
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT channel, status, external_id, url, last_error, attempts, posted_at, removed_at
+		FROM social_posts
+		WHERE listing_id = $1
+		ORDER BY channel
+	`, id)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query social posts", err))
+	}
+	defer rows.Close()
+
+	posts := []SocialPostResponse{}
+	for rows.Next() {
+		var post SocialPostResponse
+		if err := rows.Scan(&post.Channel, &post.Status, &post.ExternalID, &post.URL, &post.LastError,
+			&post.Attempts, &post.PostedAt, &post.RemovedAt); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to scan social post", err))
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query social posts", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"social_posting": socialPosting,
+		"data":           posts,
+		"count":          len(posts),
+	})
+}
+
+// DELETE /lost-listing/{id}/social-posts - opts the listing out of social posting. The
+// social-publish job takes the existing posts down on its next run.
+func handleRemoveSocialPosts(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	listingID := request.PathParameters["id"]
+	if listingID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID is required", Field: "id"})
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	var ownerID string
+	var socialPosting bool
+	err = tx.QueryRow(ctx, `SELECT listing_owner, social_posting FROM lost_pet_listing WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, listingID).Scan(&ownerID, &socialPosting)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
+	}
+
+	if ownerID != userUUID {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "you do not have permission to remove this listing's social posts"})
+	}
+
+	if socialPosting {
+		_, err = tx.Exec(ctx, `UPDATE lost_pet_listing SET social_posting = false, updated_at = $1 WHERE id = $2`, time.Now(), listingID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to update lost pet listing", err))
+		}
+
+		changes := generic.Changes{"social_posting": {Old: true, New: false}}
+		if err := generic.RecordHistory(ctx, tx, generic.ListingTypeLost, listingID, userUUID, generic.ActionUpdate, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+		}
+		if err := generic.RecordEvent(ctx, tx, generic.ListingTypeLost, listingID, generic.ActionUpdate, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update lost pet listing", err))
+	}
+
+	return generic.Response(http.StatusAccepted, generic.Json{
+		"message": "Social posts for this listing will be removed shortly",
+		"id":      listingID,
+	})
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// TrailPointRequest puts an accepted sighting on a lost pet's trail, or a place the
+// owner saw or heard of the pet themselves
+type TrailPointRequest struct {
+	SightingID *int    `json:"sightingId,omitempty"`
+	SeenAt     string  `json:"seenAt,omitempty"`
+	Note       *string `json:"note,omitempty"`
+
+	Location       string `json:"location,omitempty"`
+	LocationCoords *struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"locationCoords,omitempty"`
+	PostalCode      *string `json:"postalCode,omitempty"`
+	City            string  `json:"city,omitempty"`
+	ProvinceOrState string  `json:"provinceOrState,omitempty"`
+	Country         string  `json:"country,omitempty"`
+}
+
+func (r *TrailPointRequest) Validate() generic.ValidationErrors {
+	rules := []generic.Rule{generic.PastDate("seenAt", r.SeenAt)}
+	if r.SightingID != nil {
+		rules = append(rules, func() *generic.FieldError {
+			if r.Location != "" || r.LocationCoords != nil || r.City != "" || r.SeenAt != "" {
+				return &generic.FieldError{Field: "sightingId", Code: generic.CodeInvalid, Message: "a sighting brings its own location and date"}
+			}
+			return nil
+		})
+	} else {
+		rules = append(rules,
+			generic.Required("location", r.Location),
+			generic.RequiredValue("locationCoords", r.LocationCoords != nil || r.City != ""),
+		)
+	}
+	if r.Note != nil {
+		rules = append(rules, generic.MaxLength("note", *r.Note, 1000))
+	}
+	if r.LocationCoords != nil {
+		rules = append(rules,
+			generic.InRange("locationCoords.lat", r.LocationCoords.Lat, -90, 90),
+			generic.InRange("locationCoords.lng", r.LocationCoords.Lng, -180, 180),
+		)
+	}
+	return generic.Validate(rules...)
+}
+
+// place is the address in the request, without coordinates if it has none
+func (r *TrailPointRequest) place() generic.Place {
+	place := generic.Place{
+		StreetAddress:   r.Location,
+		PostalCode:      r.PostalCode,
+		City:            r.City,
+		ProvinceOrState: r.ProvinceOrState,
+		Country:         r.Country,
+	}
+	if r.LocationCoords != nil {
+		place.Lat, place.Lng = r.LocationCoords.Lat, r.LocationCoords.Lng
+	}
+	return place
+}
+
+// POST /lost-listing/{id}/trail - adds an accepted sighting or an owner update to the trail
+func handleCreateTrailPoint(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	listingID := request.PathParameters["id"]
+	if listingID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID is required", Field: "id"})
+	}
+
+	var req TrailPointRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+
+	if errs := req.Validate(); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	// Verify ownership
+	var ownerID, animalType string
+	var dateLost time.Time
+	checkQuery := `SELECT listing_owner, animal_type, date_lost FROM lost_pet_listing WHERE id = $1 AND deleted_at IS NULL`
+	err = conn.QueryRow(ctx, checkQuery, listingID).Scan(&ownerID, &animalType, &dateLost)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
+	}
+
+	if ownerID != userUUID {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "you do not have permission to update this listing's trail"})
+	}
+
+	var locationID *int
+	var seenAt *time.Time
+	if req.SightingID != nil {
+		var sightingType string
+		var dateSpotted time.Time
+		sightingQuery := `SELECT animal_type, date_spotted FROM sighting_listing WHERE id = $1 AND deleted_at IS NULL`
+		err = conn.QueryRow(ctx, sightingQuery, *req.SightingID).Scan(&sightingType, &dateSpotted)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "sighting not found", Field: "sightingId"})
+			}
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to query sighting", err))
+		}
+		if !strings.EqualFold(sightingType, animalType) {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "the sighting is of a different animal type", Field: "sightingId"})
+		}
+		if dateSpotted.Before(dateLost) {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "the sighting is from before the pet was lost", Field: "sightingId"})
+		}
+	} else {
+		seen := time.Now()
+		if req.SeenAt != "" {
+			if seen, err = generic.ParseDate(req.SeenAt); err != nil {
+				return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid date format", Field: "seenAt"})
+			}
+		}
+		if seen.Before(dateLost) {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "must not be before the pet was lost", Field: "seenAt"})
+		}
+		seenAt = &seen
+
+		place, err := generic.ResolvePlace(ctx, req.place())
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+		cityID, err := generic.GetOrCreateCity(ctx, conn, place)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to create or fetch city", err))
+		}
+		id, err := generic.GetOrCreateLocation(ctx, conn, place, cityID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to create location", err))
+		}
+		locationID = &id
+	}
+
+	insertQuery := `
+		INSERT INTO trail_points (lost_listing_id, sighting_id, location_id, seen_at, note, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (lost_listing_id, sighting_id) DO NOTHING
+		RETURNING id
+	`
+	var pointID int
+	err = conn.QueryRow(ctx, insertQuery,
+		listingID, req.SightingID, locationID, seenAt, req.Note, userUUID, time.Now(),
+	).Scan(&pointID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "the sighting is already on this trail", Field: "sightingId"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to add trail point", err))
+	}
+
+	return generic.Response(http.StatusCreated, generic.Json{
+		"message": "Trail point added successfully",
+		"id":      pointID,
+	})
+}
+
+// DELETE /lost-listing/{id}/trail/{pointId} - takes a sighting or owner update off the trail
+func handleDeleteTrailPoint(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	listingID := request.PathParameters["id"]
+	pointID := request.PathParameters["pointId"]
+	if listingID == "" || pointID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID and trail point ID are required", Field: "pointId"})
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var ownerID string
+	err = conn.QueryRow(ctx, `SELECT listing_owner FROM lost_pet_listing WHERE id = $1 AND deleted_at IS NULL`, listingID).Scan(&ownerID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
+	}
+
+	if ownerID != userUUID {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "you do not have permission to update this listing's trail"})
+	}
+
+	tag, err := conn.Exec(ctx, `DELETE FROM trail_points WHERE id = $1 AND lost_listing_id = $2`, pointID, listingID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to remove trail point", err))
+	}
+	if tag.RowsAffected() == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "trail point not found"})
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"message": "Trail point removed successfully",
+		"id":      pointID,
+	})
+}
+
+// GET /lost-listing/{id}/trail - the trail as a GeoJSON FeatureCollection: a LineString
+// through the points in time order, a Point per sighting or update, and the predicted
+// search area as a Polygon while the pet is still missing
+func getTrail(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id, email string) (events.APIGatewayProxyResponse, error) {
+	listingID, err := strconv.Atoi(id)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+	}
+
+	var owner, privacy string
+	var isFound bool
+	err = conn.QueryRow(ctx, `SELECT listing_owner, location_privacy, is_found FROM lost_pet_listing WHERE id = $1 AND deleted_at IS NULL`, listingID).Scan(&owner, &privacy, &isFound)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "lost pet listing not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query lost pet listing", err))
+	}
+
+	// Sightings deleted by their reporter drop off the trail
+	query := `
+		SELECT 0, $2::text, NULL::integer, l.date_lost::timestamptz, NULL::text, loc.latitude, loc.longitude
+		FROM lost_pet_listing l
+		JOIN locations loc ON loc.id = l.last_seen_location
+		WHERE l.id = $1
+		UNION ALL
+		SELECT t.id, CASE WHEN t.sighting_id IS NULL THEN $4::text ELSE $3::text END, t.sighting_id,
+			COALESCE(t.seen_at, s.date_spotted::timestamptz), t.note, loc.latitude, loc.longitude
+		FROM trail_points t
+		LEFT JOIN sighting_listing s ON s.id = t.sighting_id
+		JOIN locations loc ON loc.id = COALESCE(t.location_id, s.spotted_location)
+		WHERE t.lost_listing_id = $1 AND s.deleted_at IS NULL
+		ORDER BY 4, 1
+	`
+	rows, err := conn.Query(ctx, query, listingID, generic.TrailLastSeen, generic.TrailSighting, generic.TrailOwnerUpdate)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query trail", err))
+	}
+	defer rows.Close()
+
+	type trailPoint struct {
+		id         int
+		kind       string
+		sightingID *int
+		note       *string
+		generic.TrailPoint
+	}
+	var points []trailPoint
+	for rows.Next() {
+		var p trailPoint
+		if err := rows.Scan(&p.id, &p.kind, &p.sightingID, &p.SeenAt, &p.note, &p.Lat, &p.Lng); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to scan trail point", err))
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query trail", err))
+	}
+
+	// The search area is predicted from the true points before they are fuzzed
+	trail := make([]generic.TrailPoint, len(points))
+	for i, p := range points {
+		trail[i] = p.TrailPoint
+	}
+	var area *generic.SearchArea
+	if !isFound {
+		area = generic.PredictSearchArea(trail, time.Now())
+	}
+
+	// Every point gets the listing's privacy level, with its own seed so points in one
+	// cell don't land on the same spot. The last seen point matches the listing's.
+	level := visibleLevel(privacy, owner, viewerUUID(ctx, conn, email))
+	seed := generic.PrivacySeed(generic.ListingTypeLost, listingID)
+	if level != generic.PrivacyExact {
+		for i := range points {
+			pointSeed := seed
+			if points[i].id != 0 {
+				pointSeed += ":trail:" + strconv.Itoa(points[i].id)
+			}
+			points[i].Lat, points[i].Lng = generic.FuzzCoordinates(level, pointSeed, points[i].Lat, points[i].Lng)
+		}
+		if area != nil {
+			area.CenterLat, area.CenterLng = generic.FuzzCoordinates(level, seed+":search-area", area.CenterLat, area.CenterLng)
+		}
+	}
+
+	features := []generic.Json{}
+	if len(points) > 1 {
+		line := make([][]float64, len(points))
+		for i, p := range points {
+			line[i] = []float64{p.Lng, p.Lat}
+		}
+		features = append(features, generic.Json{
+			"type":     "Feature",
+			"geometry": generic.Json{"type": "LineString", "coordinates": line},
+			"properties": generic.Json{
+				"kind":       "trail",
+				"points":     len(points),
+				"started_at": points[0].SeenAt,
+				"ended_at":   points[len(points)-1].SeenAt,
+			},
+		})
+	}
+	for _, p := range points {
+		properties := generic.Json{"kind": p.kind, "seen_at": p.SeenAt}
+		if p.id != 0 {
+			properties["id"] = p.id
+		}
+		if p.sightingID != nil {
+			properties["sighting_id"] = *p.sightingID
+		}
+		// Anonymous callers don't see the owner's notes
+		if p.note != nil && email != "" {
+			properties["note"] = *p.note
+		}
+		features = append(features, generic.Json{
+			"type":       "Feature",
+			"geometry":   generic.Json{"type": "Point", "coordinates": []float64{p.Lng, p.Lat}},
+			"properties": properties,
+		})
+	}
+	if area != nil {
+		features = append(features, generic.Json{
+			"type":     "Feature",
+			"geometry": generic.Json{"type": "Polygon", "coordinates": [][][]float64{area.Polygon()}},
+			"properties": generic.Json{
+				"kind":          "search_area",
+				"center":        []float64{area.CenterLng, area.CenterLat},
+				"heading_deg":   area.HeadingDeg,
+				"speed_kmh":     area.SpeedKmh,
+				"elapsed_hours": area.ElapsedHours,
+				"semi_major_km": area.SemiMajorKm,
+				"semi_minor_km": area.SemiMinorKm,
+			},
+		})
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
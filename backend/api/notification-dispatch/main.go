@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackc/pgx/v5"
+)
+
+// A claimed notification is hidden from other runs for sendLease, so a run that dies
+// mid-send only delays it
+const sendLease = 5 * time.Minute
+
+type notification struct {
+	id       int64
+	userUUID string
+	kind     string
+	channel  string
+	data     map[string]any
+	attempts int
+	email    string
+	name     *string
+	prefs    generic.NotificationPreferences
+}
+
+// senders delivers notifications on each channel
+type senders struct {
+	siteURL string
+	mailer  generic.Mailer
+	sms     generic.SMSProvider
+	push    generic.PushSender
+}
+
+func main() {
+	lambda.Start(handler)
+}
+
+// handler runs every minute. It queues warnings for listings about to go stale, then
+// sends every notification that is due.
+func handler(ctx context.Context) error {
+	s := senders{siteURL: os.Getenv("PUBLIC_SITE_URL")}
+	if s.siteURL == "" {
+		return errors.New("PUBLIC_SITE_URL is not configured")
+	}
+	var err error
+	if s.mailer, err = generic.NewMailer(); err != nil {
+		return err
+	}
+	if s.sms, err = generic.NewSMSProvider(); err != nil {
+		return err
+	}
+	if s.push, err = generic.NewPushSender(); err != nil {
+		return err
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	warned, err := queueStaleWarnings(ctx, conn)
+	if err != nil {
+		generic.Logger.Error("failed to queue stale listing warnings", "error", err.Error())
+		return err
+	}
+
+	due, err := claimDue(ctx, conn)
+	if err != nil {
+		generic.Logger.Error("failed to claim notifications", "error", err.Error())
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, n := range due {
+		// SMS and push wait out the user's quiet hours; email doesn't wake anyone
+		if n.channel != generic.ChannelEmail && n.prefs.Allows(n.kind, n.channel) {
+			if until, quiet := n.prefs.QuietUntil(time.Now()); quiet {
+				// It is claimed again once its lease runs out, and checked again then
+				if err := postpone(ctx, conn, n, until); err != nil {
+					generic.Logger.Error("failed to postpone notification", "notification_id", n.id, "error", err.Error())
+					continue
+				}
+				counts["quiet"]++
+				continue
+			}
+		}
+
+		status, sendErr := send(ctx, conn, s, n)
+		if err := record(ctx, conn, n, status, sendErr); err != nil {
+			generic.Logger.Error("failed to record notification", "notification_id", n.id, "error", err.Error())
+			continue
+		}
+		counts[status]++
+	}
+
+	generic.Logger.Info("dispatched notifications", "stale_warnings", warned, "claimed", len(due),
+		"sent", counts[generic.NotificationSent], "skipped", counts[generic.NotificationSkipped],
+		"quiet", counts["quiet"], "retrying", counts[generic.NotificationPending], "failed", counts[generic.NotificationFailed])
+	return nil
+}
+
+// queueStaleWarnings warns the owner of each open listing that goes stale within
+// StaleWarning, once per listing. Listings that are already stale aren't warned.
+func queueStaleWarnings(ctx context.Context, conn *pgx.Conn) (int64, error) {
+	now := time.Now()
+	tag, err := conn.Exec(ctx, `
+		WITH stale AS (
+			UPDATE lost_pet_listing SET stale_warned_at = $1
+			WHERE NOT is_found AND deleted_at IS NULL AND stale_warned_at IS NULL
+				AND created_at <= $2 AND created_at > $3
+			RETURNING id, listing_owner, pet_name, created_at
+		)
+		INSERT INTO notifications (user_uuid, kind, channel, data, next_attempt_at, created_at)
+		SELECT listing_owner, $4, c.channel, jsonb_build_object(
+			'listing_id', id,
+			'pet_name', pet_name,
+			'stale_on', to_char(created_at + make_interval(secs => $5), 'FMMonth FMDD, YYYY')
+		), $1, $1
+		FROM stale
+		CROSS JOIN LATERAL `+generic.RouteChannels(generic.NotifyListingStale, "listing_owner")+` AS c(channel)
+	`, now, now.Add(-(generic.ListingStaleAfter - generic.StaleWarning)), now.Add(-generic.ListingStaleAfter),
+		generic.NotifyListingStale, generic.ListingStaleAfter.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// claimDue leases the pending notifications that are due, oldest first, with the
+// recipient's addresses and preferences
+func claimDue(ctx context.Context, conn *pgx.Conn) ([]notification, error) {
+	now := time.Now()
+	rows, err := conn.Query(ctx, `
+		UPDATE notifications n SET next_attempt_at = $2
+		FROM (
+			SELECT n.id, u.email, u.name,
+				COALESCE(p.email_enabled, true) AS email_enabled,
+				COALESCE(p.sighting_nearby, true) AS sighting_nearby,
+				COALESCE(p.listing_stale, true) AS listing_stale,
+				COALESCE(p.messages, true) AS messages,
+				COALESCE(p.area_alerts, true) AS area_alerts,
+				COALESCE(p.urgent_channels, ARRAY['email']) AS urgent_channels,
+				COALESCE(p.digest_channels, ARRAY['email']) AS digest_channels,
+				p.quiet_hours_start, p.quiet_hours_end,
+				COALESCE(p.timezone, 'UTC') AS timezone, p.phone_number,
+				COALESCE(p.digest_frequency, 'off') AS digest_frequency,
+				p.digest_city_id, p.digest_latitude, p.digest_longitude, p.digest_radius_km
+			FROM notifications n
+			JOIN users u ON u.user_uuid = n.user_uuid
+			LEFT JOIN notification_preferences p ON p.user_uuid = n.user_uuid
+			WHERE n.status = 'pending' AND n.next_attempt_at <= $1
+			ORDER BY n.next_attempt_at
+			LIMIT $3
+			FOR UPDATE OF n SKIP LOCKED
+		) due
+		WHERE n.id = due.id
+		RETURNING n.id, n.user_uuid, n.kind, n.channel, n.data, n.attempts, due.email, due.name,
+			due.email_enabled, due.sighting_nearby, due.listing_stale, due.messages, due.area_alerts,
+			due.urgent_channels, due.digest_channels, due.quiet_hours_start, due.quiet_hours_end, due.timezone, due.phone_number,
+			due.digest_frequency, due.digest_city_id, due.digest_latitude, due.digest_longitude, due.digest_radius_km
+	`, now, now.Add(sendLease), generic.NotificationBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (notification, error) {
+		var n notification
+		var data []byte
+		targets := []any{&n.id, &n.userUUID, &n.kind, &n.channel, &data, &n.attempts, &n.email, &n.name}
+		if err := row.Scan(append(targets, generic.ScanPreferences(&n.prefs)...)...); err != nil {
+			return n, err
+		}
+		return n, json.Unmarshal(data, &n.data)
+	})
+}
+
+// send renders and sends one notification and returns its new status. Preferences are
+// checked here rather than when it was queued, so turning a kind or channel off stops
+// queued notifications too.
+func send(ctx context.Context, conn *pgx.Conn, s senders, n notification) (string, error) {
+	if !n.prefs.Allows(n.kind, n.channel) {
+		return generic.NotificationSkipped, nil
+	}
+
+	name := "there"
+	if n.name != nil && *n.name != "" {
+		name = *n.name
+	}
+	data := generic.TemplateData{
+		Name:           name,
+		SiteURL:        s.siteURL,
+		UnsubscribeURL: generic.UnsubscribeURL(s.siteURL, n.userUUID, n.kind),
+		Data:           n.data,
+	}
+
+	var err error
+	switch n.channel {
+	case generic.ChannelEmail:
+		var msg generic.EmailMessage
+		if msg, err = generic.RenderNotification(n.kind, data); err != nil {
+			// Retrying won't fix a template or an unknown kind
+			return generic.NotificationFailed, err
+		}
+		msg.To = n.email
+		err = s.mailer.Send(ctx, msg)
+	case generic.ChannelSMS:
+		if n.prefs.PhoneNumber == nil {
+			// The number was removed, or never verified, after this was queued
+			return generic.NotificationSkipped, nil
+		}
+		var msg generic.ShortMessage
+		if msg, err = generic.RenderShort(n.kind, data); err != nil {
+			return generic.NotificationFailed, err
+		}
+		err = s.sms.SendSMS(ctx, *n.prefs.PhoneNumber, msg.SMS())
+	case generic.ChannelPush:
+		var msg generic.ShortMessage
+		if msg, err = generic.RenderShort(n.kind, data); err != nil {
+			return generic.NotificationFailed, err
+		}
+		var delivered bool
+		if delivered, err = sendPush(ctx, conn, s.push, n, msg); err == nil && !delivered {
+			return generic.NotificationSkipped, nil
+		}
+	default:
+		return generic.NotificationFailed, errors.New("unknown channel " + n.channel)
+	}
+
+	if err != nil {
+		if n.attempts+1 >= generic.NotificationMaxAttempts {
+			return generic.NotificationFailed, err
+		}
+		return generic.NotificationPending, err
+	}
+	return generic.NotificationSent, nil
+}
+
+// sendPush pushes msg to every browser the user subscribed. Subscriptions the push
+// service reports gone are deleted. It reports whether any browser got the push, and
+// fails only if none did and at least one may yet.
+func sendPush(ctx context.Context, conn *pgx.Conn, pusher generic.PushSender, n notification, msg generic.ShortMessage) (bool, error) {
+	rows, err := conn.Query(ctx, `SELECT id, endpoint, p256dh, auth FROM push_subscriptions WHERE user_uuid = $1`, n.userUUID)
+	if err != nil {
+		return false, err
+	}
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (generic.PushSubscription, error) {
+		var sub generic.PushSubscription
+		err := row.Scan(&sub.ID, &sub.Endpoint, &sub.P256dh, &sub.Auth)
+		return sub, err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"kind":  n.kind,
+		"title": msg.Title,
+		"body":  msg.Body,
+		"url":   msg.URL,
+	})
+	if err != nil {
+		return false, err
+	}
+	urgent := generic.NotificationUrgency(n.kind) == generic.UrgencyUrgent
+
+	var delivered []int
+	var lastErr error
+	for _, sub := range subs {
+		err := pusher.Push(ctx, sub, payload, urgent)
+		switch {
+		case errors.Is(err, generic.ErrPushGone):
+			if _, err := conn.Exec(ctx, `DELETE FROM push_subscriptions WHERE id = $1`, sub.ID); err != nil {
+				return false, err
+			}
+		case err != nil:
+			lastErr = err
+		default:
+			delivered = append(delivered, sub.ID)
+		}
+	}
+
+	if len(delivered) > 0 {
+		_, err := conn.Exec(ctx, `UPDATE push_subscriptions SET last_used_at = $2 WHERE id = ANY($1)`, delivered, time.Now())
+		return true, err
+	}
+	return false, lastErr
+}
+
+// postpone puts a notification back until the user's quiet hours end, without
+// counting an attempt
+func postpone(ctx context.Context, conn *pgx.Conn, n notification, until time.Time) error {
+	_, err := conn.Exec(ctx, `UPDATE notifications SET next_attempt_at = $2 WHERE id = $1`, n.id, until)
+	return err
+}
+
+// record stores the outcome of a send. A notification left pending is retried with
+// the same exponential backoff as webhooks.
+func record(ctx context.Context, conn *pgx.Conn, n notification, status string, sendErr error) error {
+	now := time.Now()
+	attempts := n.attempts
+	if status != generic.NotificationSkipped {
+		attempts++
+	}
+	var sentAt *time.Time
+	if status == generic.NotificationSent {
+		sentAt = &now
+	}
+
+	var message *string
+	if sendErr != nil {
+		text := generic.StoredError(sendErr.Error())
+		message = &text
+		generic.Logger.Warn("failed to send notification", "notification_id", n.id, "kind", n.kind, "error", text)
+	}
+
+	_, err := conn.Exec(ctx, `
+		UPDATE notifications
+		SET status = $2, attempts = $3, last_error = $4, sent_at = $5, next_attempt_at = $6
+		WHERE id = $1
+	`, n.id, status, attempts, message, sentAt, now.Add(generic.WebhookBackoff(attempts)))
+	return err
+}
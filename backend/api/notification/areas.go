@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// AlertAreaRequest is a circle (center and radiusKm) or a polygon, not both
+type AlertAreaRequest struct {
+	Name     string           `json:"name"`
+	Center   *generic.LatLng  `json:"center,omitempty"`
+	RadiusKm *float64         `json:"radiusKm,omitempty"`
+	Polygon  []generic.LatLng `json:"polygon,omitempty"`
+}
+
+func (r *AlertAreaRequest) Validate() generic.ValidationErrors {
+	rules := []generic.Rule{
+		generic.Required("name", r.Name),
+		generic.MaxLength("name", r.Name, 100),
+		generic.ValidPolygon("polygon", r.Polygon),
+	}
+	circle := r.Center != nil || r.RadiusKm != nil
+	switch {
+	case circle && len(r.Polygon) > 0:
+		rules = append(rules, func() *generic.FieldError {
+			return &generic.FieldError{Field: "polygon", Code: generic.CodeInvalid, Message: "an area is a center and radius or a polygon, not both"}
+		})
+	case circle:
+		rules = append(rules,
+			generic.RequiredValue("center", r.Center != nil),
+			generic.RequiredValue("radiusKm", r.RadiusKm != nil),
+		)
+	default:
+		rules = append(rules, func() *generic.FieldError {
+			return &generic.FieldError{Field: "polygon", Code: generic.CodeRequired, Message: "give a center and radiusKm, or a polygon"}
+		})
+	}
+	if r.Center != nil {
+		rules = append(rules,
+			generic.InRange("center.lat", r.Center.Lat, -90, 90),
+			generic.InRange("center.lng", r.Center.Lng, -180, 180),
+		)
+	}
+	if r.RadiusKm != nil {
+		rules = append(rules, generic.InRange("radiusKm", *r.RadiusKm, generic.MinAreaRadiusKm, generic.MaxAreaRadiusKm))
+	}
+	return generic.Validate(rules...)
+}
+
+// boundary is the polygon to store, or nil for a circle
+func (r *AlertAreaRequest) boundary() *string {
+	if len(r.Polygon) == 0 {
+		return nil
+	}
+	literal := generic.PolygonLiteral(r.Polygon)
+	return &literal
+}
+
+type AlertAreaResponse struct {
+	ID        int              `json:"id"`
+	Name      string           `json:"name"`
+	Center    *generic.LatLng  `json:"center,omitempty"`
+	RadiusKm  *float64         `json:"radius_km,omitempty"`
+	Polygon   []generic.LatLng `json:"polygon,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+const alertAreaColumns = `id, name, latitude, longitude, radius_km, boundary, created_at, updated_at`
+
+func scanAlertArea(row pgx.CollectableRow) (AlertAreaResponse, error) {
+	var area AlertAreaResponse
+	var lat, lng *float64
+	var boundary pgtype.Polygon
+	if err := row.Scan(&area.ID, &area.Name, &lat, &lng, &area.RadiusKm, &boundary, &area.CreatedAt, &area.UpdatedAt); err != nil {
+		return area, err
+	}
+	if lat != nil && lng != nil {
+		area.Center = &generic.LatLng{Lat: *lat, Lng: *lng}
+	}
+	for _, vertex := range boundary.P {
+		area.Polygon = append(area.Polygon, generic.LatLng{Lat: vertex.Y, Lng: vertex.X})
+	}
+	return area, nil
+}
+
+// routeAreas dispatches the /notification/areas routes
+func routeAreas(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	areaID := request.PathParameters["areaId"]
+	switch {
+	case request.HTTPMethod == "GET" && areaID == "":
+		return handleListAreas(ctx, conn, request, userUUID)
+	case request.HTTPMethod == "POST" && areaID == "":
+		return handleCreateArea(ctx, conn, request, userUUID)
+	case request.HTTPMethod == "PUT" && areaID != "":
+		return handleUpdateArea(ctx, conn, request, userUUID, areaID)
+	case request.HTTPMethod == "DELETE" && areaID != "":
+		return handleDeleteArea(ctx, conn, request, userUUID, areaID)
+	}
+	return generic.MethodNotAllowed(request)
+}
+
+// GET /notification/areas - the areas the user gets new lost listing alerts for
+func handleListAreas(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID string) (events.APIGatewayProxyResponse, error) {
+	rows, err := conn.Query(ctx, `SELECT `+alertAreaColumns+` FROM alert_areas WHERE user_uuid = $1 ORDER BY id`, userUUID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query alert areas", err))
+	}
+	areas, err := pgx.CollectRows(rows, scanAlertArea)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query alert areas", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data":  areas,
+		"count": len(areas),
+	})
+}
+
+// POST /notification/areas - registers an area. Up to MaxAlertAreas per user.
+func handleCreateArea(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID string) (events.APIGatewayProxyResponse, error) {
+	var req AlertAreaRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if errs := req.Validate(); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	var lat, lng *float64
+	if req.Center != nil {
+		lat, lng = &req.Center.Lat, &req.Center.Lng
+	}
+
+	now := time.Now()
+	rows, err := conn.Query(ctx, `
+		INSERT INTO alert_areas (user_uuid, name, latitude, longitude, radius_km, boundary, created_at, updated_at)
+		SELECT $1, $2, $3, $4, $5, $6::polygon, $7, $7
+		WHERE (SELECT count(*) FROM alert_areas WHERE user_uuid = $1) < $8
+		RETURNING `+alertAreaColumns, userUUID, req.Name, lat, lng, req.RadiusKm, req.boundary(), now, generic.MaxAlertAreas)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create alert area", err))
+	}
+	areas, err := pgx.CollectRows(rows, scanAlertArea)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create alert area", err))
+	}
+	if len(areas) == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "you can have up to " + strconv.Itoa(generic.MaxAlertAreas) + " alert areas; delete one first"})
+	}
+
+	return generic.Response(http.StatusCreated, generic.Json{"data": areas[0]})
+}
+
+// PUT /notification/areas/{areaId} - replaces an area's name and shape
+func handleUpdateArea(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID, areaID string) (events.APIGatewayProxyResponse, error) {
+	var req AlertAreaRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if errs := req.Validate(); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	var lat, lng *float64
+	if req.Center != nil {
+		lat, lng = &req.Center.Lat, &req.Center.Lng
+	}
+
+	rows, err := conn.Query(ctx, `
+		UPDATE alert_areas
+		SET name = $3, latitude = $4, longitude = $5, radius_km = $6, boundary = $7::polygon, updated_at = $8
+		WHERE id = $1 AND user_uuid = $2
+		RETURNING `+alertAreaColumns, areaID, userUUID, req.Name, lat, lng, req.RadiusKm, req.boundary(), time.Now())
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update alert area", err))
+	}
+	areas, err := pgx.CollectRows(rows, scanAlertArea)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update alert area", err))
+	}
+	if len(areas) == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "alert area not found"})
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{"data": areas[0]})
+}
+
+// DELETE /notification/areas/{areaId}
+func handleDeleteArea(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID, areaID string) (events.APIGatewayProxyResponse, error) {
+	tag, err := conn.Exec(ctx, `DELETE FROM alert_areas WHERE id = $1 AND user_uuid = $2`, areaID, userUUID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to delete alert area", err))
+	}
+	if tag.RowsAffected() == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "alert area not found"})
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{"message": "Alert area deleted"})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/jackc/pgx/v5"
+)
+
+// DigestCityRequest picks the city a listing digest covers. An empty city stops the
+// digest covering a city.
+type DigestCityRequest struct {
+	City            string `json:"city"`
+	ProvinceOrState string `json:"provinceOrState"`
+	Country         string `json:"country"`
+}
+
+type DigestCityResponse struct {
+	City            string `json:"city"`
+	ProvinceOrState string `json:"province_or_state"`
+	Country         string `json:"country"`
+}
+
+// applyDigest merges the digest fields of req into prefs, creating the city if no
+// listing has been posted in it yet
+func applyDigest(ctx context.Context, conn *pgx.Conn, req PreferencesRequest, prefs *generic.NotificationPreferences) error {
+	if req.DigestFrequency != nil {
+		prefs.DigestFrequency = *req.DigestFrequency
+	}
+
+	if req.DigestCity != nil {
+		prefs.DigestCityID = nil
+
+		geocoder, err := generic.NewGeocoder()
+		if err != nil {
+			return generic.Internal("geocoding is not configured", err)
+		}
+		place, err := generic.ResolveCity(ctx, geocoder, generic.Place{
+			City:            req.DigestCity.City,
+			ProvinceOrState: req.DigestCity.ProvinceOrState,
+			Country:         req.DigestCity.Country,
+		})
+		if err != nil {
+			var validationErr *generic.ValidationError
+			if errors.As(err, &validationErr) {
+				validationErr.Field = "digestCity." + validationErr.Field
+			}
+			return err
+		}
+
+		if place.City != "" {
+			cityID, err := generic.GetOrCreateCity(ctx, conn, place)
+			if err != nil {
+				return generic.Internal("failed to resolve digest city", err)
+			}
+			prefs.DigestCityID = &cityID
+		}
+	}
+
+	if req.DigestCoords != nil {
+		prefs.DigestLatitude = &req.DigestCoords.Lat
+		prefs.DigestLongitude = &req.DigestCoords.Lng
+		if prefs.DigestRadiusKm == nil {
+			radius := float64(generic.DefaultDigestRadiusKm)
+			prefs.DigestRadiusKm = &radius
+		}
+	}
+	if req.DigestRadiusKm != nil {
+		prefs.DigestRadiusKm = req.DigestRadiusKm
+		if *req.DigestRadiusKm == 0 {
+			prefs.DigestLatitude, prefs.DigestLongitude, prefs.DigestRadiusKm = nil, nil, nil
+		}
+	}
+	return nil
+}
+
+// validateDigest checks the merged digest settings: a radius needs a point, and a
+// digest needs a city or a point to cover
+func validateDigest(prefs generic.NotificationPreferences) generic.ValidationErrors {
+	var errs generic.ValidationErrors
+	if prefs.DigestRadiusKm != nil && prefs.DigestLatitude == nil {
+		errs = append(errs, generic.FieldError{Field: "digestCoords", Code: generic.CodeRequired, Message: "a digest radius needs a point to be around"})
+	}
+	if prefs.DigestFrequency != generic.DigestOff && prefs.DigestCityID == nil && prefs.DigestRadiusKm == nil {
+		errs = append(errs, generic.FieldError{Field: "digestCity", Code: generic.CodeRequired, Message: "choose a city or a point for your digest to cover"})
+	}
+	return errs
+}
+
+// digestCity returns the city a user's digest covers, if any
+func digestCity(ctx context.Context, conn *pgx.Conn, prefs generic.NotificationPreferences) (*DigestCityResponse, error) {
+	if prefs.DigestCityID == nil {
+		return nil, nil
+	}
+	var city DigestCityResponse
+	err := conn.QueryRow(ctx, `SELECT city_name, province_or_state, country FROM cities WHERE id = $1`, *prefs.DigestCityID).
+		Scan(&city.City, &city.ProvinceOrState, &city.Country)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, generic.Internal("failed to query digest city", err)
+	}
+	return &city, nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// PreferencesRequest changes only the fields it sets. An empty quietHoursStart or
+// quietHoursEnd turns quiet hours off, and a digestRadiusKm of 0 stops the digest
+// covering an area around a point.
+type PreferencesRequest struct {
+	EmailEnabled    *bool     `json:"emailEnabled,omitempty"`
+	SightingNearby  *bool     `json:"sightingNearby,omitempty"`
+	ListingStale    *bool     `json:"listingStale,omitempty"`
+	Messages        *bool     `json:"messages,omitempty"`
+	AreaAlerts      *bool     `json:"areaAlerts,omitempty"`
+	UrgentChannels  *[]string `json:"urgentChannels,omitempty"`
+	DigestChannels  *[]string `json:"digestChannels,omitempty"`
+	QuietHoursStart *string   `json:"quietHoursStart,omitempty"`
+	QuietHoursEnd   *string   `json:"quietHoursEnd,omitempty"`
+	Timezone        *string   `json:"timezone,omitempty"`
+
+	DigestFrequency *string            `json:"digestFrequency,omitempty"`
+	DigestCity      *DigestCityRequest `json:"digestCity,omitempty"`
+	DigestCoords    *struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"digestCoords,omitempty"`
+	DigestRadiusKm *float64 `json:"digestRadiusKm,omitempty"`
+}
+
+func (r *PreferencesRequest) Validate() generic.ValidationErrors {
+	var rules []generic.Rule
+	channelRules := func(field string, channels *[]string) {
+		if channels == nil {
+			return
+		}
+		for i, channel := range *channels {
+			rules = append(rules, generic.OneOf(field+"["+strconv.Itoa(i)+"]", channel, generic.NotificationChannels))
+		}
+	}
+	channelRules("urgentChannels", r.UrgentChannels)
+	channelRules("digestChannels", r.DigestChannels)
+	if r.QuietHoursStart != nil && *r.QuietHoursStart != "" {
+		rules = append(rules, generic.ValidClock("quietHoursStart", r.QuietHoursStart))
+	}
+	if r.QuietHoursEnd != nil && *r.QuietHoursEnd != "" {
+		rules = append(rules, generic.ValidClock("quietHoursEnd", r.QuietHoursEnd))
+	}
+	if r.Timezone != nil {
+		rules = append(rules,
+			generic.Required("timezone", *r.Timezone),
+			generic.ValidTimezone("timezone", *r.Timezone),
+		)
+	}
+	if r.DigestFrequency != nil {
+		rules = append(rules,
+			generic.Required("digestFrequency", *r.DigestFrequency),
+			generic.OneOf("digestFrequency", *r.DigestFrequency, generic.DigestFrequencies),
+		)
+	}
+	if r.DigestCity != nil && r.DigestCity.City != "" {
+		rules = append(rules,
+			generic.MaxLength("digestCity.city", r.DigestCity.City, 100),
+			generic.Required("digestCity.provinceOrState", r.DigestCity.ProvinceOrState),
+			generic.Required("digestCity.country", r.DigestCity.Country),
+		)
+	}
+	if r.DigestCoords != nil {
+		rules = append(rules,
+			generic.InRange("digestCoords.lat", r.DigestCoords.Lat, -90, 90),
+			generic.InRange("digestCoords.lng", r.DigestCoords.Lng, -180, 180),
+		)
+	}
+	// A radius of 0 stops covering a point
+	if r.DigestRadiusKm != nil && *r.DigestRadiusKm != 0 {
+		rules = append(rules, generic.InRange("digestRadiusKm", *r.DigestRadiusKm, generic.MinAreaRadiusKm, generic.MaxDigestRadiusKm))
+	}
+	return generic.Validate(rules...)
+}
+
+type UnsubscribeRequest struct {
+	Token string `json:"token"`
+}
+
+// Helper functions
+func extractUserFromToken(request events.APIGatewayProxyRequest) (string, string, error) {
+	authHeader := request.Headers["Authorization"]
+	if authHeader == "" {
+		authHeader = request.Headers["authorization"]
+	}
+	if authHeader == "" {
+		return "", "", &generic.AuthError{Message: "missing authorization header"}
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	token = strings.TrimSpace(token)
+
+	tokenClaims, err := generic.TokenClaims(token)
+	if err != nil {
+		return "", "", &generic.AuthError{Message: "invalid or expired token", Err: err}
+	}
+
+	email, ok := tokenClaims["email"].(string)
+	if !ok || email == "" {
+		return "", "", &generic.AuthError{Message: "email not found in token"}
+	}
+
+	return token, email, nil
+}
+
+func getUserUUID(ctx context.Context, conn *pgx.Conn, email string) (string, error) {
+	var userUUID string
+	queryUser := `SELECT user_uuid FROM users WHERE email = $1`
+	err := conn.QueryRow(ctx, queryUser, email).Scan(&userUUID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", &generic.NotFoundError{Message: "user not found"}
+		}
+		return "", generic.Internal("failed to query user", err)
+	}
+	generic.SetUser(ctx, userUUID)
+	return userUUID, nil
+}
+
+func main() {
+	generic.Start("notification", handler)
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod == "OPTIONS" {
+		return generic.Response(http.StatusOK, generic.Json{})
+	}
+
+	if strings.HasSuffix(request.Resource, "/unsubscribe") {
+		if request.HTTPMethod != "POST" {
+			return generic.MethodNotAllowed(request)
+		}
+		return handleUnsubscribe(ctx, request)
+	}
+	if strings.Contains(request.Resource, "/phone") {
+		return routePhone(ctx, request)
+	}
+	if strings.Contains(request.Resource, "/push-") {
+		return routePush(ctx, request)
+	}
+	if strings.Contains(request.Resource, "/areas") {
+		return routeAreas(ctx, request)
+	}
+
+	switch request.HTTPMethod {
+	case "GET":
+		return handleGetPreferences(ctx, request)
+	case "PUT", "PATCH":
+		return handleUpdatePreferences(ctx, request)
+	default:
+		return generic.MethodNotAllowed(request)
+	}
+}
+
+// GET /notification/preferences
+func handleGetPreferences(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	prefs, err := generic.LoadPreferences(ctx, conn, userUUID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query notification preferences", err))
+	}
+	city, err := digestCity(ctx, conn, prefs)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data":        prefs,
+		"digest_city": city,
+	})
+}
+
+// PUT/PATCH /notification/preferences
+func handleUpdatePreferences(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var req PreferencesRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	prefs, err := generic.LoadPreferences(ctx, conn, userUUID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query notification preferences", err))
+	}
+
+	for field, value := range map[*bool]*bool{
+		&prefs.EmailEnabled:   req.EmailEnabled,
+		&prefs.SightingNearby: req.SightingNearby,
+		&prefs.ListingStale:   req.ListingStale,
+		&prefs.Messages:       req.Messages,
+		&prefs.AreaAlerts:     req.AreaAlerts,
+	} {
+		if value != nil {
+			*field = *value
+		}
+	}
+	if req.UrgentChannels != nil {
+		prefs.UrgentChannels = uniqueChannels(*req.UrgentChannels)
+	}
+	if req.DigestChannels != nil {
+		prefs.DigestChannels = uniqueChannels(*req.DigestChannels)
+	}
+	for field, value := range map[**string]*string{
+		&prefs.QuietHoursStart: req.QuietHoursStart,
+		&prefs.QuietHoursEnd:   req.QuietHoursEnd,
+	} {
+		if value != nil {
+			*field = value
+			if *value == "" {
+				*field = nil
+			}
+		}
+	}
+	if req.Timezone != nil {
+		prefs.Timezone = *req.Timezone
+	}
+	if err := applyDigest(ctx, conn, req, &prefs); err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	// Checked against the merged preferences, since a PATCH may set only one side
+	var errs generic.ValidationErrors
+	if (prefs.QuietHoursStart == nil) != (prefs.QuietHoursEnd == nil) {
+		errs = append(errs, generic.FieldError{Field: "quietHoursEnd", Code: generic.CodeRequired, Message: "quiet hours need both a start and an end"})
+	}
+	if prefs.PhoneNumber == nil {
+		if req.UrgentChannels != nil && slices.Contains(prefs.UrgentChannels, generic.ChannelSMS) {
+			errs = append(errs, generic.FieldError{Field: "urgentChannels", Code: generic.CodeInvalid, Message: "verify a phone number before choosing sms"})
+		}
+		if req.DigestChannels != nil && slices.Contains(prefs.DigestChannels, generic.ChannelSMS) {
+			errs = append(errs, generic.FieldError{Field: "digestChannels", Code: generic.CodeInvalid, Message: "verify a phone number before choosing sms"})
+		}
+	}
+	errs = append(errs, validateDigest(prefs)...)
+	if len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	if err := generic.SavePreferences(ctx, conn, userUUID, prefs); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to save notification preferences", err))
+	}
+	city, err := digestCity(ctx, conn, prefs)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"message":     "Notification preferences updated",
+		"data":        prefs,
+		"digest_city": city,
+	})
+}
+
+// uniqueChannels drops repeated channels, keeping the first of each
+func uniqueChannels(channels []string) []string {
+	unique := []string{}
+	for _, channel := range channels {
+		if !slices.Contains(unique, channel) {
+			unique = append(unique, channel)
+		}
+	}
+	return unique
+}
+
+// POST /notification/unsubscribe - the one-click unsubscribe link in every email. The
+// signed token identifies the user, so no sign-in is needed.
+func handleUnsubscribe(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var req UnsubscribeRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+
+	if errs := generic.Validate(generic.Required("token", req.Token)); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	userUUID, kind, err := generic.ParseUnsubscribeToken(req.Token)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	if err := generic.CheckPublicRateLimit(ctx, conn, request); err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	generic.SetUser(ctx, userUUID)
+	if err := generic.Unsubscribe(ctx, conn, userUUID, kind); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to unsubscribe", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"message": "Unsubscribed",
+		"kind":    kind,
+	})
+}
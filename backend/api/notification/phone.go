@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// Verification codes a user can request per phoneCodeWindow, so the endpoint can't be
+// used to send texts to someone else's number
+const (
+	phoneCodeLimit  = 5
+	phoneCodeWindow = time.Hour
+)
+
+type PhoneRequest struct {
+	PhoneNumber string `json:"phoneNumber"`
+}
+
+type VerifyPhoneRequest struct {
+	Code string `json:"code"`
+}
+
+// routePhone dispatches the /notification/phone routes
+func routePhone(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	verify := strings.HasSuffix(request.Resource, "/verify")
+	switch {
+	case verify && request.HTTPMethod == "POST":
+		return handleVerifyPhone(ctx, conn, request, userUUID)
+	case !verify && request.HTTPMethod == "POST":
+		return handleSendPhoneCode(ctx, conn, request, userUUID)
+	case !verify && request.HTTPMethod == "DELETE":
+		return handleRemovePhone(ctx, conn, request, userUUID)
+	default:
+		return generic.MethodNotAllowed(request)
+	}
+}
+
+// POST /notification/phone - texts a verification code to a number. The number is only
+// used for SMS once the code is confirmed; until then any verified number stays in place.
+func handleSendPhoneCode(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID string) (events.APIGatewayProxyResponse, error) {
+	var req PhoneRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+
+	if errs := generic.Validate(
+		generic.Required("phoneNumber", req.PhoneNumber),
+		generic.PhoneNumber("phoneNumber", req.PhoneNumber),
+	); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	if err := generic.CheckRateLimit(ctx, conn, "phone:"+userUUID, phoneCodeLimit, phoneCodeWindow); err != nil {
+		var rateLimitErr *generic.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			rateLimitErr.Message = "too many verification codes requested, retry in " + strconv.Itoa(rateLimitErr.RetryAfter) + " seconds"
+		}
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	sms, err := generic.NewSMSProvider()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("SMS is not configured", err))
+	}
+
+	code, err := generic.NewPhoneCode()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to generate verification code", err))
+	}
+
+	now := time.Now()
+	_, err = conn.Exec(ctx, `
+		INSERT INTO notification_preferences (
+			user_uuid, pending_phone, phone_code_hash, phone_code_expires_at, phone_code_attempts, updated_at
+		) VALUES ($1, $2, $3, $4, 0, $5)
+		ON CONFLICT (user_uuid) DO UPDATE
+		SET pending_phone = EXCLUDED.pending_phone, phone_code_hash = EXCLUDED.phone_code_hash,
+			phone_code_expires_at = EXCLUDED.phone_code_expires_at, phone_code_attempts = 0,
+			updated_at = EXCLUDED.updated_at
+	`, userUUID, req.PhoneNumber, generic.PhoneCodeHash(userUUID, req.PhoneNumber, code), now.Add(generic.PhoneCodeTTL), now)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to save verification code", err))
+	}
+
+	body := "Your FindMyPet verification code is " + code + ". It expires in " +
+		strconv.Itoa(int(generic.PhoneCodeTTL.Minutes())) + " minutes."
+	if err := sms.SendSMS(ctx, req.PhoneNumber, body); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to send verification code", err))
+	}
+
+	return generic.Response(http.StatusAccepted, generic.Json{
+		"message":    "Verification code sent",
+		"expires_at": now.Add(generic.PhoneCodeTTL),
+	})
+}
+
+// POST /notification/phone/verify - confirms the code sent to the pending number and
+// makes it the number SMS notifications go to
+func handleVerifyPhone(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID string) (events.APIGatewayProxyResponse, error) {
+	var req VerifyPhoneRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	req.Code = strings.TrimSpace(req.Code)
+
+	if errs := generic.Validate(generic.Required("code", req.Code)); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	// Count the guess before checking it, so concurrent guesses can't get past the limit
+	var pending, hash string
+	var expiresAt time.Time
+	var attempts int
+	err := conn.QueryRow(ctx, `
+		UPDATE notification_preferences SET phone_code_attempts = phone_code_attempts + 1
+		WHERE user_uuid = $1 AND pending_phone IS NOT NULL
+		RETURNING pending_phone, phone_code_hash, phone_code_expires_at, phone_code_attempts
+	`, userUUID).Scan(&pending, &hash, &expiresAt, &attempts)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "no phone number is waiting to be verified", Field: "code"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to check verification code", err))
+	}
+
+	if attempts > generic.PhoneCodeMaxAttempts || time.Now().After(expiresAt) {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "verification code has expired, request a new one", Field: "code"})
+	}
+	if !hmac.Equal([]byte(hash), []byte(generic.PhoneCodeHash(userUUID, pending, req.Code))) {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "incorrect verification code", Field: "code"})
+	}
+
+	_, err = conn.Exec(ctx, `
+		UPDATE notification_preferences
+		SET phone_number = pending_phone, phone_verified_at = $2, pending_phone = NULL,
+			phone_code_hash = NULL, phone_code_expires_at = NULL, phone_code_attempts = 0, updated_at = $2
+		WHERE user_uuid = $1
+	`, userUUID, time.Now())
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to save phone number", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"message":      "Phone number verified",
+		"phone_number": pending,
+	})
+}
+
+// DELETE /notification/phone - removes the user's number and stops SMS notifications
+func handleRemovePhone(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID string) (events.APIGatewayProxyResponse, error) {
+	_, err := conn.Exec(ctx, `
+		UPDATE notification_preferences
+		SET phone_number = NULL, phone_verified_at = NULL, pending_phone = NULL,
+			phone_code_hash = NULL, phone_code_expires_at = NULL, phone_code_attempts = 0,
+			urgent_channels = array_remove(urgent_channels, $2),
+			digest_channels = array_remove(digest_channels, $2),
+			updated_at = $3
+		WHERE user_uuid = $1
+	`, userUUID, generic.ChannelSMS, time.Now())
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to remove phone number", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"message": "Phone number removed",
+	})
+}
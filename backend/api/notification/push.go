@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// Browsers a user can subscribe at once; the oldest is replaced past this
+const maxPushSubscriptions = 10
+
+// PushSubscriptionRequest is the browser's PushSubscription.toJSON()
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+func (r *PushSubscriptionRequest) Validate() generic.ValidationErrors {
+	return generic.Validate(
+		generic.Required("endpoint", r.Endpoint),
+		generic.WebhookURL("endpoint", r.Endpoint),
+		generic.MaxLength("endpoint", r.Endpoint, 2000),
+		generic.Required("keys.p256dh", r.Keys.P256dh),
+		pushKey("keys.p256dh", r.Keys.P256dh, 65),
+		generic.Required("keys.auth", r.Keys.Auth),
+		pushKey("keys.auth", r.Keys.Auth, 16),
+	)
+}
+
+// pushKey checks a base64url subscription key of size bytes
+func pushKey(field, value string, size int) generic.Rule {
+	return func() *generic.FieldError {
+		if value == "" {
+			return nil
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil || len(raw) != size {
+			return &generic.FieldError{Field: field, Code: generic.CodeInvalid, Message: "must be a base64url key from the browser's push subscription"}
+		}
+		return nil
+	}
+}
+
+type PushSubscriptionResponse struct {
+	ID         int        `json:"id"`
+	Endpoint   string     `json:"endpoint"`
+	UserAgent  *string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// routePush dispatches the /notification/push-key and /notification/push-subscriptions routes
+func routePush(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if strings.HasSuffix(request.Resource, "/push-key") {
+		if request.HTTPMethod != "GET" {
+			return generic.MethodNotAllowed(request)
+		}
+		return handleGetPushKey(ctx, request)
+	}
+
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	subscriptionID := request.PathParameters["subscriptionId"]
+	switch {
+	case request.HTTPMethod == "GET" && subscriptionID == "":
+		return handleListPushSubscriptions(ctx, conn, request, userUUID)
+	case request.HTTPMethod == "POST" && subscriptionID == "":
+		return handleCreatePushSubscription(ctx, conn, request, userUUID)
+	case request.HTTPMethod == "DELETE" && subscriptionID != "":
+		return handleDeletePushSubscription(ctx, conn, request, userUUID, subscriptionID)
+	}
+	return generic.MethodNotAllowed(request)
+}
+
+// GET /notification/push-key - the VAPID public key browsers subscribe with
+// (applicationServerKey in pushManager.subscribe)
+func handleGetPushKey(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	key := os.Getenv("VAPID_PUBLIC_KEY")
+	if key == "" {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "push notifications are not configured"})
+	}
+	return generic.Response(http.StatusOK, generic.Json{"public_key": key})
+}
+
+// GET /notification/push-subscriptions - the browsers the user gets push notifications in
+func handleListPushSubscriptions(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID string) (events.APIGatewayProxyResponse, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT id, endpoint, user_agent, created_at, last_used_at
+		FROM push_subscriptions
+		WHERE user_uuid = $1
+		ORDER BY id
+	`, userUUID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query push subscriptions", err))
+	}
+	subscriptions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (PushSubscriptionResponse, error) {
+		var sub PushSubscriptionResponse
+		err := row.Scan(&sub.ID, &sub.Endpoint, &sub.UserAgent, &sub.CreatedAt, &sub.LastUsedAt)
+		return sub, err
+	})
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query push subscriptions", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data":  subscriptions,
+		"count": len(subscriptions),
+	})
+}
+
+// POST /notification/push-subscriptions - saves a browser's push subscription. Posting
+// the same endpoint again refreshes its keys, and moves it to whoever is signed in now.
+func handleCreatePushSubscription(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID string) (events.APIGatewayProxyResponse, error) {
+	var req PushSubscriptionRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	var userAgent *string
+	if agent := request.Headers["User-Agent"] + request.Headers["user-agent"]; agent != "" {
+		if len(agent) > 500 {
+			agent = agent[:500]
+		}
+		userAgent = &agent
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	sub := PushSubscriptionResponse{Endpoint: req.Endpoint, UserAgent: userAgent}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO push_subscriptions (user_uuid, endpoint, p256dh, auth, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (endpoint) DO UPDATE
+		SET user_uuid = EXCLUDED.user_uuid, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth,
+			user_agent = EXCLUDED.user_agent
+		RETURNING id, created_at, last_used_at
+	`, userUUID, req.Endpoint, strings.TrimRight(req.Keys.P256dh, "="), strings.TrimRight(req.Keys.Auth, "="),
+		userAgent, time.Now()).Scan(&sub.ID, &sub.CreatedAt, &sub.LastUsedAt)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to save push subscription", err))
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM push_subscriptions WHERE user_uuid = $1 AND id NOT IN (
+			SELECT id FROM push_subscriptions WHERE user_uuid = $1 ORDER BY id DESC LIMIT $2
+		)
+	`, userUUID, maxPushSubscriptions)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to prune push subscriptions", err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to save push subscription", err))
+	}
+
+	return generic.Response(http.StatusCreated, generic.Json{"data": sub})
+}
+
+// DELETE /notification/push-subscriptions/{subscriptionId}
+func handleDeletePushSubscription(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, userUUID, subscriptionID string) (events.APIGatewayProxyResponse, error) {
+	tag, err := conn.Exec(ctx, `DELETE FROM push_subscriptions WHERE id = $1 AND user_uuid = $2`, subscriptionID, userUUID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to delete push subscription", err))
+	}
+	if tag.RowsAffected() == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "push subscription not found"})
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{"message": "Push subscription deleted"})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// MaxIntakeBatch caps how many animals one intake request can post
+const MaxIntakeBatch = 50
+
+// IntakeAnimal is an animal in a shelter's care. It is stored as a sighting at the
+// shelter's address so it shows up in the sighting feed and takes part in matching.
+type IntakeAnimal struct {
+	PetName     *string  `json:"petName,omitempty"`
+	PetID       *string  `json:"petId,omitempty"`
+	AnimalType  string   `json:"animalType"`
+	Gender      *string  `json:"gender,omitempty"`
+	Breed       []string `json:"breed,omitempty"`
+	Color       []string `json:"color"`
+	Description string   `json:"description,omitempty"`
+	IntakeDate  string   `json:"intakeDate,omitempty"`
+}
+
+type IntakeRequest struct {
+	Animals []IntakeAnimal `json:"animals"`
+}
+
+func (r *IntakeRequest) Validate() generic.ValidationErrors {
+	rules := []generic.Rule{
+		generic.RequiredValue("animals", len(r.Animals) > 0),
+		func() *generic.FieldError {
+			if len(r.Animals) > MaxIntakeBatch {
+				return &generic.FieldError{Field: "animals", Code: generic.CodeTooLong, Message: "must have at most " + strconv.Itoa(MaxIntakeBatch) + " animals"}
+			}
+			return nil
+		},
+	}
+	for i, animal := range r.Animals {
+		field := "animals[" + strconv.Itoa(i) + "]."
+		rules = append(rules,
+			generic.Required(field+"animalType", animal.AnimalType),
+			generic.OneOf(field+"animalType", animal.AnimalType, generic.AnimalTypes),
+			generic.RequiredList(field+"color", animal.Color),
+			generic.MaxLength(field+"description", animal.Description, generic.MaxDescriptionLength),
+			generic.PastDate(field+"intakeDate", animal.IntakeDate),
+		)
+		if animal.PetName != nil {
+			rules = append(rules, generic.MaxLength(field+"petName", *animal.PetName, 100))
+		}
+		if animal.Gender != nil {
+			rules = append(rules, generic.OneOf(field+"gender", *animal.Gender, generic.Genders))
+		}
+	}
+	return generic.Validate(rules...)
+}
+
+// POST /organization/{id}/intake - verified staff of a verified organization post the animals in their care
+func handleIntake(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	organizationID := request.PathParameters["id"]
+
+	var req IntakeRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+
+	if errs := req.Validate(); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var verified bool
+	var locationID int
+	err = conn.QueryRow(ctx, `SELECT verified, location_id FROM organizations WHERE id = $1`, organizationID).Scan(&verified, &locationID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "organization not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query organization", err))
+	}
+	if !verified {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "organization must be verified before posting intake listings"})
+	}
+
+	role, err := staffRole(ctx, conn, organizationID, userUUID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify staff role", err))
+	}
+	if role == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "only verified staff can post intake listings"})
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	insertQuery := `
+		INSERT INTO sighting_listing (
+			listing_owner, organization_id, is_found, pet_name, pet_id, gender, breed, color,
+			animal_type, description, date_spotted, spotted_location, created_at
+		) VALUES (
+			$1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13
+		) RETURNING id
+	`
+
+	ids := make([]int, 0, len(req.Animals))
+	for _, animal := range req.Animals {
+		intakeDate := time.Now()
+		if animal.IntakeDate != "" {
+			intakeDate, _ = generic.ParseDate(animal.IntakeDate)
+		}
+
+		var sightingID int
+		err := tx.QueryRow(ctx, insertQuery,
+			userUUID, organizationID, false, animal.PetName, animal.PetID, animal.Gender, animal.Breed, animal.Color,
+			animal.AnimalType, animal.Description, intakeDate, locationID, time.Now(),
+		).Scan(&sightingID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to insert intake listing", err))
+		}
+
+		changes := generic.Diff(nil, map[string]any{
+			"organization_id":  organizationID,
+			"pet_name":         animal.PetName,
+			"pet_id":           animal.PetID,
+			"gender":           animal.Gender,
+			"breed":            animal.Breed,
+			"color":            animal.Color,
+			"animal_type":      animal.AnimalType,
+			"description":      animal.Description,
+			"date_spotted":     intakeDate,
+			"spotted_location": locationID,
+		})
+		if err := generic.RecordHistory(ctx, tx, generic.ListingTypeSighting, sightingID, userUUID, generic.ActionCreate, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+		}
+		if err := generic.RecordEvent(ctx, tx, generic.ListingTypeSighting, sightingID, generic.ActionCreate, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+		}
+		if err := generic.QueueSightingAlerts(ctx, tx, sightingID); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to queue sighting alerts", err))
+		}
+
+		ids = append(ids, sightingID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to insert intake listings", err))
+	}
+
+	return generic.Response(http.StatusCreated, generic.Json{
+		"message": "Intake listings created",
+		"ids":     ids,
+		"count":   len(ids),
+	})
+}
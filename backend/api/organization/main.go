@@ -0,0 +1,708 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// Organization types
+const (
+	TypeShelter = "shelter"
+	TypeRescue  = "rescue"
+)
+
+var organizationTypes = []string{TypeShelter, TypeRescue}
+
+// Staff roles. Admins manage the profile and staff; staff post intake listings.
+const (
+	RoleAdmin = "admin"
+	RoleStaff = "staff"
+)
+
+var staffRoles = []string{RoleAdmin, RoleStaff}
+
+type OrganizationRequest struct {
+	Name        string                 `json:"name"`
+	Type        string                 `json:"type"`
+	Description *string                `json:"description,omitempty"`
+	Phone       *string                `json:"phone,omitempty"`
+	Email       *string                `json:"email,omitempty"`
+	Website     *string                `json:"website,omitempty"`
+	Hours       []generic.OpeningHours `json:"hours,omitempty"`
+
+	Location       string `json:"location"`
+	LocationCoords *struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"locationCoords,omitempty"`
+
+	City            string  `json:"city"`
+	ProvinceOrState string  `json:"provinceOrState"`
+	Country         string  `json:"country"`
+	PostalCode      *string `json:"postalCode,omitempty"`
+}
+
+// Validate checks the request and returns every violation. Partial requests (updates)
+// only validate the fields that are present.
+func (r *OrganizationRequest) Validate(partial bool) generic.ValidationErrors {
+	rules := []generic.Rule{
+		generic.OneOf("type", r.Type, organizationTypes),
+		generic.MaxLength("name", r.Name, 200),
+	}
+	if r.Description != nil {
+		rules = append(rules, generic.MaxLength("description", *r.Description, generic.MaxDescriptionLength))
+	}
+	if !partial {
+		rules = append(rules,
+			generic.Required("name", r.Name),
+			generic.Required("type", r.Type),
+			generic.Required("location", r.Location),
+			generic.RequiredValue("locationCoords", r.LocationCoords != nil || r.City != ""),
+		)
+	}
+	if r.LocationCoords != nil {
+		rules = append(rules,
+			generic.InRange("locationCoords.lat", r.LocationCoords.Lat, -90, 90),
+			generic.InRange("locationCoords.lng", r.LocationCoords.Lng, -180, 180),
+		)
+	}
+	for i, hours := range r.Hours {
+		rules = append(rules, generic.ValidHours("hours["+strconv.Itoa(i)+"]", hours))
+	}
+	return generic.Validate(rules...)
+}
+
+// place is the address in the request, without coordinates if it has none
+func (r *OrganizationRequest) place() generic.Place {
+	place := generic.Place{
+		StreetAddress:   r.Location,
+		PostalCode:      r.PostalCode,
+		City:            r.City,
+		ProvinceOrState: r.ProvinceOrState,
+		Country:         r.Country,
+	}
+	if r.LocationCoords != nil {
+		place.Lat, place.Lng = r.LocationCoords.Lat, r.LocationCoords.Lng
+	}
+	return place
+}
+
+type OrganizationResponse struct {
+	ID          int                    `json:"id"`
+	Name        string                 `json:"name"`
+	Type        string                 `json:"type"`
+	Description *string                `json:"description,omitempty"`
+	Phone       *string                `json:"phone,omitempty"`
+	Email       *string                `json:"email,omitempty"`
+	Website     *string                `json:"website,omitempty"`
+	Hours       []generic.OpeningHours `json:"hours"`
+	Verified    bool                   `json:"verified"`
+	LocationID  int                    `json:"location_id"`
+	Location    *Location              `json:"location,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+type Location struct {
+	ID            int     `json:"id"`
+	StreetAddress string  `json:"street_address"`
+	PostalCode    *string `json:"postal_code"`
+	Latitude      float64 `json:"latitude"`
+	Longitude     float64 `json:"longitude"`
+	CityID        *int    `json:"city_id,omitempty"`
+}
+
+// Helper functions
+func extractUserFromToken(request events.APIGatewayProxyRequest) (string, string, error) {
+	authHeader := request.Headers["Authorization"]
+	if authHeader == "" {
+		authHeader = request.Headers["authorization"]
+	}
+	if authHeader == "" {
+		return "", "", &generic.AuthError{Message: "missing authorization header"}
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	token = strings.TrimSpace(token)
+
+	tokenClaims, err := generic.TokenClaims(token)
+	if err != nil {
+		return "", "", &generic.AuthError{Message: "invalid or expired token", Err: err}
+	}
+
+	email, ok := tokenClaims["email"].(string)
+	if !ok || email == "" {
+		return "", "", &generic.AuthError{Message: "email not found in token"}
+	}
+
+	return token, email, nil
+}
+
+func getUserUUID(ctx context.Context, conn *pgx.Conn, email string) (string, error) {
+	var userUUID string
+	queryUser := `SELECT user_uuid FROM users WHERE email = $1`
+	err := conn.QueryRow(ctx, queryUser, email).Scan(&userUUID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", &generic.NotFoundError{Message: "user not found"}
+		}
+		return "", generic.Internal("failed to query user", err)
+	}
+	generic.SetUser(ctx, userUUID)
+	return userUUID, nil
+}
+
+// staffRole returns the caller's verified role in the organization, or "" if they aren't verified staff
+func staffRole(ctx context.Context, conn *pgx.Conn, organizationID, userUUID string) (string, error) {
+	var role string
+	query := `SELECT role FROM organization_staff WHERE organization_id = $1 AND user_uuid = $2 AND verified`
+	err := conn.QueryRow(ctx, query, organizationID, userUUID).Scan(&role)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// requireAdmin returns the caller's user UUID if they are a verified admin of the organization.
+// Moderators are treated as admins of every organization.
+func requireAdmin(ctx context.Context, conn *pgx.Conn, organizationID, email string) (string, error) {
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return "", err
+	}
+
+	if err := organizationExists(ctx, conn, organizationID); err != nil {
+		return "", err
+	}
+
+	role, err := staffRole(ctx, conn, organizationID, userUUID)
+	if err != nil {
+		return "", generic.Internal("failed to verify staff role", err)
+	}
+	if role == RoleAdmin {
+		return userUUID, nil
+	}
+
+	moderator, err := generic.IsModerator(ctx, conn, userUUID)
+	if err != nil {
+		return "", generic.Internal("failed to verify moderator", err)
+	}
+	if !moderator {
+		return "", &generic.ForbiddenError{Message: "only organization admins can do this"}
+	}
+	return userUUID, nil
+}
+
+func organizationExists(ctx context.Context, conn *pgx.Conn, organizationID string) error {
+	var exists bool
+	err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM organizations WHERE id = $1)`, organizationID).Scan(&exists)
+	if err != nil {
+		return generic.Internal("failed to query organization", err)
+	}
+	if !exists {
+		return &generic.NotFoundError{Message: "organization not found"}
+	}
+	return nil
+}
+
+func main() {
+	generic.Start("organization", handler)
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod == "OPTIONS" {
+		return generic.Response(http.StatusOK, generic.Json{})
+	}
+
+	if strings.Contains(request.Resource, "/webhooks") {
+		return routeWebhooks(ctx, request)
+	}
+
+	isStaff := strings.Contains(request.Resource, "/staff")
+
+	switch request.HTTPMethod {
+	case "GET":
+		if isStaff {
+			return handleListStaff(ctx, request)
+		}
+		return handleGet(ctx, request)
+	case "POST":
+		switch {
+		case isStaff:
+			return handleAddStaff(ctx, request)
+		case strings.HasSuffix(request.Resource, "/verify"):
+			return handleVerify(ctx, request)
+		case strings.HasSuffix(request.Resource, "/intake"):
+			return handleIntake(ctx, request)
+		}
+		return handleCreate(ctx, request)
+	case "PUT", "PATCH":
+		if isStaff {
+			return handleUpdateStaff(ctx, request)
+		}
+		return handleUpdate(ctx, request)
+	case "DELETE":
+		if isStaff {
+			return handleRemoveStaff(ctx, request)
+		}
+		return generic.MethodNotAllowed(request)
+	default:
+		return generic.MethodNotAllowed(request)
+	}
+}
+
+// ------------------ CREATE ------------------
+func handleCreate(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var req OrganizationRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+
+	if errs := req.Validate(false); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	place, err := generic.ResolvePlace(ctx, req.place())
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	cityID, err := generic.GetOrCreateCity(ctx, conn, place)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create or fetch city", err))
+	}
+
+	locationID, err := generic.GetOrCreateLocation(ctx, conn, place, cityID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create location", err))
+	}
+
+	hours := req.Hours
+	if hours == nil {
+		hours = []generic.OpeningHours{}
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	// New organizations stay unverified until a moderator checks them
+	insertQuery := `
+		INSERT INTO organizations (
+			name, org_type, description, phone, email, website, hours, location_id, created_by, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10
+		) RETURNING id
+	`
+	var organizationID int
+	err = tx.QueryRow(ctx, insertQuery,
+		req.Name, req.Type, req.Description, req.Phone, req.Email, req.Website, hours, locationID, userUUID, time.Now(),
+	).Scan(&organizationID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create organization", err))
+	}
+
+	// The creator is the first admin
+	_, err = tx.Exec(ctx, `
+		INSERT INTO organization_staff (organization_id, user_uuid, role, verified, added_by, created_at)
+		VALUES ($1, $2, $3, TRUE, $2, $4)
+	`, organizationID, userUUID, RoleAdmin, time.Now())
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to add organization admin", err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create organization", err))
+	}
+
+	return generic.Response(http.StatusCreated, generic.Json{
+		"message": "Organization created successfully",
+		"id":      organizationID,
+	})
+}
+
+// ------------------ READ ------------------
+func handleGet(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, _, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	if strings.HasSuffix(request.Resource, "/nearby") {
+		return getNearbyOrganizations(ctx, conn, request)
+	}
+	if organizationID := request.PathParameters["id"]; organizationID != "" {
+		return getOrganizationByID(ctx, conn, request, organizationID)
+	}
+	return getAllOrganizations(ctx, conn, request)
+}
+
+const organizationColumns = `
+	o.id, o.name, o.org_type, o.description, o.phone, o.email, o.website, o.hours,
+	o.verified, o.location_id, o.created_at, o.updated_at,
+	loc.id, loc.street_address, loc.postal_code, loc.latitude, loc.longitude, loc.city_id
+`
+
+func scanOrganization(row pgx.Row, extra ...any) (OrganizationResponse, error) {
+	var organization OrganizationResponse
+	var location Location
+
+	dest := []any{
+		&organization.ID, &organization.Name, &organization.Type, &organization.Description,
+		&organization.Phone, &organization.Email, &organization.Website, &organization.Hours,
+		&organization.Verified, &organization.LocationID, &organization.CreatedAt, &organization.UpdatedAt,
+		&location.ID, &location.StreetAddress, &location.PostalCode, &location.Latitude, &location.Longitude, &location.CityID,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return organization, err
+	}
+
+	organization.Location = &location
+	return organization, nil
+}
+
+func getOrganizationByID(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id string) (events.APIGatewayProxyResponse, error) {
+	query := `
+		SELECT ` + organizationColumns + `
+		FROM organizations o
+		JOIN locations loc ON o.location_id = loc.id
+		WHERE o.id = $1
+	`
+
+	organization, err := scanOrganization(conn.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "organization not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query organization", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{"data": organization})
+}
+
+func getAllOrganizations(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	queryParams := request.QueryStringParameters
+	from := `
+		FROM organizations o
+		JOIN locations loc ON o.location_id = loc.id
+		LEFT JOIN cities c ON loc.city_id = c.id
+		WHERE TRUE
+	`
+
+	args := []interface{}{}
+	argPos := 1
+
+	// Filter by organization type
+	if orgType, ok := queryParams["type"]; ok && orgType != "" {
+		if errs := generic.Validate(generic.OneOf("type", orgType, organizationTypes)); len(errs) > 0 {
+			return generic.ErrorResponse(ctx, request, errs)
+		}
+		from += ` AND o.org_type = $` + strconv.Itoa(argPos)
+		args = append(args, orgType)
+		argPos++
+	}
+
+	// Filter by city name
+	if city, ok := queryParams["city"]; ok && city != "" {
+		from += ` AND c.city_name ILIKE $` + strconv.Itoa(argPos)
+		args = append(args, city)
+		argPos++
+	}
+
+	// Filter by verification status
+	if verified, ok := queryParams["verified"]; ok && verified != "" {
+		from += ` AND o.verified = $` + strconv.Itoa(argPos)
+		args = append(args, verified == "true")
+		argPos++
+	}
+	filterArgs := args
+
+	sort, err := generic.WithOrder(generic.SortKey{
+		Name:     generic.SortCreated,
+		Column:   "o.created_at",
+		Type:     "timestamptz",
+		IDColumn: "o.id",
+		Desc:     true,
+	}, queryParams["order"])
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	page, err := generic.ParsePage(queryParams, sort)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var total int64
+	if page.Total != generic.TotalNone {
+		total, err = generic.CountTotal(ctx, conn, page.Total, from, filterArgs)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to count organizations", err))
+		}
+	}
+
+	pageClause, pageArgs := page.Clause(sort, args)
+	query := `SELECT ` + organizationColumns + `, (` + sort.Column + `)::text ` + from + pageClause
+
+	rows, err := conn.Query(ctx, query, pageArgs...)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query organizations", err))
+	}
+	defer rows.Close()
+
+	type sortedOrganization struct {
+		OrganizationResponse
+		sortValue string
+	}
+
+	var organizations []sortedOrganization
+	for rows.Next() {
+		var sortValue string
+		organization, err := scanOrganization(rows, &sortValue)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to scan organization", err))
+		}
+		organizations = append(organizations, sortedOrganization{organization, sortValue})
+	}
+
+	organizations, nextCursor, prevCursor := generic.PageResult(organizations, page, sort, func(organization sortedOrganization) (string, int) {
+		return organization.sortValue, organization.ID
+	})
+
+	data := make([]OrganizationResponse, len(organizations))
+	for i, organization := range organizations {
+		data[i] = organization.OrganizationResponse
+	}
+
+	body := generic.Json{
+		"data":        data,
+		"count":       len(data),
+		"limit":       page.Limit,
+		"next_cursor": nextCursor,
+		"prev_cursor": prevCursor,
+	}
+	if page.Total != generic.TotalNone {
+		body["total"] = total
+		body["total_estimated"] = page.Total == generic.TotalEstimate
+	}
+
+	return generic.Response(http.StatusOK, body)
+}
+
+// GET /organization/nearby?lat=&lng= - shelters and rescues nearest a point
+func getNearbyOrganizations(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	lat, lng, err := generic.ParseOrigin(request.QueryStringParameters)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	radius, limit, err := generic.ParseShelterSearch(request.QueryStringParameters)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	shelters, err := generic.NearbyShelters(ctx, conn, lat, lng, radius, limit)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query nearby shelters", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data":      shelters,
+		"count":     len(shelters),
+		"radius_km": radius,
+	})
+}
+
+// ------------------ UPDATE ------------------
+func handleUpdate(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	organizationID := request.PathParameters["id"]
+	if organizationID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "organization ID is required", Field: "id"})
+	}
+
+	var req OrganizationRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+
+	if errs := req.Validate(true); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	if _, err := requireAdmin(ctx, conn, organizationID, email); err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	updateFields := []string{}
+	args := []interface{}{}
+	argPos := 1
+
+	if req.Name != "" {
+		updateFields = append(updateFields, "name = $"+strconv.Itoa(argPos))
+		args = append(args, req.Name)
+		argPos++
+	}
+	if req.Type != "" {
+		updateFields = append(updateFields, "org_type = $"+strconv.Itoa(argPos))
+		args = append(args, req.Type)
+		argPos++
+	}
+	if req.Description != nil {
+		updateFields = append(updateFields, "description = $"+strconv.Itoa(argPos))
+		args = append(args, *req.Description)
+		argPos++
+	}
+	if req.Phone != nil {
+		updateFields = append(updateFields, "phone = $"+strconv.Itoa(argPos))
+		args = append(args, *req.Phone)
+		argPos++
+	}
+	if req.Email != nil {
+		updateFields = append(updateFields, "email = $"+strconv.Itoa(argPos))
+		args = append(args, *req.Email)
+		argPos++
+	}
+	if req.Website != nil {
+		updateFields = append(updateFields, "website = $"+strconv.Itoa(argPos))
+		args = append(args, *req.Website)
+		argPos++
+	}
+	if req.Hours != nil {
+		updateFields = append(updateFields, "hours = $"+strconv.Itoa(argPos))
+		args = append(args, req.Hours)
+		argPos++
+	}
+
+	if req.LocationCoords != nil || (req.Location != "" && req.City != "") {
+		place, err := generic.ResolvePlace(ctx, req.place())
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+		cityID, err := generic.GetOrCreateCity(ctx, conn, place)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to create or fetch city", err))
+		}
+		locationID, err := generic.GetOrCreateLocation(ctx, conn, place, cityID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to create location", err))
+		}
+		updateFields = append(updateFields, "location_id = $"+strconv.Itoa(argPos))
+		args = append(args, locationID)
+		argPos++
+	}
+
+	if len(updateFields) == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "no fields to update"})
+	}
+
+	updateFields = append(updateFields, "updated_at = $"+strconv.Itoa(argPos))
+	args = append(args, time.Now())
+	argPos++
+
+	query := `UPDATE organizations SET ` + strings.Join(updateFields, ", ") + ` WHERE id = $` + strconv.Itoa(argPos)
+	args = append(args, organizationID)
+
+	if _, err := conn.Exec(ctx, query, args...); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update organization", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{"message": "Organization updated successfully"})
+}
+
+// POST /organization/{id}/verify - moderators confirm an organization is a real shelter or rescue
+func handleVerify(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	organizationID := request.PathParameters["id"]
+	if organizationID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "organization ID is required", Field: "id"})
+	}
+
+	var req struct {
+		Verified *bool `json:"verified"`
+	}
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+		}
+	}
+	verified := req.Verified == nil || *req.Verified
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	moderator, err := generic.IsModerator(ctx, conn, userUUID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify moderator", err))
+	}
+	if !moderator {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "only moderators can verify organizations"})
+	}
+
+	tag, err := conn.Exec(ctx, `UPDATE organizations SET verified = $1, updated_at = $2 WHERE id = $3`, verified, time.Now(), organizationID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify organization", err))
+	}
+	if tag.RowsAffected() == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "organization not found"})
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"message":  "Organization verification updated",
+		"verified": verified,
+	})
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+type StaffRequest struct {
+	Email    string `json:"email,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Verified *bool  `json:"verified,omitempty"`
+}
+
+type StaffResponse struct {
+	UserUUID   string     `json:"user_uuid"`
+	Name       *string    `json:"name,omitempty"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// GET /organization/{id}/staff - visible to the organization's verified staff and moderators
+func handleListStaff(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	organizationID := request.PathParameters["id"]
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	if err := organizationExists(ctx, conn, organizationID); err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	role, err := staffRole(ctx, conn, organizationID, userUUID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify staff role", err))
+	}
+	if role == "" {
+		moderator, err := generic.IsModerator(ctx, conn, userUUID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify moderator", err))
+		}
+		if !moderator {
+			return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "only organization staff can view staff"})
+		}
+	}
+
+	query := `
+		SELECT s.user_uuid, u.name, u.email, s.role, s.verified, s.verified_at, s.created_at
+		FROM organization_staff s
+		JOIN users u ON u.user_uuid = s.user_uuid
+		WHERE s.organization_id = $1
+		ORDER BY s.created_at
+	`
+	rows, err := conn.Query(ctx, query, organizationID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query staff", err))
+	}
+	defer rows.Close()
+
+	staff := []StaffResponse{}
+	for rows.Next() {
+		var member StaffResponse
+		err := rows.Scan(&member.UserUUID, &member.Name, &member.Email, &member.Role, &member.Verified, &member.VerifiedAt, &member.CreatedAt)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to scan staff", err))
+		}
+		staff = append(staff, member)
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data":  staff,
+		"count": len(staff),
+	})
+}
+
+// POST /organization/{id}/staff - admins add a user by email (verified immediately);
+// anyone else posting without an email asks to join and waits for an admin to verify them
+func handleAddStaff(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	organizationID := request.PathParameters["id"]
+
+	var req StaffRequest
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+		}
+	}
+	if req.Role == "" {
+		req.Role = RoleStaff
+	}
+	if errs := generic.Validate(generic.OneOf("role", req.Role, staffRoles)); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	var memberUUID, addedBy string
+	verified := false
+	if req.Email == "" {
+		// Join request
+		if memberUUID, err = getUserUUID(ctx, conn, email); err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+		if err := organizationExists(ctx, conn, organizationID); err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+		addedBy = memberUUID
+		req.Role = RoleStaff
+	} else {
+		if addedBy, err = requireAdmin(ctx, conn, organizationID, email); err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+		err = conn.QueryRow(ctx, `SELECT user_uuid FROM users WHERE email = $1`, req.Email).Scan(&memberUUID)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "no user with this email has signed in yet", Field: "email"})
+			}
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to query user", err))
+		}
+		verified = true
+	}
+
+	var verifiedAt *time.Time
+	if verified {
+		now := time.Now()
+		verifiedAt = &now
+	}
+
+	tag, err := conn.Exec(ctx, `
+		INSERT INTO organization_staff (organization_id, user_uuid, role, verified, verified_at, added_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (organization_id, user_uuid) DO NOTHING
+	`, organizationID, memberUUID, req.Role, verified, verifiedAt, addedBy, time.Now())
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to add staff", err))
+	}
+	if tag.RowsAffected() == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "user is already a member of this organization", Field: "email"})
+	}
+
+	message := "Staff member added"
+	if !verified {
+		message = "Join request sent; an organization admin must verify it"
+	}
+	return generic.Response(http.StatusCreated, generic.Json{
+		"message":   message,
+		"user_uuid": memberUUID,
+		"verified":  verified,
+	})
+}
+
+// PATCH /organization/{id}/staff/{userId} - admins verify a member or change their role
+func handleUpdateStaff(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	organizationID := request.PathParameters["id"]
+	memberUUID := request.PathParameters["userId"]
+	if memberUUID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "user ID is required", Field: "userId"})
+	}
+
+	var req StaffRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	if errs := generic.Validate(generic.OneOf("role", req.Role, staffRoles)); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+	if req.Role == "" && req.Verified == nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "no fields to update"})
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	if _, err := requireAdmin(ctx, conn, organizationID, email); err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	// Like removing staff, demoting or unverifying the last verified admin is refused
+	query := `
+		UPDATE organization_staff s SET
+			role = COALESCE(NULLIF($1, ''), role),
+			verified = COALESCE($2, verified),
+			verified_at = CASE WHEN $2 THEN COALESCE(verified_at, $3) WHEN NOT $2 THEN NULL ELSE verified_at END
+		WHERE s.organization_id = $4 AND s.user_uuid = $5
+		AND (s.role <> $6 OR NOT s.verified OR (COALESCE(NULLIF($1, ''), s.role) = $6 AND COALESCE($2, s.verified)) OR EXISTS (
+			SELECT 1 FROM organization_staff o
+			WHERE o.organization_id = s.organization_id AND o.user_uuid <> s.user_uuid AND o.role = $6 AND o.verified
+		))
+	`
+	tag, err := conn.Exec(ctx, query, req.Role, req.Verified, time.Now(), organizationID, memberUUID, RoleAdmin)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update staff", err))
+	}
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM organization_staff WHERE organization_id = $1 AND user_uuid = $2)`, organizationID, memberUUID).Scan(&exists)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to update staff", err))
+		}
+		if exists {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "an organization needs at least one verified admin"})
+		}
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "staff member not found"})
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{"message": "Staff member updated"})
+}
+
+// DELETE /organization/{id}/staff/{userId} - admins remove anyone; members can remove themselves
+func handleRemoveStaff(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	organizationID := request.PathParameters["id"]
+	memberUUID := request.PathParameters["userId"]
+	if memberUUID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "user ID is required", Field: "userId"})
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+	if userUUID != memberUUID {
+		if _, err := requireAdmin(ctx, conn, organizationID, email); err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+	}
+
+	// Keep at least one verified admin so the organization can still be managed
+	query := `
+		DELETE FROM organization_staff s
+		WHERE s.organization_id = $1 AND s.user_uuid = $2
+		AND (s.role <> $3 OR NOT s.verified OR EXISTS (
+			SELECT 1 FROM organization_staff o
+			WHERE o.organization_id = s.organization_id AND o.user_uuid <> s.user_uuid AND o.role = $3 AND o.verified
+		))
+	`
+	tag, err := conn.Exec(ctx, query, organizationID, memberUUID, RoleAdmin)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to remove staff", err))
+	}
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM organization_staff WHERE organization_id = $1 AND user_uuid = $2)`, organizationID, memberUUID).Scan(&exists)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to remove staff", err))
+		}
+		if exists {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "an organization needs at least one verified admin"})
+		}
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "staff member not found"})
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{"message": "Staff member removed"})
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// Deliveries listed per request in the delivery log
+const deliveryLogLimit = 100
+
+type WebhookRequest struct {
+	URL    string   `json:"url,omitempty"`
+	Events []string `json:"events,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+	// Set on update to replace the signing secret; the new one is returned once
+	RotateSecret bool `json:"rotateSecret,omitempty"`
+}
+
+func (r *WebhookRequest) Validate(partial bool) generic.ValidationErrors {
+	rules := []generic.Rule{
+		generic.WebhookURL("url", r.URL),
+		generic.MaxLength("url", r.URL, 2000),
+	}
+	if !partial {
+		rules = append(rules,
+			generic.Required("url", r.URL),
+			generic.RequiredList("events", r.Events),
+		)
+	}
+	for i, event := range r.Events {
+		rules = append(rules, generic.OneOf("events["+strconv.Itoa(i)+"]", event, generic.EventTypes))
+	}
+	return generic.Validate(rules...)
+}
+
+type WebhookResponse struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"` // only returned when created or rotated
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type DeliveryResponse struct {
+	ID             int64      `json:"id"`
+	EventID        int64      `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int       `json:"last_status_code,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	ReplayOf       *int64     `json:"replay_of,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// routeWebhooks dispatches the /organization/{id}/webhooks routes. Every route is for the
+// organization's admins (and moderators) only.
+func routeWebhooks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	organizationID := request.PathParameters["id"]
+	userUUID, err := requireAdmin(ctx, conn, organizationID, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	webhookID := request.PathParameters["webhookId"]
+	isDeliveries := strings.Contains(request.Resource, "/deliveries")
+	switch {
+	case request.HTTPMethod == "GET" && isDeliveries:
+		return handleListDeliveries(ctx, conn, request, organizationID, webhookID)
+	case request.HTTPMethod == "POST" && strings.HasSuffix(request.Resource, "/replay"):
+		return handleReplayDelivery(ctx, conn, request, organizationID, webhookID)
+	case request.HTTPMethod == "GET" && webhookID == "":
+		return handleListWebhooks(ctx, conn, request, organizationID)
+	case request.HTTPMethod == "POST" && webhookID == "":
+		return handleCreateWebhook(ctx, conn, request, organizationID, userUUID)
+	case (request.HTTPMethod == "PUT" || request.HTTPMethod == "PATCH") && webhookID != "":
+		return handleUpdateWebhook(ctx, conn, request, organizationID, webhookID)
+	case request.HTTPMethod == "DELETE" && webhookID != "":
+		return handleDeleteWebhook(ctx, conn, request, organizationID, webhookID)
+	}
+	return generic.MethodNotAllowed(request)
+}
+
+// GET /organization/{id}/webhooks
+func handleListWebhooks(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, organizationID string) (events.APIGatewayProxyResponse, error) {
+	query := `
+		SELECT id, url, events, active, created_at, updated_at
+		FROM webhook_endpoints
+		WHERE organization_id = $1
+		ORDER BY id
+	`
+	rows, err := conn.Query(ctx, query, organizationID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query webhooks", err))
+	}
+	webhooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (WebhookResponse, error) {
+		var webhook WebhookResponse
+		err := row.Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt)
+		return webhook, err
+	})
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query webhooks", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data":  webhooks,
+		"count": len(webhooks),
+	})
+}
+
+// POST /organization/{id}/webhooks - registers an endpoint. The signing secret is only
+// returned in this response. Only verified organizations can receive events.
+func handleCreateWebhook(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, organizationID, userUUID string) (events.APIGatewayProxyResponse, error) {
+	var req WebhookRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	if errs := req.Validate(false); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	var verified bool
+	if err := conn.QueryRow(ctx, `SELECT verified FROM organizations WHERE id = $1`, organizationID).Scan(&verified); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query organization", err))
+	}
+	if !verified {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "only verified organizations can register webhooks"})
+	}
+
+	secret, err := generic.NewWebhookSecret()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to generate webhook secret", err))
+	}
+
+	webhook := WebhookResponse{URL: req.URL, Events: req.Events, Active: true, Secret: secret}
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	now := time.Now()
+	err = conn.QueryRow(ctx, `
+		INSERT INTO webhook_endpoints (organization_id, url, secret, events, active, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING id, created_at, updated_at
+	`, organizationID, req.URL, secret, req.Events, webhook.Active, userUUID, now).Scan(&webhook.ID, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create webhook", err))
+	}
+
+	return generic.Response(http.StatusCreated, generic.Json{"data": webhook})
+}
+
+// PATCH /organization/{id}/webhooks/{webhookId} - change the URL or events, pause with
+// active=false, or rotate the secret
+func handleUpdateWebhook(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, organizationID, webhookID string) (events.APIGatewayProxyResponse, error) {
+	var req WebhookRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	if errs := req.Validate(true); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	updateFields := []string{}
+	args := []interface{}{}
+	argPos := 1
+
+	if req.URL != "" {
+		updateFields = append(updateFields, "url = $"+strconv.Itoa(argPos))
+		args = append(args, req.URL)
+		argPos++
+	}
+	if len(req.Events) > 0 {
+		updateFields = append(updateFields, "events = $"+strconv.Itoa(argPos))
+		args = append(args, req.Events)
+		argPos++
+	}
+	if req.Active != nil {
+		updateFields = append(updateFields, "active = $"+strconv.Itoa(argPos))
+		args = append(args, *req.Active)
+		argPos++
+	}
+	var secret string
+	if req.RotateSecret {
+		var err error
+		if secret, err = generic.NewWebhookSecret(); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to generate webhook secret", err))
+		}
+		updateFields = append(updateFields, "secret = $"+strconv.Itoa(argPos))
+		args = append(args, secret)
+		argPos++
+	}
+	if len(updateFields) == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "no fields to update"})
+	}
+
+	updateFields = append(updateFields, "updated_at = $"+strconv.Itoa(argPos))
+	args = append(args, time.Now())
+	argPos++
+
+	args = append(args, webhookID, organizationID)
+	query := `
+		UPDATE webhook_endpoints SET ` + strings.Join(updateFields, ", ") + `
+		WHERE id = $` + strconv.Itoa(argPos) + ` AND organization_id = $` + strconv.Itoa(argPos+1) + `
+		RETURNING id, url, events, active, created_at, updated_at
+	`
+	var webhook WebhookResponse
+	err := conn.QueryRow(ctx, query, args...).Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "webhook not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update webhook", err))
+	}
+	webhook.Secret = secret
+
+	return generic.Response(http.StatusOK, generic.Json{"data": webhook})
+}
+
+// DELETE /organization/{id}/webhooks/{webhookId} - removes the endpoint and its delivery log
+func handleDeleteWebhook(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, organizationID, webhookID string) (events.APIGatewayProxyResponse, error) {
+	tag, err := conn.Exec(ctx, `DELETE FROM webhook_endpoints WHERE id = $1 AND organization_id = $2`, webhookID, organizationID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to delete webhook", err))
+	}
+	if tag.RowsAffected() == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "webhook not found"})
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{"message": "Webhook deleted"})
+}
+
+// GET /organization/{id}/webhooks/{webhookId}/deliveries - the most recent deliveries,
+// newest first. ?status=pending|succeeded|failed filters them.
+func handleListDeliveries(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, organizationID, webhookID string) (events.APIGatewayProxyResponse, error) {
+	status := request.QueryStringParameters["status"]
+	statuses := []string{generic.DeliveryPending, generic.DeliverySucceeded, generic.DeliveryFailed}
+	if errs := generic.Validate(generic.OneOf("status", status, statuses)); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
+	}
+
+	query := `
+		SELECT
+			d.id, d.event_id, e.event_type, d.status, d.attempts, d.next_attempt_at,
+			d.last_status_code, d.last_error, d.replay_of, d.created_at, d.delivered_at
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints w ON w.id = d.endpoint_id
+		JOIN event_outbox e ON e.id = d.event_id
+		WHERE d.endpoint_id = $1 AND w.organization_id = $2 AND ($3 = '' OR d.status = $3)
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $4
+	`
+	rows, err := conn.Query(ctx, query, webhookID, organizationID, status, deliveryLogLimit)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query webhook deliveries", err))
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (DeliveryResponse, error) {
+		var delivery DeliveryResponse
+		var nextAttemptAt time.Time
+		err := row.Scan(
+			&delivery.ID, &delivery.EventID, &delivery.EventType, &delivery.Status, &delivery.Attempts, &nextAttemptAt,
+			&delivery.LastStatusCode, &delivery.LastError, &delivery.ReplayOf, &delivery.CreatedAt, &delivery.DeliveredAt,
+		)
+		if delivery.Status == generic.DeliveryPending {
+			delivery.NextAttemptAt = &nextAttemptAt
+		}
+		return delivery, err
+	})
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query webhook deliveries", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"data":  deliveries,
+		"count": len(deliveries),
+	})
+}
+
+// POST /organization/{id}/webhooks/{webhookId}/deliveries/{deliveryId}/replay - queues the
+// delivery's event to be sent again. The original delivery stays in the log unchanged.
+func handleReplayDelivery(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, organizationID, webhookID string) (events.APIGatewayProxyResponse, error) {
+	deliveryID := request.PathParameters["deliveryId"]
+
+	var replayID int64
+	err := conn.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, status, next_attempt_at, replay_of, created_at)
+		SELECT d.endpoint_id, d.event_id, 'pending', $4, d.id, $4
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints w ON w.id = d.endpoint_id
+		WHERE d.id = $1 AND d.endpoint_id = $2 AND w.organization_id = $3
+		RETURNING id
+	`, deliveryID, webhookID, organizationID, time.Now()).Scan(&replayID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "webhook delivery not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to replay webhook delivery", err))
+	}
+
+	return generic.Response(http.StatusAccepted, generic.Json{
+		"message": "Delivery queued for replay",
+		"id":      replayID,
+	})
+}
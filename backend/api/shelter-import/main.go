@@ -0,0 +1,332 @@
+// shelter-import loads a shelter and rescue dataset into the organizations table.
+//
+// Usage:
+//
+//	DATABASE_URL=... go run ./shelter-import -file shelters.csv [-source name] [-dry-run]
+//
+// CSV files need a header row with these columns (any order, extra columns ignored):
+//
+//	external_id, name, type, street_address, city, province_or_state, country,
+//	postal_code, latitude, longitude, phone, email, website, hours
+//
+// GeoJSON files are a FeatureCollection of Point features with the same property
+// names; coordinates come from the geometry. Hours use the compact form
+// "Mon-Fri 09:00-17:00; Sat 10:00-14:00" (GeoJSON may also give an array of
+// {day, opens, closes}). Rows are upserted on (source, external_id), so re-running
+// an import updates the existing entries instead of duplicating them.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/jackc/pgx/v5"
+)
+
+var organizationTypes = []string{"shelter", "rescue"}
+
+type ShelterRecord struct {
+	ExternalID      string
+	Name            string
+	Type            string
+	StreetAddress   string
+	City            string
+	ProvinceOrState string
+	Country         string
+	PostalCode      *string
+	Latitude        float64
+	Longitude       float64
+	Phone           *string
+	Email           *string
+	Website         *string
+	Hours           []generic.OpeningHours
+}
+
+func (r *ShelterRecord) Validate() generic.ValidationErrors {
+	return generic.Validate(
+		generic.Required("name", r.Name),
+		generic.OneOf("type", r.Type, organizationTypes),
+		generic.Required("street_address", r.StreetAddress),
+		generic.InRange("latitude", r.Latitude, -90, 90),
+		generic.InRange("longitude", r.Longitude, -180, 180),
+	)
+}
+
+func main() {
+	file := flag.String("file", "", "path to a .csv or .geojson shelter dataset")
+	source := flag.String("source", "", "dataset name used to match rows on re-import (defaults to the file name)")
+	dryRun := flag.Bool("dry-run", false, "parse and validate the file without writing to the database")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *source == "" {
+		*source = strings.TrimSuffix(filepath.Base(*file), filepath.Ext(*file))
+	}
+
+	records, err := readDataset(*file)
+	if err != nil {
+		log.Fatalf("failed to read %s: %v", *file, err)
+	}
+	log.Printf("parsed %d shelters from %s", len(records), *file)
+
+	if *dryRun {
+		return
+	}
+
+	ctx := context.Background()
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	created, updated := 0, 0
+	for _, record := range records {
+		inserted, err := upsertShelter(ctx, conn, *source, record)
+		if err != nil {
+			log.Fatalf("failed to import %q: %v", record.Name, err)
+		}
+		if inserted {
+			created++
+		} else {
+			updated++
+		}
+	}
+	log.Printf("imported %d shelters (%d new, %d updated)", len(records), created, updated)
+}
+
+func readDataset(path string) ([]ShelterRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return readCSV(f)
+	case ".geojson", ".json":
+		return readGeoJSON(f)
+	default:
+		return nil, errors.New("unsupported file type, expected .csv or .geojson")
+	}
+}
+
+func readCSV(r io.Reader) ([]ShelterRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	var records []ShelterRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		record, err := newRecord(get, nil)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func readGeoJSON(r io.Reader) ([]ShelterRecord, error) {
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string    `json:"type"`
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, err
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, errors.New("expected a GeoJSON FeatureCollection")
+	}
+
+	var records []ShelterRecord
+	for i, feature := range collection.Features {
+		if feature.Geometry.Type != "Point" || len(feature.Geometry.Coordinates) < 2 {
+			return nil, fmt.Errorf("feature %d: expected a Point geometry", i)
+		}
+
+		get := func(property string) string {
+			switch value := feature.Properties[property].(type) {
+			case string:
+				return strings.TrimSpace(value)
+			case float64:
+				return strconv.FormatFloat(value, 'f', -1, 64)
+			}
+			return ""
+		}
+		// GeoJSON positions are [longitude, latitude]
+		coords := []float64{feature.Geometry.Coordinates[1], feature.Geometry.Coordinates[0]}
+
+		record, err := newRecord(get, coords)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+		if hours, ok := feature.Properties["hours"].([]any); ok {
+			raw, _ := json.Marshal(hours)
+			if err := json.Unmarshal(raw, &record.Hours); err != nil {
+				return nil, fmt.Errorf("feature %d: invalid hours: %w", i, err)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// newRecord builds a record from named fields. coords overrides the latitude/longitude fields when set.
+func newRecord(get func(string) string, coords []float64) (ShelterRecord, error) {
+	optional := func(field string) *string {
+		if value := get(field); value != "" {
+			return &value
+		}
+		return nil
+	}
+
+	record := ShelterRecord{
+		ExternalID:      get("external_id"),
+		Name:            get("name"),
+		Type:            strings.ToLower(get("type")),
+		StreetAddress:   get("street_address"),
+		City:            get("city"),
+		ProvinceOrState: get("province_or_state"),
+		Country:         get("country"),
+		PostalCode:      optional("postal_code"),
+		Phone:           optional("phone"),
+		Email:           optional("email"),
+		Website:         optional("website"),
+	}
+	if record.Type == "" {
+		record.Type = "shelter"
+	}
+
+	if coords != nil {
+		record.Latitude, record.Longitude = coords[0], coords[1]
+	} else {
+		lat, latErr := strconv.ParseFloat(get("latitude"), 64)
+		lng, lngErr := strconv.ParseFloat(get("longitude"), 64)
+		if latErr != nil || lngErr != nil {
+			return record, errors.New("latitude and longitude are required")
+		}
+		record.Latitude, record.Longitude = lat, lng
+	}
+
+	hours, err := generic.ParseHours(get("hours"))
+	if err != nil {
+		return record, err
+	}
+	record.Hours = hours
+
+	// Datasets without IDs are matched on name and address
+	if record.ExternalID == "" {
+		record.ExternalID = strings.ToLower(record.Name + "|" + record.StreetAddress)
+	}
+
+	if errs := record.Validate(); len(errs) > 0 {
+		return record, errs
+	}
+
+	place, err := generic.ResolvePlace(context.Background(), generic.Place{
+		StreetAddress:   record.StreetAddress,
+		PostalCode:      record.PostalCode,
+		Lat:             record.Latitude,
+		Lng:             record.Longitude,
+		City:            record.City,
+		ProvinceOrState: record.ProvinceOrState,
+		Country:         record.Country,
+	})
+	if err != nil {
+		return record, err
+	}
+	record.StreetAddress, record.PostalCode = place.StreetAddress, place.PostalCode
+	record.City, record.ProvinceOrState, record.Country = place.City, place.ProvinceOrState, place.Country
+	return record, nil
+}
+
+// upsertShelter writes one record and reports whether it was newly created
+func upsertShelter(ctx context.Context, conn *pgx.Conn, source string, record ShelterRecord) (bool, error) {
+	place := generic.Place{
+		StreetAddress:   record.StreetAddress,
+		PostalCode:      record.PostalCode,
+		Lat:             record.Latitude,
+		Lng:             record.Longitude,
+		City:            record.City,
+		ProvinceOrState: record.ProvinceOrState,
+		Country:         record.Country,
+	}
+	cityID, err := generic.GetOrCreateCity(ctx, conn, place)
+	if err != nil {
+		return false, err
+	}
+
+	locationID, err := generic.GetOrCreateLocation(ctx, conn, place, cityID)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO organizations (
+			name, org_type, phone, email, website, hours, location_id, source, external_id, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10
+		)
+		ON CONFLICT (source, external_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			org_type = EXCLUDED.org_type,
+			phone = EXCLUDED.phone,
+			email = EXCLUDED.email,
+			website = EXCLUDED.website,
+			hours = EXCLUDED.hours,
+			location_id = EXCLUDED.location_id,
+			updated_at = EXCLUDED.updated_at
+		RETURNING (xmax = 0)
+	`
+	var inserted bool
+	err = conn.QueryRow(ctx, query,
+		record.Name, record.Type, record.Phone, record.Email, record.Website, record.Hours, locationID, source, record.ExternalID, time.Now(),
+	).Scan(&inserted)
+	return inserted, err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackc/pgx/v5"
+)
+
+var exportColumns = []string{
+	"id", "pet_name", "pet_id", "animal_type", "gender", "breed", "color", "description",
+	"organization_id", "is_found", "date_found", "date_spotted", "created_at", "updated_at",
+	"street_address", "postal_code", "city", "province_or_state", "country",
+}
+
+// GET /sighting-listing/export?format=csv|geojson|kml - the listings matching the same filters
+// as GET /sighting-listing, newest first. Owner identities are left out.
+func exportSightings(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, email string) (events.APIGatewayProxyResponse, error) {
+	queryParams := request.QueryStringParameters
+
+	exporter, err := generic.NewExporter(queryParams["format"], exportColumns, "Sightings")
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	where, args, _, err := listFilters(ctx, conn, queryParams, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	query := `
+		SELECT
+			s.id, s.pet_name, s.pet_id, s.animal_type, s.gender, s.breed, s.color, s.description,
+			s.organization_id, s.is_found, s.date_found, s.date_spotted, s.created_at, s.updated_at,
+			loc.street_address, loc.postal_code, c.city_name, c.province_or_state, c.country,
+			loc.latitude, loc.longitude
+		FROM sighting_listing s
+		LEFT JOIN locations loc ON s.spotted_location = loc.id
+		LEFT JOIN cities c ON loc.city_id = c.id
+	` + where + `
+		ORDER BY s.created_at DESC, s.id DESC
+		LIMIT $` + strconv.Itoa(len(args)+1)
+	args = append(args, generic.MaxExportRows+1)
+
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query sighting listings", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if exporter.Rows() == generic.MaxExportRows {
+			exporter.Truncated = true
+			break
+		}
+
+		var (
+			id                                 int
+			organizationID                     *int
+			animalType, description            string
+			petName, petID, gender             *string
+			breed, color                       []string
+			isFound                            bool
+			dateFound                          *time.Time
+			dateSpotted, createdAt, updatedAt  time.Time
+			street, postalCode, city, province *string
+			country                            *string
+			lat, lng                           *float64
+		)
+		err := rows.Scan(
+			&id, &petName, &petID, &animalType, &gender, &breed, &color, &description,
+			&organizationID, &isFound, &dateFound, &dateSpotted, &createdAt, &updatedAt,
+			&street, &postalCode, &city, &province, &country,
+			&lat, &lng,
+		)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to scan sighting listing", err))
+		}
+
+		name := animalType + " sighting"
+		if petName != nil && *petName != "" {
+			name = *petName
+		}
+		if !exporter.Add(name, []any{
+			id, petName, petID, animalType, gender, breed, color, description,
+			organizationID, isFound, dateFound, dateSpotted, createdAt, updatedAt,
+			street, postalCode, city, province, country,
+		}, lat, lng) {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query sighting listings", err))
+	}
+
+	return exporter.Response("sightings-" + time.Now().Format("2006-01-02"))
+}
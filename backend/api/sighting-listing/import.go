@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// importRow is a row that passed validation and is waiting to be inserted
+type importRow struct {
+	row         int
+	req         SightingRequest
+	place       generic.Place
+	dateSpotted time.Time
+}
+
+// POST /sighting-listing/import - creates sightings from a CSV or JSON array of
+// SightingRequest rows. Invalid rows and duplicates are reported and skipped; the rest are
+// imported in one transaction. ?dryRun=true validates without writing anything.
+// Verified staff can pass ?organizationId= to post the rows as that organization's intake.
+func handleImport(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	dryRun := request.QueryStringParameters["dryRun"] == "true"
+
+	body, err := generic.ImportBody(request.Body, request.IsBase64Encoded)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	contentType := request.Headers["Content-Type"]
+	if contentType == "" {
+		contentType = request.Headers["content-type"]
+	}
+
+	rawRows, err := generic.DecodeImportRows(body, contentType, "color", "breed")
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+	if len(rawRows) == 0 {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "import has no rows"})
+	}
+	if len(rawRows) > generic.MaxImportRows {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "import is limited to " + strconv.Itoa(generic.MaxImportRows) + " rows per request"})
+	}
+
+	var rows []importRow
+	var rowErrors []generic.ImportRowError
+	for i, raw := range rawRows {
+		rowNumber := i + 1
+
+		var req SightingRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			rowErrors = append(rowErrors, generic.ImportRowError{Row: rowNumber, Message: "row does not match the listing format"})
+			continue
+		}
+		if errs := req.Validate(false); len(errs) > 0 {
+			rowErrors = append(rowErrors, generic.ImportRowError{Row: rowNumber, Fields: errs})
+			continue
+		}
+		dateSpotted, err := parseDate(req.DateSpotted)
+		if err != nil {
+			rowErrors = append(rowErrors, generic.ImportRowError{Row: rowNumber, Message: err.Error()})
+			continue
+		}
+
+		place, err := generic.ResolvePlace(ctx, req.place())
+		if err != nil {
+			var validationErr *generic.ValidationError
+			if !errors.As(err, &validationErr) {
+				return generic.ErrorResponse(ctx, request, err)
+			}
+			rowErrors = append(rowErrors, generic.ImportRowError{Row: rowNumber, Fields: generic.ValidationErrors{
+				{Field: validationErr.Field, Code: generic.CodeInvalid, Message: validationErr.Message},
+			}})
+			continue
+		}
+
+		rows = append(rows, importRow{row: rowNumber, req: req, place: place, dateSpotted: dateSpotted})
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var organizationID *int
+	if value := request.QueryStringParameters["organizationId"]; value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "must be an organization ID", Field: "organizationId"})
+		}
+		var isStaff bool
+		err = conn.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM organization_staff s JOIN organizations o ON o.id = s.organization_id
+				WHERE s.organization_id = $1 AND s.user_uuid = $2 AND s.verified AND o.verified
+			)
+		`, id, userUUID).Scan(&isStaff)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify staff role", err))
+		}
+		if !isStaff {
+			return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "only verified staff of a verified organization can import on its behalf"})
+		}
+		organizationID = &id
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	places := make([]generic.Place, len(rows))
+	for i, row := range rows {
+		places[i] = row.place
+	}
+	locationIDs, err := generic.ResolveLocations(ctx, tx, places)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to resolve locations", err))
+	}
+
+	keys := make([]generic.ListingKey, len(rows))
+	for i, row := range rows {
+		keys[i] = generic.ListingKey{AnimalType: row.req.AnimalType, LocationID: locationIDs[i], Date: row.dateSpotted, PetID: row.req.PetID}
+	}
+	existing, err := generic.FindDuplicates(ctx, tx, generic.ListingTypeSighting, keys)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to check for duplicates", err))
+	}
+
+	insertQuery := `
+		INSERT INTO sighting_listing (
+			listing_owner, organization_id, is_found, pet_name, pet_id, gender, breed, color,
+			animal_type, description, date_spotted, spotted_location, created_at
+		) VALUES (
+			$1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13
+		) RETURNING id
+	`
+
+	var ids []int
+	var duplicates []generic.ImportDuplicate
+	seen := map[string]int{}
+	imported := 0
+	for i, row := range rows {
+		if existing[i] != 0 {
+			duplicates = append(duplicates, generic.ImportDuplicate{Row: row.row, ExistingID: &existing[i]})
+			continue
+		}
+		if earlier, ok := seen[keys[i].String()]; ok {
+			duplicates = append(duplicates, generic.ImportDuplicate{Row: row.row, SameAsRow: &earlier})
+			continue
+		}
+		seen[keys[i].String()] = row.row
+		imported++
+
+		if dryRun {
+			continue
+		}
+
+		req := row.req
+		var listingID int
+		err := tx.QueryRow(ctx, insertQuery,
+			userUUID, organizationID, false, req.PetName, req.PetID, req.Gender, req.Breed, req.Color,
+			req.AnimalType, req.Description, row.dateSpotted, locationIDs[i], time.Now(),
+		).Scan(&listingID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to insert sighting", err))
+		}
+
+		changes := generic.Diff(nil, map[string]any{
+			"organization_id":  organizationID,
+			"pet_name":         req.PetName,
+			"pet_id":           req.PetID,
+			"gender":           req.Gender,
+			"breed":            req.Breed,
+			"color":            req.Color,
+			"animal_type":      req.AnimalType,
+			"description":      req.Description,
+			"date_spotted":     row.dateSpotted,
+			"spotted_location": locationIDs[i],
+		})
+		if err := generic.RecordHistory(ctx, tx, generic.ListingTypeSighting, listingID, userUUID, generic.ActionCreate, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+		}
+		if err := generic.RecordEvent(ctx, tx, generic.ListingTypeSighting, listingID, generic.ActionCreate, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+		}
+		if err := generic.QueueSightingAlerts(ctx, tx, listingID); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to queue sighting alerts", err))
+		}
+
+		ids = append(ids, listingID)
+	}
+
+	// A dry run leaves new cities and locations uncommitted too
+	if !dryRun {
+		if err := tx.Commit(ctx); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to import sightings", err))
+		}
+	}
+
+	status := http.StatusOK
+	if len(ids) > 0 {
+		status = http.StatusCreated
+	}
+	return generic.Response(status, generic.ImportReport(len(rawRows), imported, ids, duplicates, rowErrors, dryRun))
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
@@ -39,9 +38,58 @@ type SightingRequest struct {
 	IsFound *bool `json:"isFound,omitempty"`
 }
 
+// Validate checks the request and returns every violation. Partial requests (updates)
+// only validate the fields that are present.
+func (r *SightingRequest) Validate(partial bool) generic.ValidationErrors {
+	rules := []generic.Rule{
+		generic.OneOf("animalType", r.AnimalType, generic.AnimalTypes),
+		generic.PastDate("dateSpotted", r.DateSpotted),
+		generic.MaxLength("description", r.Description, generic.MaxDescriptionLength),
+	}
+	if r.PetName != nil {
+		rules = append(rules, generic.MaxLength("petName", *r.PetName, 100))
+	}
+	if r.Gender != nil {
+		rules = append(rules, generic.OneOf("gender", *r.Gender, generic.Genders))
+	}
+	if !partial {
+		rules = append(rules,
+			generic.Required("animalType", r.AnimalType),
+			generic.RequiredList("color", r.Color),
+			generic.Required("dateSpotted", r.DateSpotted),
+			generic.Required("location", r.Location),
+			// Without coordinates the sighting is placed at the centre of its city
+			generic.RequiredValue("locationCoords", r.LocationCoords != nil || r.City != ""),
+		)
+	}
+	if r.LocationCoords != nil {
+		rules = append(rules,
+			generic.InRange("locationCoords.lat", r.LocationCoords.Lat, -90, 90),
+			generic.InRange("locationCoords.lng", r.LocationCoords.Lng, -180, 180),
+		)
+	}
+	return generic.Validate(rules...)
+}
+
+// place is the address in the request, without coordinates if it has none
+func (r *SightingRequest) place() generic.Place {
+	place := generic.Place{
+		StreetAddress:   r.Location,
+		PostalCode:      r.PostalCode,
+		City:            r.City,
+		ProvinceOrState: r.ProvinceOrState,
+		Country:         r.Country,
+	}
+	if r.LocationCoords != nil {
+		place.Lat, place.Lng = r.LocationCoords.Lat, r.LocationCoords.Lng
+	}
+	return place
+}
+
 type SightingResponse struct {
 	ID              int        `json:"id"`
-	ListingOwner    string     `json:"listing_owner"`
+	ListingOwner    string     `json:"listing_owner,omitempty"`
+	OrganizationID  *int       `json:"organization_id,omitempty"`
 	IsFound         bool       `json:"is_found"`
 	DateFound       *time.Time `json:"date_found,omitempty"`
 	PetName         *string    `json:"pet_name,omitempty"`
@@ -53,13 +101,17 @@ type SightingResponse struct {
 	Description     string     `json:"description,omitempty"`
 	ImageURLs       []string   `json:"image_urls,omitempty"`
 	DateSpotted     time.Time  `json:"date_spotted"`
-	SpottedLocation int        `json:"spotted_location"`
+	SpottedLocation int        `json:"spotted_location,omitempty"`
 	Location        *Location  `json:"location,omitempty"`
 	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DistanceKm      *float64   `json:"distance_km,omitempty"`
+
+	sortValue string
 }
 
 type Location struct {
-	ID            int     `json:"id"`
+	ID            int     `json:"id,omitempty"`
 	StreetAddress string  `json:"street_address"`
 	PostalCode    *string `json:"postal_code"`
 	Latitude      float64 `json:"latitude"`
@@ -67,36 +119,6 @@ type Location struct {
 	CityID        *int    `json:"city_id,omitempty"`
 }
 
-// Error types
-type AuthError struct {
-	Message string
-	Err     error
-}
-
-func (e *AuthError) Error() string {
-	if e.Err != nil {
-		return e.Message + ": " + e.Err.Error()
-	}
-	return e.Message
-}
-
-type NotFoundError struct {
-	Message string
-}
-
-func (e *NotFoundError) Error() string {
-	return e.Message
-}
-
-type ValidationError struct {
-	Message string
-	Field   string
-}
-
-func (e *ValidationError) Error() string {
-	return e.Message
-}
-
 // Helper functions
 func extractUserFromToken(request events.APIGatewayProxyRequest) (string, string, error) {
 	authHeader := request.Headers["Authorization"]
@@ -104,7 +126,7 @@ func extractUserFromToken(request events.APIGatewayProxyRequest) (string, string
 		authHeader = request.Headers["authorization"]
 	}
 	if authHeader == "" {
-		return "", "", &AuthError{Message: "missing authorization header"}
+		return "", "", &generic.AuthError{Message: "missing authorization header"}
 	}
 
 	token := strings.TrimPrefix(authHeader, "Bearer ")
@@ -112,12 +134,12 @@ func extractUserFromToken(request events.APIGatewayProxyRequest) (string, string
 
 	tokenClaims, err := generic.TokenClaims(token)
 	if err != nil {
-		return "", "", &AuthError{Message: "invalid or expired token", Err: err}
+		return "", "", &generic.AuthError{Message: "invalid or expired token", Err: err}
 	}
 
 	email, ok := tokenClaims["email"].(string)
 	if !ok || email == "" {
-		return "", "", &AuthError{Message: "email not found in token"}
+		return "", "", &generic.AuthError{Message: "email not found in token"}
 	}
 
 	return token, email, nil
@@ -129,10 +151,11 @@ func getUserUUID(ctx context.Context, conn *pgx.Conn, email string) (string, err
 	err := conn.QueryRow(ctx, queryUser, email).Scan(&userUUID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return "", &NotFoundError{Message: "user not found"}
+			return "", &generic.NotFoundError{Message: "user not found"}
 		}
-		return "", err
+		return "", generic.Internal("failed to query user", err)
 	}
+	generic.SetUser(ctx, userUUID)
 	return userUUID, nil
 }
 
@@ -142,118 +165,181 @@ func GetUserUUID(ctx context.Context, conn *pgx.Conn, email string) (string, err
 }
 
 func parseDate(dateStr string) (time.Time, error) {
-	dateFormats := []string{
-		time.RFC3339,
-		"2006-01-02T15:04:05Z07:00",
-		"2006-01-02",
-		time.RFC3339Nano,
-	}
-
-	for _, format := range dateFormats {
-		if date, err := time.Parse(format, dateStr); err == nil {
-			return date, nil
-		}
+	date, err := generic.ParseDate(dateStr)
+	if err != nil {
+		return time.Time{}, &generic.ValidationError{Message: "invalid date format", Field: "dateSpotted"}
 	}
-
-	return time.Time{}, &ValidationError{Message: "invalid date format", Field: "dateSpotted"}
+	return date, nil
 }
 
-func getOrCreateCity(ctx context.Context, conn *pgx.Conn, cityName, province, country string) (*int, error) {
-	var cityID int
-	query := `SELECT id FROM cities WHERE city_name = $1 AND province_or_state = $2 AND country = $3 LIMIT 1`
-	err := conn.QueryRow(ctx, query, cityName, province, country).Scan(&cityID)
-	if err == nil {
-		return &cityID, nil
-	}
-	if err != pgx.ErrNoRows {
-		return nil, err
+// canManage is true when user $2 may change sighting x. A shelter's sightings belong to
+// the organization, so any of its verified staff can manage them and staff who leave
+// can't; other sightings belong to whoever posted them.
+const canManage = `CASE WHEN x.organization_id IS NULL THEN x.listing_owner = $2 ELSE EXISTS (
+	SELECT 1 FROM organization_staff s
+	WHERE s.organization_id = x.organization_id AND s.user_uuid = $2 AND s.verified
+) END`
+
+// verifyOwner returns the caller's user UUID if they can manage the sighting
+func verifyOwner(ctx context.Context, conn *pgx.Conn, listingID, email string) (string, error) {
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return "", err
 	}
 
-	insertQuery := `INSERT INTO cities (city_name, province_or_state, country) VALUES ($1, $2, $3) RETURNING id`
-	err = conn.QueryRow(ctx, insertQuery, cityName, province, country).Scan(&cityID)
+	var allowed bool
+	err = conn.QueryRow(ctx, "SELECT "+canManage+" FROM sighting_listing x WHERE x.id=$1 AND x.deleted_at IS NULL", listingID, userUUID).Scan(&allowed)
 	if err != nil {
-		return nil, err
+		if err == pgx.ErrNoRows {
+			return "", &generic.NotFoundError{Message: "sighting not found"}
+		}
+		return "", generic.Internal("failed to verify ownership", err)
+	}
+	if !allowed {
+		return "", &generic.ForbiddenError{Message: "no permission"}
 	}
 
-	return &cityID, nil
+	return userUUID, nil
 }
 
-func getOrCreateLocation(ctx context.Context, conn *pgx.Conn, streetAddress string, postalCode *string, lat, lng float64, cityID *int) (int, error) {
-	var locationID int
-	query := `SELECT id FROM locations WHERE street_address = $1 AND latitude = $2 AND longitude = $3 LIMIT 1`
-	err := conn.QueryRow(ctx, query, streetAddress, lat, lng).Scan(&locationID)
-	if err == nil {
-		return locationID, nil
-	}
-	if err != pgx.ErrNoRows {
-		return 0, err
+// resolveSort maps the sort and order query parameters to a keyset sort. Distance
+// binds the origin point, so those arguments are appended to args.
+func resolveSort(params map[string]string, args []interface{}, searchPos int) (generic.SortKey, []interface{}, error) {
+	sort := generic.SortKey{Name: params["sort"], IDColumn: "s.id", Type: "timestamptz", Desc: true}
+
+	switch sort.Name {
+	case "", generic.SortCreated:
+		sort.Name = generic.SortCreated
+		sort.Column = "s.created_at"
+	case "dateSpotted":
+		sort.Column = "s.date_spotted"
+	case generic.SortUpdated:
+		sort.Column = "s.updated_at"
+	case generic.SortDistance:
+		lat, lng, err := generic.ParseOrigin(params)
+		if err != nil {
+			return generic.SortKey{}, args, err
+		}
+		args = append(args, lat, lng)
+		// Metres, so the order comes straight off the locations spatial index
+		sort.Column = generic.NearestFirst("loc.geog", generic.GeogParam(len(args)-1, len(args)))
+		sort.Type = "float8"
+		sort.Desc = false
+	case generic.SortRelevance:
+		if searchPos == 0 {
+			return generic.SortKey{}, args, &generic.ValidationError{Message: "relevance sort requires a search query", Field: "q"}
+		}
+		sort.Column = "ts_rank(s.search_vector, websearch_to_tsquery('english', $" + strconv.Itoa(searchPos) + "))"
+		sort.Type = "real"
+	default:
+		return generic.SortKey{}, args, &generic.ValidationError{
+			Message: "must be one of: created, dateSpotted, updated, distance, relevance",
+			Field:   "sort",
+		}
 	}
 
-	insertQuery := `INSERT INTO locations (street_address, postal_code, latitude, longitude, city_id, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
-	err = conn.QueryRow(ctx, insertQuery, streetAddress, postalCode, lat, lng, cityID, time.Now()).Scan(&locationID)
-	if err != nil {
-		return 0, err
-	}
-	return locationID, nil
+	sort, err := generic.WithOrder(sort, params["order"])
+	return sort, args, err
 }
 
-func getAllSightings(ctx context.Context, conn *pgx.Conn, queryParams map[string]string, email string) (events.APIGatewayProxyResponse, error) {
-	query := `
-		SELECT 
-			s.id, s.listing_owner, s.is_found, s.date_found, s.pet_name, s.pet_id,
-			s.gender, s.breed, s.color, s.animal_type, s.description,
-			s.image_urls, s.date_spotted, s.spotted_location, s.created_at
-		FROM sighting_listing s
-		WHERE 1=1
-	`
-
+// listFilters builds the WHERE conditions shared by the list and export handlers from the
+// query parameters. The conditions assume sighting_listing is aliased s and locations loc.
+func listFilters(ctx context.Context, conn *pgx.Conn, queryParams map[string]string, email string) (string, []interface{}, int, error) {
+	where := ` WHERE s.deleted_at IS NULL`
 	args := []interface{}{}
 	argPos := 1
 
 	// Filter by animal type
 	if animalType, ok := queryParams["animalType"]; ok && animalType != "" {
-		query += ` AND s.animal_type = $` + strconv.Itoa(argPos)
+		where += ` AND s.animal_type = $` + strconv.Itoa(argPos)
 		args = append(args, animalType)
 		argPos++
 	}
 
 	// Filter by user
 	if mine, ok := queryParams["mine"]; ok && mine == "true" {
+		if email == "" {
+			return "", nil, 0, &generic.AuthError{Message: "sign in to list your own sightings"}
+		}
 		userUUID, err := getUserUUID(ctx, conn, email)
 		if err != nil {
-			return generic.Response(http.StatusInternalServerError, generic.Json{
-				"error": "failed to get user",
-			})
+			return "", nil, 0, err
 		}
-		query += ` AND s.listing_owner = $` + strconv.Itoa(argPos)
+		where += ` AND s.listing_owner = $` + strconv.Itoa(argPos)
 		args = append(args, userUUID)
 		argPos++
 	}
 
-	// Pagination
-	limit := 50
-	if limitStr, ok := queryParams["limit"]; ok {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
-			limit = parsedLimit
-		}
+	// Filter to animals in a shelter's care, or to one organization's intake
+	if organizationID, ok := queryParams["organizationId"]; ok && organizationID != "" {
+		where += ` AND s.organization_id = $` + strconv.Itoa(argPos)
+		args = append(args, organizationID)
+		argPos++
+	} else if queryParams["source"] == "shelter" {
+		where += ` AND s.organization_id IS NOT NULL`
+	}
+
+	// Full-text search over pet name, description and animal type
+	searchPos := 0
+	if q, ok := queryParams["q"]; ok && q != "" {
+		where += ` AND s.search_vector @@ websearch_to_tsquery('english', $` + strconv.Itoa(argPos) + `)`
+		args = append(args, q)
+		searchPos = argPos
+		argPos++
+	}
+
+	// Sightings without a location can't be ranked by distance
+	if queryParams["sort"] == generic.SortDistance {
+		where += ` AND loc.id IS NOT NULL`
 	}
 
-	offset := 0
-	if offsetStr, ok := queryParams["offset"]; ok {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
+	return where, args, searchPos, nil
+}
+
+func getAllSightings(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, email string) (events.APIGatewayProxyResponse, error) {
+	queryParams := request.QueryStringParameters
+
+	where, args, searchPos, err := listFilters(ctx, conn, queryParams, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+	from := `
+		FROM sighting_listing s
+		LEFT JOIN locations loc ON s.spotted_location = loc.id
+	` + where
+	filterArgs := args
+
+	// Sorting and pagination
+	sort, args, err := resolveSort(queryParams, args, searchPos)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	page, err := generic.ParsePage(queryParams, sort)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	var total int64
+	if page.Total != generic.TotalNone {
+		total, err = generic.CountTotal(ctx, conn, page.Total, from, filterArgs)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to count sighting listings", err))
 		}
 	}
 
-	query += ` ORDER BY s.created_at DESC LIMIT $` + strconv.Itoa(argPos) + ` OFFSET $` + strconv.Itoa(argPos+1)
-	args = append(args, limit, offset)
+	pageClause, pageArgs := page.Clause(sort, args)
+	query := `
+		SELECT 
+			s.id, s.listing_owner, s.organization_id, s.is_found, s.date_found, s.pet_name, s.pet_id,
+			s.gender, s.breed, s.color, s.animal_type, s.description,
+			s.image_urls, s.date_spotted, s.spotted_location, s.created_at, s.updated_at,
+			(` + sort.Column + `)::text
+	` + from + pageClause
 
-	rows, err := conn.Query(ctx, query, args...)
+	rows, err := conn.Query(ctx, query, pageArgs...)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to query sighting listings",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query sighting listings", err))
 	}
 	defer rows.Close()
 
@@ -263,115 +349,121 @@ func getAllSightings(ctx context.Context, conn *pgx.Conn, queryParams map[string
 		var dateFound *time.Time
 
 		err := rows.Scan(
-			&sighting.ID, &sighting.ListingOwner, &sighting.IsFound, &dateFound, &sighting.PetName, &sighting.PetID,
+			&sighting.ID, &sighting.ListingOwner, &sighting.OrganizationID, &sighting.IsFound, &dateFound, &sighting.PetName, &sighting.PetID,
 			&sighting.Gender, &sighting.Breed, &sighting.Color, &sighting.AnimalType, &sighting.Description,
-			&sighting.ImageURLs, &sighting.DateSpotted, &sighting.SpottedLocation, &sighting.CreatedAt,
+			&sighting.ImageURLs, &sighting.DateSpotted, &sighting.SpottedLocation, &sighting.CreatedAt, &sighting.UpdatedAt,
+			&sighting.sortValue,
 		)
 		if err != nil {
-			return generic.Response(http.StatusInternalServerError, generic.Json{
-				"error":   "failed to scan sighting listing",
-				"message": err.Error(),
-			})
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to scan sighting listing", err))
 		}
 
 		sighting.DateFound = dateFound
+		if queryParams["sort"] == generic.SortDistance {
+			if metres, err := strconv.ParseFloat(sighting.sortValue, 64); err == nil {
+				distance := metres / 1000
+				sighting.DistanceKm = &distance
+			}
+		}
+		if email == "" {
+			publicSighting(&sighting)
+		}
 		sightings = append(sightings, sighting)
 	}
 
-	return generic.Response(http.StatusOK, generic.Json{
-		"data":   sightings,
-		"count":  len(sightings),
-		"limit":  limit,
-		"offset": offset,
+	sightings, nextCursor, prevCursor := generic.PageResult(sightings, page, sort, func(sighting SightingResponse) (string, int) {
+		return sighting.sortValue, sighting.ID
 	})
+
+	body := generic.Json{
+		"data":        sightings,
+		"count":       len(sightings),
+		"limit":       page.Limit,
+		"next_cursor": nextCursor,
+		"prev_cursor": prevCursor,
+	}
+	if page.Total != generic.TotalNone {
+		body["total"] = total
+		body["total_estimated"] = page.Total == generic.TotalEstimate
+	}
+
+	return generic.Response(http.StatusOK, body)
 }
 
 func main() {
-	lambda.Start(handler)
+	generic.Start("sighting-listing", handler)
 }
 
-func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	if request.HTTPMethod == "OPTIONS" {
 		return generic.Response(http.StatusOK, generic.Json{})
 	}
 
 	switch request.HTTPMethod {
 	case "GET":
-		return handleGet(request)
+		return handleGet(ctx, request)
 	case "POST":
-		return handleCreate(request)
+		if strings.HasSuffix(request.Resource, "/import") {
+			return handleImport(ctx, request)
+		}
+		if strings.HasSuffix(request.Resource, "/restore") {
+			return handleRestore(ctx, request)
+		}
+		return handleCreate(ctx, request)
 	case "PUT", "PATCH":
-		return handleUpdate(request)
+		return handleUpdate(ctx, request)
 	case "DELETE":
-		return handleDelete(request)
+		return handleDelete(ctx, request)
 	default:
-		return generic.Response(http.StatusMethodNotAllowed, generic.Json{
-			"error": "method not allowed",
-		})
+		return generic.MethodNotAllowed(request)
 	}
 }
 
 // ------------------ CREATE ------------------
-func handleCreate(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handleCreate(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	_, email, err := extractUserFromToken(request)
 	if err != nil {
-		if authErr, ok := err.(*AuthError); ok {
-			return generic.Response(http.StatusUnauthorized, generic.Json{
-				"error": authErr.Message,
-			})
-		}
-		return generic.Response(http.StatusUnauthorized, generic.Json{
-			"error": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	var req SightingRequest
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error":   "invalid request body",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
 	}
 
-	if req.AnimalType == "" || req.DateSpotted == "" || req.Location == "" || len(req.Color) == 0 {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error": "missing required fields: animalType, color, dateSpotted, location",
-		})
-	}
-	if req.LocationCoords == nil {
-		return generic.Response(http.StatusBadRequest, generic.Json{
-			"error": "locationCoords (lat, lng) are required",
-		})
+	if errs := req.Validate(false); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
 	}
 
-	ctx := context.Background()
 	conn, err := generic.SupabaseConnect()
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{
-			"error":   "failed to connect to database",
-			"message": err.Error(),
-		})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
 	}
 	defer conn.Close(ctx)
 
 	userUUID, err := getUserUUID(ctx, conn, email)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to get user"})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	dateSpotted, err := parseDate(req.DateSpotted)
 	if err != nil {
-		return generic.Response(http.StatusBadRequest, generic.Json{"error": err.Error()})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
-	cityID, err := getOrCreateCity(ctx, conn, req.City, req.ProvinceOrState, req.Country)
+	place, err := generic.ResolvePlace(ctx, req.place())
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to create or fetch city"})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
-	locationID, err := getOrCreateLocation(ctx, conn, req.Location, req.PostalCode, req.LocationCoords.Lat, req.LocationCoords.Lng, cityID)
+	cityID, err := generic.GetOrCreateCity(ctx, conn, place)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to create location"})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create or fetch city", err))
+	}
+
+	locationID, err := generic.GetOrCreateLocation(ctx, conn, place, cityID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to create location", err))
 	}
 
 	userUUIDParsed, _ := uuid.Parse(userUUID)
@@ -385,8 +477,14 @@ func handleCreate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 		) RETURNING id
 	`
 
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
 	var sightingID int
-	err = conn.QueryRow(ctx, insertQuery,
+	err = tx.QueryRow(ctx, insertQuery,
 		userUUIDParsed,
 		false,
 		req.PetName,
@@ -401,43 +499,89 @@ func handleCreate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 		time.Now(),
 	).Scan(&sightingID)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to insert sighting", "message": err.Error()})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to insert sighting", err))
+	}
+
+	changes := generic.Diff(nil, map[string]any{
+		"pet_name":         req.PetName,
+		"pet_id":           req.PetID,
+		"gender":           req.Gender,
+		"breed":            req.Breed,
+		"color":            req.Color,
+		"animal_type":      req.AnimalType,
+		"description":      req.Description,
+		"date_spotted":     dateSpotted,
+		"spotted_location": locationID,
+	})
+	if err := generic.RecordHistory(ctx, tx, generic.ListingTypeSighting, sightingID, userUUID, generic.ActionCreate, changes); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+	}
+	if err := generic.RecordEvent(ctx, tx, generic.ListingTypeSighting, sightingID, generic.ActionCreate, changes); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+	}
+	if err := generic.QueueSightingAlerts(ctx, tx, sightingID); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to queue sighting alerts", err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to insert sighting", err))
 	}
 
 	return generic.Response(http.StatusCreated, generic.Json{"message": "Sighting created", "id": sightingID})
 }
 
 // ------------------ READ ------------------
-func handleGet(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	_, email, err := extractUserFromToken(request)
-	if err != nil {
-		return generic.Response(http.StatusUnauthorized, generic.Json{"error": err.Error()})
+// Sightings can be read without signing in; anonymous callers are rate limited and get
+// public fields and locations (see publicSighting)
+func handleGet(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	email := ""
+	if generic.HasAuthorization(request) {
+		var err error
+		_, email, err = extractUserFromToken(request)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
 	}
 
-	ctx := context.Background()
 	conn, err := generic.SupabaseConnect()
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to connect to database"})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
 	}
 	defer conn.Close(ctx)
 
+	if email == "" {
+		if strings.HasSuffix(request.Resource, "/export") || strings.HasSuffix(request.Resource, "/history") {
+			return generic.ErrorResponse(ctx, request, &generic.AuthError{Message: "missing authorization header"})
+		}
+		if err := generic.CheckPublicRateLimit(ctx, conn, request); err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+	}
+
+	if strings.HasSuffix(request.Resource, "/export") {
+		return exportSightings(ctx, conn, request, email)
+	}
+
 	listingID := request.PathParameters["id"]
+	if listingID != "" && strings.HasSuffix(request.Resource, "/history") {
+		return getSightingHistory(ctx, conn, request, listingID, email)
+	}
 	if listingID != "" {
-		return getSightingByID(ctx, conn, listingID)
+		return getSightingByID(ctx, conn, request, listingID, email)
 	}
-	return getAllSightings(ctx, conn, request.QueryStringParameters, email)
+	return getAllSightings(ctx, conn, request, email)
 }
 
-func getSightingByID(ctx context.Context, conn *pgx.Conn, id string) (events.APIGatewayProxyResponse, error) {
+func getSightingByID(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id, email string) (events.APIGatewayProxyResponse, error) {
 	query := `
 		SELECT
-			s.id, s.listing_owner, s.is_found, s.date_found, s.pet_name, s.pet_id,
+			s.id, s.listing_owner, s.organization_id, s.is_found, s.date_found, s.pet_name, s.pet_id,
 			s.gender, s.breed, s.color, s.animal_type, s.description,
-			s.image_urls, s.date_spotted, s.spotted_location, s.created_at,
+			s.image_urls, s.date_spotted, s.spotted_location, s.created_at, s.updated_at,
 			loc.id, loc.street_address, loc.postal_code, loc.latitude, loc.longitude, loc.city_id
 		FROM sighting_listing s
 		LEFT JOIN locations loc ON s.spotted_location = loc.id
-		WHERE s.id = $1
+		WHERE s.id = $1 AND s.deleted_at IS NULL
 	`
 
 	var sighting SightingResponse
@@ -445,54 +589,93 @@ func getSightingByID(ctx context.Context, conn *pgx.Conn, id string) (events.API
 	var dateFound *time.Time
 
 	err := conn.QueryRow(ctx, query, id).Scan(
-		&sighting.ID, &sighting.ListingOwner, &sighting.IsFound, &dateFound, &sighting.PetName, &sighting.PetID,
+		&sighting.ID, &sighting.ListingOwner, &sighting.OrganizationID, &sighting.IsFound, &dateFound, &sighting.PetName, &sighting.PetID,
 		&sighting.Gender, &sighting.Breed, &sighting.Color, &sighting.AnimalType, &sighting.Description,
-		&sighting.ImageURLs, &sighting.DateSpotted, &sighting.SpottedLocation, &sighting.CreatedAt,
+		&sighting.ImageURLs, &sighting.DateSpotted, &sighting.SpottedLocation, &sighting.CreatedAt, &sighting.UpdatedAt,
 		&location.ID, &location.StreetAddress, &location.PostalCode, &location.Latitude, &location.Longitude, &location.CityID,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return generic.Response(http.StatusNotFound, generic.Json{"error": "sighting not found"})
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "sighting not found"})
 		}
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": err.Error()})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query sighting", err))
 	}
 
 	sighting.DateFound = dateFound
 	sighting.Location = &location
+	if email == "" {
+		publicSighting(&sighting)
+	}
 	return generic.Response(http.StatusOK, generic.Json{"data": sighting})
 }
 
+// GET /sighting-listing/{id}/history - visible to whoever can manage the sighting and moderators
+func getSightingHistory(ctx context.Context, conn *pgx.Conn, request events.APIGatewayProxyRequest, id, email string) (events.APIGatewayProxyResponse, error) {
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	// History outlives deleted sightings, so a missing row is only a problem for non-moderators
+	allowed, found := false, true
+	err = conn.QueryRow(ctx, "SELECT "+canManage+" FROM sighting_listing x WHERE x.id=$1", id, userUUID).Scan(&allowed)
+	if err == pgx.ErrNoRows {
+		found = false
+	} else if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
+	}
+
+	if !allowed {
+		isModerator, err := generic.IsModerator(ctx, conn, userUUID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to check moderator role", err))
+		}
+		if !isModerator {
+			if !found {
+				return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "sighting not found"})
+			}
+			return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "no permission"})
+		}
+	}
+
+	entries, err := generic.ListHistory(ctx, conn, generic.ListingTypeSighting, id)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to query listing history", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{"data": entries, "count": len(entries)})
+}
+
 // ------------------ UPDATE ------------------
-func handleUpdate(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handleUpdate(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	_, email, err := extractUserFromToken(request)
 	if err != nil {
-		return generic.Response(http.StatusUnauthorized, generic.Json{"error": err.Error()})
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	listingID := request.PathParameters["id"]
 	if listingID == "" {
-		return generic.Response(http.StatusBadRequest, generic.Json{"error": "listing ID required"})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID required", Field: "id"})
 	}
 
 	var req SightingRequest
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
-		return generic.Response(http.StatusBadRequest, generic.Json{"error": "invalid request body"})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "invalid request body"})
+	}
+	if errs := req.Validate(true); len(errs) > 0 {
+		return generic.ErrorResponse(ctx, request, errs)
 	}
 
-	ctx := context.Background()
 	conn, err := generic.SupabaseConnect()
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to connect to database"})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
 	}
 	defer conn.Close(ctx)
 
-	userUUID, _ := getUserUUID(ctx, conn, email)
-
 	// Ownership check
-	var ownerID string
-	err = conn.QueryRow(ctx, "SELECT listing_owner FROM sighting_listing WHERE id=$1", listingID).Scan(&ownerID)
-	if err != nil || ownerID != userUUID {
-		return generic.Response(http.StatusForbidden, generic.Json{"error": "no permission"})
+	userUUID, err := verifyOwner(ctx, conn, listingID, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
 	updateFields := []string{}
@@ -535,7 +718,10 @@ func handleUpdate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 		argPos++
 	}
 	if req.DateSpotted != "" {
-		dateSpotted, _ := parseDate(req.DateSpotted)
+		dateSpotted, err := parseDate(req.DateSpotted)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
 		updateFields = append(updateFields, "date_spotted=$"+strconv.Itoa(argPos))
 		args = append(args, dateSpotted)
 		argPos++
@@ -552,53 +738,201 @@ func handleUpdate(request events.APIGatewayProxyRequest) (events.APIGatewayProxy
 	}
 
 	// Location update
-	if req.Location != "" && req.LocationCoords != nil {
-		cityID, _ := getOrCreateCity(ctx, conn, req.City, req.ProvinceOrState, req.Country)
-		locationID, _ := getOrCreateLocation(ctx, conn, req.Location, req.PostalCode, req.LocationCoords.Lat, req.LocationCoords.Lng, cityID)
+	if req.Location != "" && (req.LocationCoords != nil || req.City != "") {
+		place, err := generic.ResolvePlace(ctx, req.place())
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, err)
+		}
+		cityID, err := generic.GetOrCreateCity(ctx, conn, place)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to create or fetch city", err))
+		}
+		locationID, err := generic.GetOrCreateLocation(ctx, conn, place, cityID)
+		if err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to update location", err))
+		}
 		updateFields = append(updateFields, "spotted_location=$"+strconv.Itoa(argPos))
 		args = append(args, locationID)
 		argPos++
 	}
 
 	if len(updateFields) == 0 {
-		return generic.Response(http.StatusBadRequest, generic.Json{"error": "no fields to update"})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "no fields to update"})
+	}
+
+	// Each update field binds exactly one argument, so the new values line up with args
+	columns := make([]string, len(updateFields))
+	after := make(map[string]any, len(updateFields))
+	for i, field := range updateFields {
+		columns[i] = strings.SplitN(field, "=", 2)[0]
+		after[columns[i]] = args[i]
+	}
+
+	updateFields = append(updateFields, "updated_at=$"+strconv.Itoa(argPos))
+	args = append(args, time.Now())
+	argPos++
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := generic.RowValues(ctx, tx, "SELECT "+strings.Join(columns, ", ")+" FROM sighting_listing WHERE id=$1 AND deleted_at IS NULL FOR UPDATE", columns, listingID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "sighting not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to read sighting", err))
 	}
 
 	args = append(args, listingID)
 	query := "UPDATE sighting_listing SET " + strings.Join(updateFields, ", ") + " WHERE id=$" + strconv.Itoa(argPos)
-	_, err = conn.Exec(ctx, query, args...)
+	_, err = tx.Exec(ctx, query, args...)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to update sighting"})
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update sighting", err))
+	}
+
+	changes := generic.Diff(before, after)
+	action := generic.ActionUpdate
+	if change, ok := changes["is_found"]; ok {
+		action = generic.ActionUnfound
+		if found, _ := change.New.(bool); found {
+			action = generic.ActionFound
+		}
+	}
+	if len(changes) > 0 {
+		if err := generic.RecordHistory(ctx, tx, generic.ListingTypeSighting, listingID, userUUID, action, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+		}
+		if err := generic.RecordEvent(ctx, tx, generic.ListingTypeSighting, listingID, action, changes); err != nil {
+			return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to update sighting", err))
 	}
 
 	return generic.Response(http.StatusOK, generic.Json{"message": "Sighting updated successfully"})
 }
 
 // ------------------ DELETE ------------------
-func handleDelete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	_, email, _ := extractUserFromToken(request)
+func handleDelete(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
 	listingID := request.PathParameters["id"]
 	if listingID == "" {
-		return generic.Response(http.StatusBadRequest, generic.Json{"error": "listing ID required"})
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID required", Field: "id"})
 	}
 
-	ctx := context.Background()
-	conn, _ := generic.SupabaseConnect()
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
 	defer conn.Close(ctx)
 
-	userUUID, _ := GetUserUUID(ctx, conn, email)
-
 	// Ownership check
-	var ownerID string
-	err := conn.QueryRow(ctx, "SELECT listing_owner FROM sighting_listing WHERE id=$1", listingID).Scan(&ownerID)
-	if err != nil || ownerID != userUUID {
-		return generic.Response(http.StatusForbidden, generic.Json{"error": "no permission"})
+	userUUID, err := verifyOwner(ctx, conn, listingID, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	// Soft delete; the purge job removes the row and its images once the grace period is over
+	_, err = tx.Exec(ctx, "UPDATE sighting_listing SET deleted_at=$1 WHERE id=$2 AND deleted_at IS NULL", time.Now(), listingID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to delete sighting", err))
+	}
+
+	if err := generic.RecordHistory(ctx, tx, generic.ListingTypeSighting, listingID, userUUID, generic.ActionDelete, nil); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+	}
+	if err := generic.RecordEvent(ctx, tx, generic.ListingTypeSighting, listingID, generic.ActionDelete, nil); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to delete sighting", err))
+	}
+
+	return generic.Response(http.StatusOK, generic.Json{
+		"message":       "Sighting deleted successfully",
+		"restore_until": time.Now().Add(generic.DeleteGracePeriod),
+	})
+}
+
+// ------------------ RESTORE ------------------
+func handleRestore(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	_, email, err := extractUserFromToken(request)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
+	}
+
+	listingID := request.PathParameters["id"]
+	if listingID == "" {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "listing ID required", Field: "id"})
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to connect to database", err))
+	}
+	defer conn.Close(ctx)
+
+	userUUID, err := getUserUUID(ctx, conn, email)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, err)
 	}
 
-	_, err = conn.Exec(ctx, "DELETE FROM sighting_listing WHERE id=$1", listingID)
+	var allowed bool
+	var deletedAt *time.Time
+	err = conn.QueryRow(ctx, "SELECT "+canManage+", x.deleted_at FROM sighting_listing x WHERE x.id=$1", listingID, userUUID).Scan(&allowed, &deletedAt)
 	if err != nil {
-		return generic.Response(http.StatusInternalServerError, generic.Json{"error": "failed to delete sighting"})
+		if err == pgx.ErrNoRows {
+			return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "sighting not found"})
+		}
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to verify ownership", err))
+	}
+	if !allowed {
+		return generic.ErrorResponse(ctx, request, &generic.ForbiddenError{Message: "no permission"})
+	}
+	if deletedAt == nil {
+		return generic.ErrorResponse(ctx, request, &generic.ValidationError{Message: "sighting is not deleted"})
+	}
+	if time.Since(*deletedAt) > generic.DeleteGracePeriod {
+		return generic.ErrorResponse(ctx, request, &generic.NotFoundError{Message: "sighting can no longer be restored"})
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to start transaction", err))
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "UPDATE sighting_listing SET deleted_at=NULL, updated_at=$1 WHERE id=$2", time.Now(), listingID)
+	if err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to restore sighting", err))
+	}
+
+	if err := generic.RecordHistory(ctx, tx, generic.ListingTypeSighting, listingID, userUUID, generic.ActionRestore, nil); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing history", err))
+	}
+	if err := generic.RecordEvent(ctx, tx, generic.ListingTypeSighting, listingID, generic.ActionRestore, nil); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to record listing event", err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return generic.ErrorResponse(ctx, request, generic.Internal("failed to restore sighting", err))
 	}
 
-	return generic.Response(http.StatusOK, generic.Json{"message": "Sighting deleted successfully"})
+	return generic.Response(http.StatusOK, generic.Json{"message": "Sighting restored successfully"})
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+)
+
+// Sightings have no privacy setting of their own, but a pet is often spotted from the
+// finder's home, so anonymous callers see sighting locations at the default level.
+
+// publicSighting clears the fields only signed-in users see and fuzzes the location
+func publicSighting(sighting *SightingResponse) {
+	sighting.ListingOwner = ""
+	sighting.PetID = nil
+	sighting.SpottedLocation = 0
+	if sighting.DistanceKm != nil {
+		distance := generic.FuzzDistance(generic.DefaultPrivacy, *sighting.DistanceKm)
+		sighting.DistanceKm = &distance
+	}
+
+	location := sighting.Location
+	if location == nil {
+		return
+	}
+	seed := generic.PrivacySeed(generic.ListingTypeSighting, sighting.ID)
+	location.ID = 0
+	location.Latitude, location.Longitude = generic.FuzzCoordinates(generic.DefaultPrivacy, seed, location.Latitude, location.Longitude)
+	location.StreetAddress = generic.FuzzAddress(generic.DefaultPrivacy, location.StreetAddress)
+	location.PostalCode = generic.FuzzPostalCode(generic.DefaultPrivacy, location.PostalCode)
+}
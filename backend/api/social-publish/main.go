@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackc/pgx/v5"
+)
+
+type pendingListing struct {
+	post    generic.LostPetPost
+	privacy string
+	street  *string
+	city    *string
+	state   *string
+}
+
+type stalePost struct {
+	id         int
+	listingID  int
+	externalID string
+}
+
+func main() {
+	lambda.Start(handler)
+}
+
+// handler runs on a schedule and reconciles each channel in SOCIAL_CHANNELS with the
+// listings: opted-in lost pets that aren't posted yet are published, and posts of pets
+// that were found, deleted or opted out are taken down
+func handler(ctx context.Context) error {
+	publishers, err := generic.Publishers()
+	if err != nil {
+		return err
+	}
+	if len(publishers) == 0 {
+		generic.Logger.Info("no social channels configured")
+		return nil
+	}
+
+	siteURL := os.Getenv("PUBLIC_SITE_URL")
+	if siteURL == "" {
+		return errors.New("PUBLIC_SITE_URL is not configured")
+	}
+
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	for channel, publisher := range publishers {
+		published, failed, err := publishPending(ctx, conn, channel, publisher, siteURL)
+		if err != nil {
+			generic.Logger.Error("failed to publish social posts", "channel", channel, "error", err.Error())
+			return err
+		}
+
+		removed, err := removeStale(ctx, conn, channel, publisher)
+		if err != nil {
+			generic.Logger.Error("failed to remove social posts", "channel", channel, "error", err.Error())
+			return err
+		}
+
+		generic.Logger.Info("reconciled social posts", "channel", channel, "published", published, "failed", failed, "removed", removed)
+	}
+	return nil
+}
+
+// publishPending posts opted-in listings that have no live post on the channel. A
+// failed post is retried on later runs until SocialMaxAttempts.
+func publishPending(ctx context.Context, conn *pgx.Conn, channel string, publisher generic.Publisher, siteURL string) (int, int, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT
+			l.id, l.pet_name, l.animal_type, l.breed, l.color, l.description, l.date_lost,
+			l.image_urls, l.location_privacy,
+			loc.street_address, c.city_name, c.province_or_state
+		FROM lost_pet_listing l
+		LEFT JOIN locations loc ON l.last_seen_location = loc.id
+		LEFT JOIN cities c ON loc.city_id = c.id
+		LEFT JOIN social_posts p ON p.listing_id = l.id AND p.channel = $1
+		WHERE l.social_posting AND NOT l.is_found AND l.deleted_at IS NULL
+			AND (p.id IS NULL OR p.status = 'removed' OR (p.status = 'failed' AND p.attempts < $2))
+		ORDER BY l.id
+		LIMIT $3
+	`, channel, generic.SocialMaxAttempts, generic.SocialBatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pending, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pendingListing, error) {
+		var l pendingListing
+		err := row.Scan(
+			&l.post.ID, &l.post.PetName, &l.post.AnimalType, &l.post.Breed, &l.post.Color, &l.post.Description, &l.post.DateLost,
+			&l.post.ImageURLs, &l.privacy,
+			&l.street, &l.city, &l.state,
+		)
+		return l, err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	published, failed := 0, 0
+	for _, l := range pending {
+		// Posts are public, so they get the anonymous view of the location
+		l.post.Area = generic.PublicArea(l.privacy, l.street, l.city, l.state)
+		externalID, postURL, err := publisher.Publish(ctx, generic.FormatLostPetPost(l.post, siteURL))
+		now := time.Now()
+
+		if err != nil {
+			failed++
+			generic.Logger.Warn("failed to publish social post", "channel", channel, "listing_id", l.post.ID, "error", err.Error())
+			_, err = conn.Exec(ctx, `
+				INSERT INTO social_posts (listing_id, channel, status, last_error, attempts, created_at, updated_at)
+				VALUES ($1, $2, 'failed', $3, 1, $4, $4)
+				ON CONFLICT (listing_id, channel) DO UPDATE
+				SET status = 'failed', last_error = EXCLUDED.last_error, attempts = social_posts.attempts + 1, updated_at = EXCLUDED.updated_at
+			`, l.post.ID, channel, generic.StoredError(err.Error()), now)
+			if err != nil {
+				return published, failed, err
+			}
+			continue
+		}
+
+		published++
+		_, err = conn.Exec(ctx, `
+			INSERT INTO social_posts (listing_id, channel, status, external_id, url, attempts, posted_at, created_at, updated_at)
+			VALUES ($1, $2, 'posted', $3, $4, 0, $5, $5, $5)
+			ON CONFLICT (listing_id, channel) DO UPDATE
+			SET status = 'posted', external_id = EXCLUDED.external_id, url = EXCLUDED.url, last_error = NULL,
+				attempts = 0, posted_at = EXCLUDED.posted_at, removed_at = NULL, updated_at = EXCLUDED.updated_at
+		`, l.post.ID, channel, externalID, nullIfEmpty(postURL), now)
+		if err != nil {
+			return published, failed, err
+		}
+	}
+	return published, failed, nil
+}
+
+// removeStale deletes the channel's posts of listings that were found, deleted or opted
+// out. A post that can't be deleted stays posted and is tried again on the next run.
+func removeStale(ctx context.Context, conn *pgx.Conn, channel string, publisher generic.Publisher) (int, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT p.id, p.listing_id, p.external_id
+		FROM social_posts p
+		JOIN lost_pet_listing l ON l.id = p.listing_id
+		WHERE p.channel = $1 AND p.status = 'posted'
+			AND (l.is_found OR l.deleted_at IS NOT NULL OR NOT l.social_posting)
+		ORDER BY p.id
+		LIMIT $2
+	`, channel, generic.SocialBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	stale, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (stalePost, error) {
+		var p stalePost
+		err := row.Scan(&p.id, &p.listingID, &p.externalID)
+		return p, err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, p := range stale {
+		now := time.Now()
+		if err := publisher.Delete(ctx, p.externalID); err != nil {
+			generic.Logger.Warn("failed to remove social post", "channel", channel, "listing_id", p.listingID, "error", err.Error())
+			if _, err := conn.Exec(ctx, `UPDATE social_posts SET last_error = $2, updated_at = $3 WHERE id = $1`, p.id, generic.StoredError(err.Error()), now); err != nil {
+				return removed, err
+			}
+			continue
+		}
+
+		removed++
+		_, err := conn.Exec(ctx, `
+			UPDATE social_posts SET status = 'removed', last_error = NULL, removed_at = $2, updated_at = $2
+			WHERE id = $1
+		`, p.id, now)
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
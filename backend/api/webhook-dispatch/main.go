@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MaiTra10/HackTheChange2025/backend/api/generic"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackc/pgx/v5"
+)
+
+// Deliveries are attempted by this many workers at once
+const workers = 10
+
+// A claimed delivery is hidden from other runs for deliveryLease, so a run that dies
+// mid-send only delays it
+const deliveryLease = 5 * time.Minute
+
+// Response bodies are read up to this size
+const maxResponseBytes = 4 << 10
+
+// Receivers must give their final URL; redirects aren't followed
+var client = generic.PublicClient(10 * time.Second)
+
+type delivery struct {
+	id       int64
+	attempts int
+	url      string
+	secret   string
+	event    generic.WebhookEvent
+
+	statusCode *int
+	err        error
+}
+
+func main() {
+	lambda.Start(handler)
+}
+
+// handler runs every minute. It fans new outbox events out to the endpoints subscribed
+// to them, then sends every delivery that is due.
+func handler(ctx context.Context) error {
+	conn, err := generic.SupabaseConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	events, err := fanOut(ctx, conn)
+	if err != nil {
+		generic.Logger.Error("failed to fan out webhook events", "error", err.Error())
+		return err
+	}
+
+	deliveries, err := claimDue(ctx, conn)
+	if err != nil {
+		generic.Logger.Error("failed to claim webhook deliveries", "error", err.Error())
+		return err
+	}
+	send(ctx, deliveries)
+
+	// One delivery that can't be recorded shouldn't keep the rest from being recorded
+	succeeded := 0
+	for _, d := range deliveries {
+		if err := recordAttempt(ctx, conn, d); err != nil {
+			generic.Logger.Error("failed to record webhook delivery", "delivery_id", d.id, "error", err.Error())
+			continue
+		}
+		if d.err == nil {
+			succeeded++
+		}
+	}
+
+	generic.Logger.Info("dispatched webhooks", "events", events, "deliveries", len(deliveries), "succeeded", succeeded)
+	return nil
+}
+
+// fanOut creates a delivery per active endpoint of a verified organization subscribed
+// to each undispatched event and marks the events dispatched, in one statement
+func fanOut(ctx context.Context, conn *pgx.Conn) (int64, error) {
+	tag, err := conn.Exec(ctx, `
+		WITH batch AS (
+			SELECT id, event_type FROM event_outbox
+			WHERE dispatched_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), deliveries AS (
+			INSERT INTO webhook_deliveries (endpoint_id, event_id, next_attempt_at, created_at)
+			SELECT w.id, b.id, $2, $2
+			FROM batch b
+			JOIN webhook_endpoints w ON w.active AND b.event_type = ANY (w.events)
+			JOIN organizations o ON o.id = w.organization_id AND o.verified
+		)
+		UPDATE event_outbox SET dispatched_at = $2 WHERE id IN (SELECT id FROM batch)
+	`, generic.EventBatchSize, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// claimDue leases the deliveries that are due, oldest first. Deliveries to paused
+// endpoints, or endpoints of organizations that have lost their verification, wait until
+// they are active and verified again.
+func claimDue(ctx context.Context, conn *pgx.Conn) ([]*delivery, error) {
+	now := time.Now()
+	rows, err := conn.Query(ctx, `
+		UPDATE webhook_deliveries d SET next_attempt_at = $2
+		FROM (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhook_endpoints w ON w.id = d.endpoint_id
+			JOIN organizations o ON o.id = w.organization_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= $1 AND w.active AND o.verified
+			ORDER BY d.next_attempt_at
+			LIMIT $3
+			FOR UPDATE OF d SKIP LOCKED
+		) due, webhook_endpoints w, event_outbox e
+		WHERE d.id = due.id AND w.id = d.endpoint_id AND e.id = d.event_id
+		RETURNING d.id, d.attempts, w.url, w.secret, e.id, e.event_type, e.listing_type, e.listing_id, e.changes, e.created_at
+	`, now, now.Add(deliveryLease), generic.DeliveryBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*delivery, error) {
+		d := &delivery{}
+		var changesJSON []byte
+		err := row.Scan(
+			&d.id, &d.attempts, &d.url, &d.secret,
+			&d.event.ID, &d.event.Type, &d.event.Data.ListingType, &d.event.Data.ListingID, &changesJSON, &d.event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if len(changesJSON) > 0 {
+			if err := json.Unmarshal(changesJSON, &d.event.Data.Changes); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	})
+}
+
+// send POSTs the deliveries concurrently and stores each outcome on the delivery
+func send(ctx context.Context, deliveries []*delivery) {
+	queue := make(chan *delivery)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range queue {
+				d.statusCode, d.err = post(ctx, d)
+			}
+		}()
+	}
+	for _, d := range deliveries {
+		queue <- d
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func post(ctx context.Context, d *delivery) (*int, error) {
+	body, err := json.Marshal(d.event)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "FindMyPet-Webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", strconv.FormatInt(d.id, 10))
+	req.Header.Set("X-Webhook-Event", d.event.Type)
+	req.Header.Set("X-Webhook-Signature", generic.SignWebhook(d.secret, time.Now(), body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	status := resp.StatusCode
+	if status < 200 || status > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+		return &status, errors.New(resp.Status + ": " + string(snippet))
+	}
+	return &status, nil
+}
+
+// recordAttempt stores the outcome of a send. Failures are retried with exponential
+// backoff until WebhookMaxAttempts, then the delivery is marked failed.
+func recordAttempt(ctx context.Context, conn *pgx.Conn, d *delivery) error {
+	now := time.Now()
+	attempts := d.attempts + 1
+
+	if d.err == nil {
+		_, err := conn.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'succeeded', attempts = $2, last_status_code = $3, last_error = NULL, delivered_at = $4
+			WHERE id = $1
+		`, d.id, attempts, d.statusCode, now)
+		return err
+	}
+
+	status := generic.DeliveryPending
+	if attempts >= generic.WebhookMaxAttempts {
+		status = generic.DeliveryFailed
+	}
+	message := generic.StoredError(d.err.Error())
+
+	_, err := conn.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, last_status_code = $4, last_error = $5, next_attempt_at = $6
+		WHERE id = $1
+	`, d.id, status, attempts, d.statusCode, message, now.Add(generic.WebhookBackoff(attempts)))
+	return err
+}
@@ -0,0 +1,34 @@
+-- Columns and indexes backing the sort options of GET /lost-listing and GET /sighting-listing.
+-- Every keyset index ends in id so ties are broken the same way the API pages them.
+
+ALTER TABLE lost_pet_listing
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz,
+    ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+        to_tsvector('english',
+            coalesce(pet_name, '') || ' ' || coalesce(animal_type, '') || ' ' || coalesce(description, ''))
+    ) STORED;
+
+ALTER TABLE sighting_listing
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz,
+    ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+        to_tsvector('english',
+            coalesce(pet_name, '') || ' ' || coalesce(animal_type, '') || ' ' || coalesce(description, ''))
+    ) STORED;
+
+-- Existing rows start out last updated when they were created. Only unset values are
+-- filled, so running this again doesn't reset later edits.
+UPDATE lost_pet_listing SET updated_at = created_at WHERE updated_at IS NULL;
+UPDATE sighting_listing SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE lost_pet_listing ALTER COLUMN updated_at SET DEFAULT now(), ALTER COLUMN updated_at SET NOT NULL;
+ALTER TABLE sighting_listing ALTER COLUMN updated_at SET DEFAULT now(), ALTER COLUMN updated_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS lost_pet_listing_created_at_id_idx ON lost_pet_listing (created_at, id);
+CREATE INDEX IF NOT EXISTS lost_pet_listing_date_lost_id_idx ON lost_pet_listing (date_lost, id);
+CREATE INDEX IF NOT EXISTS lost_pet_listing_updated_at_id_idx ON lost_pet_listing (updated_at, id);
+CREATE INDEX IF NOT EXISTS lost_pet_listing_search_idx ON lost_pet_listing USING gin (search_vector);
+
+CREATE INDEX IF NOT EXISTS sighting_listing_created_at_id_idx ON sighting_listing (created_at, id);
+CREATE INDEX IF NOT EXISTS sighting_listing_date_spotted_id_idx ON sighting_listing (date_spotted, id);
+CREATE INDEX IF NOT EXISTS sighting_listing_updated_at_id_idx ON sighting_listing (updated_at, id);
+CREATE INDEX IF NOT EXISTS sighting_listing_search_idx ON sighting_listing USING gin (search_vector);
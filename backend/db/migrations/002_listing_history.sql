@@ -0,0 +1,33 @@
+-- Append-only audit log for lost and sighting listings. Rows are never updated or
+-- deleted, and there is no foreign key so history survives the listing itself. The one
+-- update allowed is the actor's ON DELETE SET NULL, so users with history can be deleted.
+
+CREATE TABLE IF NOT EXISTS listing_history (
+    id           bigserial PRIMARY KEY,
+    listing_type text        NOT NULL CHECK (listing_type IN ('lost', 'sighting')),
+    listing_id   integer     NOT NULL,
+    actor        uuid        REFERENCES users (user_uuid) ON DELETE SET NULL,
+    action       text        NOT NULL CHECK (action IN ('create', 'update', 'found', 'unfound', 'delete')),
+    changes      jsonb,
+    created_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS listing_history_listing_idx ON listing_history (listing_type, listing_id, created_at);
+
+CREATE OR REPLACE FUNCTION listing_history_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.actor IS NULL
+        AND (NEW.id, NEW.listing_type, NEW.listing_id, NEW.action, NEW.changes, NEW.created_at)
+            IS NOT DISTINCT FROM (OLD.id, OLD.listing_type, OLD.listing_id, OLD.action, OLD.changes, OLD.created_at) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'listing_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS listing_history_no_update ON listing_history;
+CREATE TRIGGER listing_history_no_update
+    BEFORE UPDATE OR DELETE ON listing_history
+    FOR EACH ROW EXECUTE FUNCTION listing_history_append_only();
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_moderator boolean NOT NULL DEFAULT false;
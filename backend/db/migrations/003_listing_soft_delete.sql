@@ -0,0 +1,12 @@
+-- Soft delete for listings. Deleted rows keep their data until the listing-purge job
+-- hard-deletes them (and their S3 images) after the 30 day restore window.
+
+ALTER TABLE lost_pet_listing ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE sighting_listing ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS lost_pet_listing_deleted_at_idx ON lost_pet_listing (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS sighting_listing_deleted_at_idx ON sighting_listing (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE listing_history DROP CONSTRAINT IF EXISTS listing_history_action_check;
+ALTER TABLE listing_history ADD CONSTRAINT listing_history_action_check
+    CHECK (action IN ('create', 'update', 'found', 'unfound', 'delete', 'restore', 'purge'));
@@ -0,0 +1,47 @@
+-- How a lost pet was found. One record per lost listing; creating it marks the listing found.
+
+CREATE TABLE IF NOT EXISTS reunifications (
+    id              serial PRIMARY KEY,
+    lost_listing_id integer NOT NULL UNIQUE REFERENCES lost_pet_listing (id) ON DELETE CASCADE,
+    outcome         text NOT NULL CHECK (outcome IN ('returned_home', 'found_via_sighting', 'shelter', 'deceased')),
+    sighting_id     integer REFERENCES sighting_listing (id) ON DELETE SET NULL,
+    helper_user     uuid REFERENCES users (user_uuid) ON DELETE SET NULL,
+    found_location  integer REFERENCES locations (id),
+    note            text,
+    date_found      timestamptz NOT NULL,
+    created_by      uuid REFERENCES users (user_uuid) ON DELETE SET NULL,
+    created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+-- Deleting a user keeps the reunifications they recorded. Tables created when
+-- created_by was NOT NULL are brought in line.
+ALTER TABLE reunifications ALTER COLUMN created_by DROP NOT NULL;
+ALTER TABLE reunifications DROP CONSTRAINT IF EXISTS reunifications_created_by_fkey;
+ALTER TABLE reunifications ADD CONSTRAINT reunifications_created_by_fkey
+    FOREIGN KEY (created_by) REFERENCES users (user_uuid) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS reunifications_helper_user_idx ON reunifications (helper_user) WHERE helper_user IS NOT NULL;
+CREATE INDEX IF NOT EXISTS reunifications_sighting_id_idx ON reunifications (sighting_id) WHERE sighting_id IS NOT NULL;
+
+-- Community stats: outcomes per month and the time it took to find each pet
+CREATE OR REPLACE VIEW reunification_stats AS
+SELECT
+    date_trunc('month', r.date_found) AS month,
+    r.outcome,
+    COUNT(*) AS reunifications,
+    AVG(r.date_found - l.date_lost) AS avg_time_to_found
+FROM reunifications r
+JOIN lost_pet_listing l ON l.id = r.lost_listing_id
+WHERE l.deleted_at IS NULL
+GROUP BY 1, 2;
+
+-- Contributor reputation: reunifications a user helped with, directly or through a sighting
+CREATE OR REPLACE VIEW contributor_reputation AS
+SELECT
+    r.helper_user AS user_uuid,
+    COUNT(*) AS reunifications_helped,
+    COUNT(*) FILTER (WHERE r.outcome = 'found_via_sighting') AS sightings_credited,
+    MAX(r.date_found) AS last_helped_at
+FROM reunifications r
+WHERE r.helper_user IS NOT NULL AND r.outcome <> 'deceased'
+GROUP BY r.helper_user;
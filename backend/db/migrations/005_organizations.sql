@@ -0,0 +1,40 @@
+-- Shelter and rescue organizations. Addresses reuse the locations/cities tables;
+-- opening hours are stored as a JSON array of {day, opens, closes}.
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id          serial PRIMARY KEY,
+    name        text NOT NULL,
+    org_type    text NOT NULL CHECK (org_type IN ('shelter', 'rescue')),
+    description text,
+    phone       text,
+    email       text,
+    website     text,
+    hours       jsonb NOT NULL DEFAULT '[]',
+    location_id integer NOT NULL REFERENCES locations (id),
+    verified    boolean NOT NULL DEFAULT false,
+    created_by  uuid REFERENCES users (user_uuid) ON DELETE SET NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS organizations_created_at_idx ON organizations (created_at, id);
+CREATE INDEX IF NOT EXISTS organizations_location_id_idx ON organizations (location_id);
+
+-- Staff accounts. Join requests stay unverified until an organization admin approves them.
+CREATE TABLE IF NOT EXISTS organization_staff (
+    organization_id integer NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_uuid       uuid NOT NULL REFERENCES users (user_uuid) ON DELETE CASCADE,
+    role            text NOT NULL CHECK (role IN ('admin', 'staff')),
+    verified        boolean NOT NULL DEFAULT false,
+    verified_at     timestamptz,
+    added_by        uuid REFERENCES users (user_uuid) ON DELETE SET NULL,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (organization_id, user_uuid)
+);
+
+CREATE INDEX IF NOT EXISTS organization_staff_user_uuid_idx ON organization_staff (user_uuid);
+
+-- Intake listings are sightings posted on behalf of an organization
+ALTER TABLE sighting_listing ADD COLUMN IF NOT EXISTS organization_id integer REFERENCES organizations (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS sighting_listing_organization_id_idx ON sighting_listing (organization_id) WHERE organization_id IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Organizations loaded by the shelter-import tool remember which dataset row they came
+-- from so re-importing a dataset updates them in place. Self-registered organizations
+-- leave both columns NULL.
+
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS source text;
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS external_id text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS organizations_source_external_id_key ON organizations (source, external_id);
@@ -0,0 +1,5 @@
+-- Owners choose how precisely their pet's last seen location is shown to everyone else.
+-- Existing listings default to block level; the owner always sees the exact location.
+
+ALTER TABLE lost_pet_listing ADD COLUMN IF NOT EXISTS location_privacy text NOT NULL DEFAULT 'block'
+	CHECK (location_privacy IN ('exact', 'block', 'neighborhood'));
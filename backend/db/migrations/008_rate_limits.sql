@@ -0,0 +1,11 @@
+-- Fixed-window request counters shared by every Lambda instance. Anonymous reads are
+-- counted per source IP; listing-purge deletes windows older than a day.
+
+CREATE TABLE IF NOT EXISTS rate_limits (
+	key          text        NOT NULL,
+	window_start timestamptz NOT NULL,
+	hits         integer     NOT NULL DEFAULT 0,
+	PRIMARY KEY (key, window_start)
+);
+
+CREATE INDEX IF NOT EXISTS rate_limits_window_start_idx ON rate_limits (window_start);
@@ -0,0 +1,47 @@
+-- Outbound webhooks. Listing handlers write one event_outbox row per change in the same
+-- transaction as the change; webhook-dispatch fans each event out to a delivery per
+-- subscribed endpoint and retries failed deliveries with exponential backoff.
+
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id            bigserial PRIMARY KEY,
+    event_type    text NOT NULL,
+    listing_type  text NOT NULL,
+    listing_id    integer NOT NULL,
+    changes       jsonb,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    dispatched_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS event_outbox_pending_idx ON event_outbox (id) WHERE dispatched_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id              serial PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    url             text NOT NULL,
+    secret          text NOT NULL,
+    events          text[] NOT NULL,
+    active          boolean NOT NULL DEFAULT true,
+    created_by      uuid REFERENCES users (user_uuid) ON DELETE SET NULL,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS webhook_endpoints_organization_id_idx ON webhook_endpoints (organization_id);
+
+-- One row per event per endpoint. A replay adds a new row pointing at the original.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               bigserial PRIMARY KEY,
+    endpoint_id      integer NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event_id         bigint NOT NULL REFERENCES event_outbox (id) ON DELETE CASCADE,
+    status           text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts         integer NOT NULL DEFAULT 0,
+    next_attempt_at  timestamptz NOT NULL DEFAULT now(),
+    last_status_code integer,
+    last_error       text,
+    replay_of        bigint REFERENCES webhook_deliveries (id) ON DELETE SET NULL,
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    delivered_at     timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, created_at DESC);
@@ -0,0 +1,25 @@
+-- Social media auto-posting. Owners opt a lost listing in with social_posting;
+-- social-publish posts opted-in listings to each configured channel and takes the
+-- posts down once the pet is found, the listing is deleted or the owner opts out.
+-- listing-purge holds back a deleted listing while any of its posts is still posted,
+-- including posts on a channel no longer configured, which need taking down by hand.
+
+ALTER TABLE lost_pet_listing ADD COLUMN IF NOT EXISTS social_posting boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS social_posts (
+    id          serial PRIMARY KEY,
+    listing_id  integer NOT NULL REFERENCES lost_pet_listing (id) ON DELETE CASCADE,
+    channel     text NOT NULL,
+    status      text NOT NULL CHECK (status IN ('posted', 'removed', 'failed')),
+    external_id text,
+    url         text,
+    last_error  text,
+    attempts    integer NOT NULL DEFAULT 0,
+    posted_at   timestamptz,
+    removed_at  timestamptz,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (listing_id, channel)
+);
+
+CREATE INDEX IF NOT EXISTS social_posts_posted_idx ON social_posts (listing_id) WHERE status = 'posted';
@@ -0,0 +1,31 @@
+-- Email notifications. Handlers queue rows in notifications in the same transaction as
+-- the change that caused them; notification-dispatch renders and sends them, checking
+-- notification_preferences at send time.
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_uuid       uuid PRIMARY KEY REFERENCES users (user_uuid) ON DELETE CASCADE,
+    email_enabled   boolean NOT NULL DEFAULT true,
+    sighting_nearby boolean NOT NULL DEFAULT true,
+    listing_stale   boolean NOT NULL DEFAULT true,
+    messages        boolean NOT NULL DEFAULT true,
+    updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id              bigserial PRIMARY KEY,
+    user_uuid       uuid NOT NULL REFERENCES users (user_uuid) ON DELETE CASCADE,
+    kind            text NOT NULL,
+    data            jsonb NOT NULL DEFAULT '{}',
+    status          text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed', 'skipped')),
+    attempts        integer NOT NULL DEFAULT 0,
+    last_error      text,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    sent_at         timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS notifications_due_idx ON notifications (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS notifications_user_uuid_idx ON notifications (user_uuid, created_at DESC);
+
+-- Set once the owner has been warned that the listing is about to go stale
+ALTER TABLE lost_pet_listing ADD COLUMN IF NOT EXISTS stale_warned_at timestamptz;
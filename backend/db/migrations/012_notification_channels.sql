@@ -0,0 +1,32 @@
+-- SMS and Web Push notifications. Each notification row is now one delivery on one
+-- channel; handlers queue a row per channel the user routes that kind of alert to.
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS channel text NOT NULL DEFAULT 'email'
+    CHECK (channel IN ('email', 'sms', 'push'));
+
+ALTER TABLE notification_preferences
+    ADD COLUMN IF NOT EXISTS urgent_channels       text[] NOT NULL DEFAULT '{email}',
+    ADD COLUMN IF NOT EXISTS digest_channels       text[] NOT NULL DEFAULT '{email}',
+    ADD COLUMN IF NOT EXISTS quiet_hours_start     text,
+    ADD COLUMN IF NOT EXISTS quiet_hours_end       text,
+    ADD COLUMN IF NOT EXISTS timezone              text NOT NULL DEFAULT 'UTC',
+    -- Only a verified number is stored in phone_number; pending_phone waits for its code
+    ADD COLUMN IF NOT EXISTS phone_number          text,
+    ADD COLUMN IF NOT EXISTS phone_verified_at     timestamptz,
+    ADD COLUMN IF NOT EXISTS pending_phone         text,
+    ADD COLUMN IF NOT EXISTS phone_code_hash       text,
+    ADD COLUMN IF NOT EXISTS phone_code_expires_at timestamptz,
+    ADD COLUMN IF NOT EXISTS phone_code_attempts   integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id           serial PRIMARY KEY,
+    user_uuid    uuid NOT NULL REFERENCES users (user_uuid) ON DELETE CASCADE,
+    endpoint     text NOT NULL UNIQUE,
+    p256dh       text NOT NULL,
+    auth         text NOT NULL,
+    user_agent   text,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    last_used_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS push_subscriptions_user_uuid_idx ON push_subscriptions (user_uuid);
@@ -0,0 +1,23 @@
+-- Daily and weekly digests of new lost and sighting listings in an area. listing-digest
+-- queues one listing_digest notification per user and records each listing it showed
+-- in digest_items, so no listing is sent to the same user twice.
+
+ALTER TABLE notification_preferences
+    ADD COLUMN IF NOT EXISTS digest_frequency text NOT NULL DEFAULT 'off'
+        CHECK (digest_frequency IN ('off', 'daily', 'weekly')),
+    ADD COLUMN IF NOT EXISTS digest_city_id   integer REFERENCES cities (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS digest_latitude  double precision,
+    ADD COLUMN IF NOT EXISTS digest_longitude double precision,
+    ADD COLUMN IF NOT EXISTS digest_radius_km double precision,
+    ADD COLUMN IF NOT EXISTS last_digest_at   timestamptz;
+
+CREATE TABLE IF NOT EXISTS digest_items (
+    user_uuid    uuid NOT NULL REFERENCES users (user_uuid) ON DELETE CASCADE,
+    listing_type text NOT NULL CHECK (listing_type IN ('lost', 'sighting')),
+    listing_id   integer NOT NULL,
+    sent_at      timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_uuid, listing_type, listing_id)
+);
+
+CREATE INDEX IF NOT EXISTS notification_preferences_digest_idx ON notification_preferences (last_digest_at)
+    WHERE digest_frequency <> 'off';
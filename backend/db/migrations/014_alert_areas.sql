@@ -0,0 +1,22 @@
+-- Area alerts. Users register areas, a circle (latitude, longitude, radius_km) or a
+-- polygon (boundary, with x as longitude and y as latitude), and are told about new
+-- lost listings last seen inside them. Broadcasts per area are counted in rate_limits.
+
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS area_alerts boolean NOT NULL DEFAULT true;
+
+CREATE TABLE IF NOT EXISTS alert_areas (
+    id         serial PRIMARY KEY,
+    user_uuid  uuid NOT NULL REFERENCES users (user_uuid) ON DELETE CASCADE,
+    name       text NOT NULL,
+    latitude   double precision,
+    longitude  double precision,
+    radius_km  double precision,
+    boundary   polygon,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    CHECK ((boundary IS NULL) <> (radius_km IS NULL)),
+    CHECK (radius_km IS NULL OR (latitude IS NOT NULL AND longitude IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS alert_areas_user_uuid_idx ON alert_areas (user_uuid);
+CREATE INDEX IF NOT EXISTS alert_areas_boundary_idx ON alert_areas USING gist (boundary);
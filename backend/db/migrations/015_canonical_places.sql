@@ -0,0 +1,104 @@
+-- Canonical places. Cities belong to an ISO 3166-2 subdivision of an ISO 3166-1
+-- country. place_key is the folded "city|subdivision|country" the API matches on, so
+-- "calgary", "Calgary " and "Calgary, AB" are one city. Cities created before this
+-- migration have no place_key until city-merge backfills them and merges duplicates.
+
+CREATE TABLE IF NOT EXISTS countries (
+    code char(2) PRIMARY KEY,
+    name text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS subdivisions (
+    code         text PRIMARY KEY,
+    country_code char(2) NOT NULL REFERENCES countries (code),
+    name         text NOT NULL
+);
+
+INSERT INTO countries (code, name) VALUES
+    ('CA', 'Canada'),
+    ('US', 'United States')
+ON CONFLICT (code) DO UPDATE SET name = EXCLUDED.name;
+
+INSERT INTO subdivisions (code, country_code, name) VALUES
+    ('CA-AB', 'CA', 'Alberta'),
+    ('CA-BC', 'CA', 'British Columbia'),
+    ('CA-MB', 'CA', 'Manitoba'),
+    ('CA-NB', 'CA', 'New Brunswick'),
+    ('CA-NL', 'CA', 'Newfoundland and Labrador'),
+    ('CA-NS', 'CA', 'Nova Scotia'),
+    ('CA-NT', 'CA', 'Northwest Territories'),
+    ('CA-NU', 'CA', 'Nunavut'),
+    ('CA-ON', 'CA', 'Ontario'),
+    ('CA-PE', 'CA', 'Prince Edward Island'),
+    ('CA-QC', 'CA', 'Quebec'),
+    ('CA-SK', 'CA', 'Saskatchewan'),
+    ('CA-YT', 'CA', 'Yukon'),
+    ('US-AL', 'US', 'Alabama'),
+    ('US-AK', 'US', 'Alaska'),
+    ('US-AZ', 'US', 'Arizona'),
+    ('US-AR', 'US', 'Arkansas'),
+    ('US-CA', 'US', 'California'),
+    ('US-CO', 'US', 'Colorado'),
+    ('US-CT', 'US', 'Connecticut'),
+    ('US-DE', 'US', 'Delaware'),
+    ('US-DC', 'US', 'District of Columbia'),
+    ('US-FL', 'US', 'Florida'),
+    ('US-GA', 'US', 'Georgia'),
+    ('US-HI', 'US', 'Hawaii'),
+    ('US-ID', 'US', 'Idaho'),
+    ('US-IL', 'US', 'Illinois'),
+    ('US-IN', 'US', 'Indiana'),
+    ('US-IA', 'US', 'Iowa'),
+    ('US-KS', 'US', 'Kansas'),
+    ('US-KY', 'US', 'Kentucky'),
+    ('US-LA', 'US', 'Louisiana'),
+    ('US-ME', 'US', 'Maine'),
+    ('US-MD', 'US', 'Maryland'),
+    ('US-MA', 'US', 'Massachusetts'),
+    ('US-MI', 'US', 'Michigan'),
+    ('US-MN', 'US', 'Minnesota'),
+    ('US-MS', 'US', 'Mississippi'),
+    ('US-MO', 'US', 'Missouri'),
+    ('US-MT', 'US', 'Montana'),
+    ('US-NE', 'US', 'Nebraska'),
+    ('US-NV', 'US', 'Nevada'),
+    ('US-NH', 'US', 'New Hampshire'),
+    ('US-NJ', 'US', 'New Jersey'),
+    ('US-NM', 'US', 'New Mexico'),
+    ('US-NY', 'US', 'New York'),
+    ('US-NC', 'US', 'North Carolina'),
+    ('US-ND', 'US', 'North Dakota'),
+    ('US-OH', 'US', 'Ohio'),
+    ('US-OK', 'US', 'Oklahoma'),
+    ('US-OR', 'US', 'Oregon'),
+    ('US-PA', 'US', 'Pennsylvania'),
+    ('US-RI', 'US', 'Rhode Island'),
+    ('US-SC', 'US', 'South Carolina'),
+    ('US-SD', 'US', 'South Dakota'),
+    ('US-TN', 'US', 'Tennessee'),
+    ('US-TX', 'US', 'Texas'),
+    ('US-UT', 'US', 'Utah'),
+    ('US-VT', 'US', 'Vermont'),
+    ('US-VA', 'US', 'Virginia'),
+    ('US-WA', 'US', 'Washington'),
+    ('US-WV', 'US', 'West Virginia'),
+    ('US-WI', 'US', 'Wisconsin'),
+    ('US-WY', 'US', 'Wyoming')
+ON CONFLICT (code) DO UPDATE SET country_code = EXCLUDED.country_code, name = EXCLUDED.name;
+
+ALTER TABLE cities
+    ADD COLUMN IF NOT EXISTS country_code     char(2) REFERENCES countries (code),
+    ADD COLUMN IF NOT EXISTS subdivision_code text REFERENCES subdivisions (code),
+    ADD COLUMN IF NOT EXISTS place_key        text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS cities_place_key_idx ON cities (place_key);
+
+-- Other spellings of a city, as place keys. city-merge records the names of the cities
+-- it merges away here, so new listings typed the old way land on the kept city.
+CREATE TABLE IF NOT EXISTS city_aliases (
+    alias_key  text PRIMARY KEY,
+    city_id    integer NOT NULL REFERENCES cities (id) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS city_aliases_city_id_idx ON city_aliases (city_id);
@@ -0,0 +1,48 @@
+-- Spatial locations. geog is a PostGIS point generated from latitude and longitude, with a
+-- GiST index that distance sorts, radius searches and location reuse go through. Alert
+-- area circles get the same for their center. Locations that repeat the street address
+-- of an older one in the same city within 10 metres (LocationToleranceM) are merged into
+-- it, and the listings, sightings, reunifications and organizations pointing at them are
+-- repointed. A location that is only near a merged one, not the one kept, stays.
+
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS geog geography(Point, 4326)
+    GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography) STORED;
+
+CREATE INDEX IF NOT EXISTS locations_geog_idx ON locations USING gist (geog);
+CREATE INDEX IF NOT EXISTS locations_street_address_idx ON locations (lower(btrim(street_address)));
+
+-- A nearest-first scan of locations joins back to the listings at them
+CREATE INDEX IF NOT EXISTS lost_pet_listing_last_seen_location_idx ON lost_pet_listing (last_seen_location);
+CREATE INDEX IF NOT EXISTS sighting_listing_spotted_location_idx ON sighting_listing (spotted_location);
+
+ALTER TABLE alert_areas ADD COLUMN IF NOT EXISTS center geography(Point, 4326)
+    GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography) STORED;
+
+CREATE INDEX IF NOT EXISTS alert_areas_center_idx ON alert_areas USING gist (center);
+
+CREATE TEMP TABLE location_merges AS
+SELECT dup.id AS from_id, min(keep.id) AS into_id
+FROM locations dup
+JOIN locations keep ON keep.id < dup.id
+    AND lower(btrim(keep.street_address)) = lower(btrim(dup.street_address))
+    AND keep.city_id = dup.city_id
+    AND ST_DWithin(keep.geog, dup.geog, 10)
+WHERE NOT EXISTS (
+    SELECT 1 FROM locations older
+    WHERE older.id < keep.id
+        AND lower(btrim(older.street_address)) = lower(btrim(keep.street_address))
+        AND older.city_id = keep.city_id
+        AND ST_DWithin(older.geog, keep.geog, 10)
+)
+GROUP BY dup.id;
+
+UPDATE lost_pet_listing t SET last_seen_location = m.into_id FROM location_merges m WHERE t.last_seen_location = m.from_id;
+UPDATE sighting_listing t SET spotted_location = m.into_id FROM location_merges m WHERE t.spotted_location = m.from_id;
+UPDATE reunifications t SET found_location = m.into_id FROM location_merges m WHERE t.found_location = m.from_id;
+UPDATE organizations t SET location_id = m.into_id FROM location_merges m WHERE t.location_id = m.from_id;
+
+DELETE FROM locations l USING location_merges m WHERE l.id = m.from_id;
+
+DROP TABLE location_merges;
@@ -0,0 +1,28 @@
+-- Sighting trails. A lost listing's trail is its last seen location followed by the
+-- sightings its owner accepted onto it and the points the owner reported since, in time
+-- order. Accepted sightings take their location and date from the sighting, so edits to
+-- it show on the trail; owner updates carry their own.
+
+CREATE TABLE IF NOT EXISTS trail_points (
+    id              serial PRIMARY KEY,
+    lost_listing_id integer NOT NULL REFERENCES lost_pet_listing (id) ON DELETE CASCADE,
+    sighting_id     integer REFERENCES sighting_listing (id) ON DELETE CASCADE,
+    location_id     integer REFERENCES locations (id),
+    seen_at         timestamptz,
+    note            text,
+    created_by      uuid REFERENCES users (user_uuid) ON DELETE SET NULL,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (lost_listing_id, sighting_id),
+    CHECK (
+        (sighting_id IS NOT NULL AND location_id IS NULL AND seen_at IS NULL)
+        OR (sighting_id IS NULL AND location_id IS NOT NULL AND seen_at IS NOT NULL)
+    )
+);
+
+-- Points stay on the trail when the user who added them is deleted
+ALTER TABLE trail_points ALTER COLUMN created_by DROP NOT NULL;
+ALTER TABLE trail_points DROP CONSTRAINT IF EXISTS trail_points_created_by_fkey;
+ALTER TABLE trail_points ADD CONSTRAINT trail_points_created_by_fkey
+    FOREIGN KEY (created_by) REFERENCES users (user_uuid) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS trail_points_sighting_id_idx ON trail_points (sighting_id);
@@ -7,6 +7,22 @@ module "lambda-functions" {
     database_url = var.database_url
     google_client_id = var.google_client_id
     jwt_secret = var.jwt_secret
+    image_bucket_name = module.image-bucket.bucket_name
+    public_site_url = var.public_site_url
+    social_channels = var.social_channels
+    email_from = var.email_from
+    smtp_host = var.smtp_host
+    smtp_port = var.smtp_port
+    smtp_username = var.smtp_username
+    smtp_password = var.smtp_password
+    sms_provider = var.sms_provider
+    twilio_account_sid = var.twilio_account_sid
+    twilio_auth_token = var.twilio_auth_token
+    twilio_from = var.twilio_from
+    vapid_public_key = var.vapid_public_key
+    vapid_private_key = var.vapid_private_key
+    vapid_subject = var.vapid_subject
+    geocoder = var.geocoder
 }
 module "api-gateway" {
   source               = "./modules/api-gateway"
@@ -16,9 +32,65 @@ module "api-gateway" {
   sightings_listing_invoke_arn = module.lambda-functions.sightings_listing_invoke_arn
   lost_listing_function_name = module.lambda-functions.lost_listing_function_name
   lost_listing_invoke_arn = module.lambda-functions.lost_listing_invoke_arn
+  organization_function_name = module.lambda-functions.organization_function_name
+  organization_invoke_arn = module.lambda-functions.organization_invoke_arn
 }
 
 module "image-bucket" {
   source      = "./modules/s3-bucket"
   bucket_name = "image-bucket-${var.aws_region}-${random_id.suffix.hex}"
 }
+
+# Hard-delete soft-deleted listings once their restore window is over
+module "listing-purge-schedule" {
+  source              = "./modules/schedule"
+  name                = "listing-purge"
+  schedule_expression = "rate(1 day)"
+  function_name       = module.lambda-functions.listing_purge_function_name
+  function_arn        = module.lambda-functions.listing_purge_arn
+}
+
+# Delete rate limit windows, webhook events, notifications and digest records past their retention
+module "data-retention-schedule" {
+  source              = "./modules/schedule"
+  name                = "data-retention"
+  schedule_expression = "rate(1 day)"
+  function_name       = module.lambda-functions.data_retention_function_name
+  function_arn        = module.lambda-functions.data_retention_arn
+}
+
+# Deliver outbound webhooks and retry failed deliveries
+module "webhook-dispatch-schedule" {
+  source              = "./modules/schedule"
+  name                = "webhook-dispatch"
+  schedule_expression = "rate(1 minute)"
+  function_name       = module.lambda-functions.webhook_dispatch_function_name
+  function_arn        = module.lambda-functions.webhook_dispatch_arn
+}
+
+# Post opted-in lost listings to social media and take down posts of found pets
+module "social-publish-schedule" {
+  source              = "./modules/schedule"
+  name                = "social-publish"
+  schedule_expression = "rate(5 minutes)"
+  function_name       = module.lambda-functions.social_publish_function_name
+  function_arn        = module.lambda-functions.social_publish_arn
+}
+
+# Send queued notification emails and warn owners of listings about to go stale
+module "notification-dispatch-schedule" {
+  source              = "./modules/schedule"
+  name                = "notification-dispatch"
+  schedule_expression = "rate(1 minute)"
+  function_name       = module.lambda-functions.notification_dispatch_function_name
+  function_arn        = module.lambda-functions.notification_dispatch_arn
+}
+
+# Queue daily and weekly digests of new listings near each opted-in user
+module "listing-digest-schedule" {
+  source              = "./modules/schedule"
+  name                = "listing-digest"
+  schedule_expression = "rate(1 hour)"
+  function_name       = module.lambda-functions.listing_digest_function_name
+  function_arn        = module.lambda-functions.listing_digest_arn
+}
\ No newline at end of file
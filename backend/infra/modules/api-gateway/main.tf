@@ -83,4 +83,36 @@ module "lost_listing_endpoint" {
   execution_arn         = module.rest_api.execution_arn
   request_validator_id  = module.rest_api.body_validator_id
   root_resource_id      = module.rest_api.root_resource_id
+}
+
+module "organization_endpoint" {
+  source = "./template-endpoint"
+
+  path_part             = "organization"
+  http_method           = "POST"
+  model_name            = "organizationModel"
+  description           = "Model for validating shelter and rescue organization parameters"
+  schema                = <<EOF
+  {
+    "type": "object",
+    "properties": {
+      "name": {
+        "type": "string",
+        "minLength": 1,
+        "description": "Name of the shelter or rescue"
+      },
+      "type": {
+        "type": "string",
+        "enum": ["shelter", "rescue"]
+      }
+    },
+    "required": ["name", "type"]
+  }
+  EOF
+  func_name             = var.organization_function_name
+  invoke_arn            = var.organization_invoke_arn
+  rest_api_id           = module.rest_api.rest_api_id
+  execution_arn         = module.rest_api.execution_arn
+  request_validator_id  = module.rest_api.body_validator_id
+  root_resource_id      = module.rest_api.root_resource_id
 }
\ No newline at end of file
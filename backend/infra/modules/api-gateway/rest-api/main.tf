@@ -10,6 +10,9 @@ resource "aws_api_gateway_rest_api" "htc-api" {
         types   = ["REGIONAL"]
     }
 
+    # Lost pet flyers are returned base64-encoded and decoded for clients accepting these
+    binary_media_types = ["application/pdf", "image/png"]
+
 }
 # API Gateway request validators
 resource "aws_api_gateway_request_validator" "body_validator" {
@@ -21,4 +21,12 @@ variable "lost_listing_function_name" {
 variable "lost_listing_invoke_arn" {
     type        = string
     description = "The invoke ARN of the Lambda function for lost-listing"
+}
+variable "organization_function_name" {
+    type        = string
+    description = "The function name of the Lambda function for organization"
+}
+variable "organization_invoke_arn" {
+    type        = string
+    description = "The invoke ARN of the Lambda function for organization"
 }
\ No newline at end of file
@@ -25,6 +25,7 @@ module "sightings-listing-lambda" {
     environment_variables = {
         DATABASE_URL            = var.database_url
         JWT_SECRET              = var.jwt_secret
+        GEOCODER                = var.geocoder
     }
 
 }
@@ -36,10 +37,159 @@ module "lost-listing-lambda" {
     actions = ["*"]
     resources = ["*"]
     zip_dir_slice = "lost-listing"
+    # Flyers download and scale the listing photo
+    timeout = 15
+    memory_size = 512
 
     environment_variables = {
         DATABASE_URL            = var.database_url
         JWT_SECRET              = var.jwt_secret
+        GEOCODER                = var.geocoder
+        S3_BUCKET_NAME          = var.image_bucket_name
+        PUBLIC_SITE_URL         = var.public_site_url
+    }
+
+}
+
+module "listing-purge-lambda" {
+
+    source = "./template"
+    function_name = "listing-purge"
+    actions = ["*"]
+    resources = ["*"]
+    zip_dir_slice = "listing-purge"
+    timeout = 60
+
+    environment_variables = {
+        DATABASE_URL            = var.database_url
+        S3_BUCKET_NAME          = var.image_bucket_name
+    }
+
+}
+
+module "data-retention-lambda" {
+
+    source = "./template"
+    function_name = "data-retention"
+    actions = ["*"]
+    resources = ["*"]
+    zip_dir_slice = "data-retention"
+    timeout = 60
+
+    environment_variables = {
+        DATABASE_URL            = var.database_url
+    }
+
+}
+
+module "organization-lambda" {
+
+    source = "./template"
+    function_name = "organization"
+    actions = ["*"]
+    resources = ["*"]
+    zip_dir_slice = "organization"
+
+    environment_variables = {
+        DATABASE_URL            = var.database_url
+        JWT_SECRET              = var.jwt_secret
+        GEOCODER                = var.geocoder
+    }
+
+}
+
+module "webhook-dispatch-lambda" {
+
+    source = "./template"
+    function_name = "webhook-dispatch"
+    actions = ["*"]
+    resources = ["*"]
+    zip_dir_slice = "webhook-dispatch"
+    timeout = 60
+
+    environment_variables = {
+        DATABASE_URL            = var.database_url
+    }
+
+}
+
+module "social-publish-lambda" {
+
+    source = "./template"
+    function_name = "social-publish"
+    actions = ["*"]
+    resources = ["*"]
+    zip_dir_slice = "social-publish"
+    timeout = 60
+
+    environment_variables = {
+        DATABASE_URL            = var.database_url
+        PUBLIC_SITE_URL         = var.public_site_url
+        SOCIAL_CHANNELS         = var.social_channels
+    }
+
+}
+
+module "notification-lambda" {
+
+    source = "./template"
+    function_name = "notification"
+    actions = ["*"]
+    resources = ["*"]
+    zip_dir_slice = "notification"
+
+    environment_variables = {
+        DATABASE_URL            = var.database_url
+        JWT_SECRET              = var.jwt_secret
+        VAPID_PUBLIC_KEY        = var.vapid_public_key
+        SMS_PROVIDER            = var.sms_provider
+        TWILIO_ACCOUNT_SID      = var.twilio_account_sid
+        TWILIO_AUTH_TOKEN       = var.twilio_auth_token
+        TWILIO_FROM             = var.twilio_from
+        GEOCODER                = var.geocoder
+    }
+
+}
+
+module "notification-dispatch-lambda" {
+
+    source = "./template"
+    function_name = "notification-dispatch"
+    actions = ["*"]
+    resources = ["*"]
+    zip_dir_slice = "notification-dispatch"
+    timeout = 60
+
+    environment_variables = {
+        DATABASE_URL            = var.database_url
+        JWT_SECRET              = var.jwt_secret
+        PUBLIC_SITE_URL         = var.public_site_url
+        EMAIL_FROM              = var.email_from
+        SMTP_HOST               = var.smtp_host
+        SMTP_PORT               = var.smtp_port
+        SMTP_USERNAME           = var.smtp_username
+        SMTP_PASSWORD           = var.smtp_password
+        SMS_PROVIDER            = var.sms_provider
+        TWILIO_ACCOUNT_SID      = var.twilio_account_sid
+        TWILIO_AUTH_TOKEN       = var.twilio_auth_token
+        TWILIO_FROM             = var.twilio_from
+        VAPID_PRIVATE_KEY       = var.vapid_private_key
+        VAPID_SUBJECT           = var.vapid_subject
+    }
+
+}
+
+module "listing-digest-lambda" {
+
+    source = "./template"
+    function_name = "listing-digest"
+    actions = ["*"]
+    resources = ["*"]
+    zip_dir_slice = "listing-digest"
+    timeout = 300
+
+    environment_variables = {
+        DATABASE_URL            = var.database_url
     }
 
 }
\ No newline at end of file
@@ -19,3 +19,59 @@ output "lost_listing_function_name" {
 output "lost_listing_invoke_arn" {
     value = module.lost-listing-lambda.invoke_arn
 }
+# listing-purge
+output "listing_purge_function_name" {
+    value = module.listing-purge-lambda.function_name
+}
+output "listing_purge_arn" {
+    value = module.listing-purge-lambda.arn
+}
+# data-retention
+output "data_retention_function_name" {
+    value = module.data-retention-lambda.function_name
+}
+output "data_retention_arn" {
+    value = module.data-retention-lambda.arn
+}
+# organization
+output "organization_function_name" {
+    value = module.organization-lambda.function_name
+}
+output "organization_invoke_arn" {
+    value = module.organization-lambda.invoke_arn
+}
+# webhook-dispatch
+output "webhook_dispatch_function_name" {
+    value = module.webhook-dispatch-lambda.function_name
+}
+output "webhook_dispatch_arn" {
+    value = module.webhook-dispatch-lambda.arn
+}
+# social-publish
+output "social_publish_function_name" {
+    value = module.social-publish-lambda.function_name
+}
+output "social_publish_arn" {
+    value = module.social-publish-lambda.arn
+}
+# notification
+output "notification_function_name" {
+    value = module.notification-lambda.function_name
+}
+output "notification_invoke_arn" {
+    value = module.notification-lambda.invoke_arn
+}
+# notification-dispatch
+output "notification_dispatch_function_name" {
+    value = module.notification-dispatch-lambda.function_name
+}
+output "notification_dispatch_arn" {
+    value = module.notification-dispatch-lambda.arn
+}
+# listing-digest
+output "listing_digest_function_name" {
+    value = module.listing-digest-lambda.function_name
+}
+output "listing_digest_arn" {
+    value = module.listing-digest-lambda.arn
+}
\ No newline at end of file
@@ -49,12 +49,12 @@ resource "aws_lambda_function" "lambda" {
     function_name       = var.function_name
     role                = aws_iam_role.lambda-role.arn
     handler             = "main"
-    timeout             = 3
+    timeout             = var.timeout
     filename            = local.source_code_zip_dir
     source_code_hash    = filebase64sha256(local.source_code_zip_dir)
     runtime             = "provided.al2"
     architectures       = ["arm64"]
-    memory_size         = 128
+    memory_size         = var.memory_size
 
     dynamic "environment" {
     for_each = length(var.environment_variables) > 0 ? [1] : []
@@ -4,3 +4,6 @@ output "function_name" {
 output "invoke_arn" {
     value = aws_lambda_function.lambda.invoke_arn
 }
+output "arn" {
+    value = aws_lambda_function.lambda.arn
+}
\ No newline at end of file
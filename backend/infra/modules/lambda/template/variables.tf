@@ -14,4 +14,14 @@ variable "environment_variables" {
   type        = map(string)
   description = "Environment variables for the Lambda function"
   default     = {}
+}
+variable "timeout" {
+  type        = number
+  description = "Lambda timeout in seconds"
+  default     = 3
+}
+variable "memory_size" {
+  type        = number
+  description = "Lambda memory in MB"
+  default     = 128
 }
\ No newline at end of file
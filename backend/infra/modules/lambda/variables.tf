@@ -9,4 +9,71 @@ variable "google_client_id" {
 variable "jwt_secret" {
     type        = string
     description = "JWT secret key"
+}
+variable "public_site_url" {
+    type        = string
+    description = "Base URL of the frontend, used for links printed on flyers"
+}
+variable "social_channels" {
+    type        = string
+    description = "Comma-separated social channels lost listings are posted to"
+}
+variable "email_from" {
+    type        = string
+    description = "From address of notification emails"
+}
+variable "smtp_host" {
+    type        = string
+    description = "SMTP relay for notification emails; without one, emails are captured instead of sent"
+}
+variable "smtp_port" {
+    type        = string
+    description = "SMTP relay port"
+}
+variable "smtp_username" {
+    type        = string
+    description = "SMTP relay username"
+}
+variable "smtp_password" {
+    type        = string
+    description = "SMTP relay password"
+    sensitive   = true
+}
+variable "sms_provider" {
+    type        = string
+    description = "SMS provider for text notifications"
+}
+variable "twilio_account_sid" {
+    type        = string
+    description = "Twilio account SID"
+}
+variable "twilio_auth_token" {
+    type        = string
+    description = "Twilio auth token"
+    sensitive   = true
+}
+variable "twilio_from" {
+    type        = string
+    description = "Twilio number text notifications are sent from"
+}
+variable "vapid_public_key" {
+    type        = string
+    description = "VAPID public key for push notifications"
+}
+variable "vapid_private_key" {
+    type        = string
+    description = "VAPID private key for push notifications"
+    sensitive   = true
+}
+variable "vapid_subject" {
+    type        = string
+    description = "VAPID contact for push services"
+}
+variable "image_bucket_name" {
+    type        = string
+    description = "Name of the S3 bucket holding listing images"
+}
+variable "geocoder" {
+    type        = string
+    description = "Geocoder used to fill in and normalize addresses"
 }
\ No newline at end of file
@@ -0,0 +1,18 @@
+# EventBridge rule
+resource "aws_cloudwatch_event_rule" "schedule" {
+  name                = "${var.name}-schedule"
+  schedule_expression = var.schedule_expression
+}
+# Lambda target
+resource "aws_cloudwatch_event_target" "target" {
+  rule = aws_cloudwatch_event_rule.schedule.name
+  arn  = var.function_arn
+}
+# Permission for EventBridge to invoke the Lambda
+resource "aws_lambda_permission" "schedule_permission" {
+  statement_id  = "AllowExecutionFromEventBridge-${var.name}"
+  action        = "lambda:InvokeFunction"
+  function_name = var.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.schedule.arn
+}
@@ -0,0 +1,19 @@
+variable "name" {
+  description = "Name prefix for the EventBridge rule"
+  type        = string
+}
+
+variable "schedule_expression" {
+  description = "EventBridge schedule, e.g. rate(1 day) or cron(0 3 * * ? *)"
+  type        = string
+}
+
+variable "function_name" {
+  description = "Lambda function name"
+  type        = string
+}
+
+variable "function_arn" {
+  description = "Lambda function ARN"
+  type        = string
+}
@@ -13,4 +13,80 @@ variable "google_client_id" {
 variable "jwt_secret" {
     type        = string
     description = "JWT secret key"
+}
+variable "public_site_url" {
+    type        = string
+    description = "Base URL of the frontend, e.g. https://findmypet.example"
+}
+variable "email_from" {
+    type        = string
+    description = "From address of notification emails, e.g. FindMyPet <alerts@findmypet.example>"
+}
+variable "smtp_host" {
+    type        = string
+    description = "SMTP relay for notification emails; leave empty to capture emails instead of sending them"
+    default     = ""
+}
+variable "smtp_port" {
+    type        = string
+    description = "SMTP relay port"
+    default     = "587"
+}
+variable "smtp_username" {
+    type        = string
+    description = "SMTP relay username"
+    default     = ""
+}
+variable "smtp_password" {
+    type        = string
+    description = "SMTP relay password"
+    default     = ""
+    sensitive   = true
+}
+variable "sms_provider" {
+    type        = string
+    description = "SMS provider for text notifications: twilio, or capture to keep texts instead of sending them"
+    default     = "capture"
+}
+variable "twilio_account_sid" {
+    type        = string
+    description = "Twilio account SID"
+    default     = ""
+}
+variable "twilio_auth_token" {
+    type        = string
+    description = "Twilio auth token"
+    default     = ""
+    sensitive   = true
+}
+variable "twilio_from" {
+    type        = string
+    description = "Twilio number text notifications are sent from, in E.164 format"
+    default     = ""
+}
+variable "vapid_public_key" {
+    type        = string
+    description = "VAPID public key browsers subscribe to push notifications with (base64url)"
+    default     = ""
+}
+variable "vapid_private_key" {
+    type        = string
+    description = "VAPID private key push notifications are signed with (base64url); leave empty to capture pushes instead of sending them"
+    default     = ""
+    sensitive   = true
+}
+variable "vapid_subject" {
+    type        = string
+    description = "Contact push services can reach about our pushes, e.g. mailto:alerts@findmypet.example"
+    default     = ""
+}
+variable "social_channels" {
+    type        = string
+    description = "Comma-separated social channels lost listings are posted to, e.g. file"
+    default     = ""
+}
+variable "geocoder" {
+    type        = string
+    description = "Geocoder used to fill in and normalize listing addresses: gazetteer, the built-in offline city list"
+    default     = "gazetteer"
 }
\ No newline at end of file